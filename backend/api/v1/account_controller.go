@@ -0,0 +1,183 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
+	"github.com/sandipdas/go-doctor-booking/backend/middleware"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// accountLink builds a frontend URL carrying a single-use token
+func accountLink(path, token string) string {
+	base := config.GetEnv("APP_BASE_URL", "http://localhost:3000")
+	return base + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendVerificationEmail issues a verification token and emails it to the user
+func sendVerificationEmail(db *gorm.DB, m mailer.Mailer, user *models.User) error {
+	token, err := middleware.IssueUserToken(db, user.ID, models.TokenPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return m.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, accountLink("/verify-email", token), emailVerificationTTL),
+	})
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the email is registered.
+func ForgotPassword(db *gorm.DB, m mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := db.Where("email = ? AND active = ?", req.Email, true).First(&user).Error; err == nil {
+			token, err := middleware.IssueUserToken(db, user.ID, models.TokenPurposePasswordReset, passwordResetTTL)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+				return
+			}
+
+			if err := m.Send(mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your password",
+				Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password:\n\n%s\n\nThe link expires in %s. If you did not request a reset you can ignore this email.\n",
+					user.Name, accountLink("/reset-password", token), passwordResetTTL),
+			}); err != nil {
+				log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a reset link has been sent"})
+	}
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword and
+// signs out every existing session
+func ResetPassword(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hashedPassword, err := hashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			token, err := middleware.ConsumeUserToken(tx, req.Token, models.TokenPurposePasswordReset)
+			if err != nil {
+				return err
+			}
+
+			// UpdateColumn skips the BeforeUpdate hook, which would hash again
+			if err := tx.Model(&models.User{}).Where("id = ?", token.UserID).
+				UpdateColumn("password", hashedPassword).Error; err != nil {
+				return err
+			}
+			return middleware.RevokeAllTokens(tx, token.UserID)
+		})
+		if errors.Is(err, middleware.ErrInvalidUserToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+	}
+}
+
+// VerifyEmail confirms a user's email address using a token from the verification email
+func VerifyEmail(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.Query("token")
+		if raw == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			token, err := middleware.ConsumeUserToken(tx, raw, models.TokenPurposeEmailVerification)
+			if err != nil {
+				return err
+			}
+			return tx.Model(&models.User{}).Where("id = ?", token.UserID).
+				UpdateColumns(map[string]interface{}{
+					"email_verified":    true,
+					"email_verified_at": time.Now(),
+				}).Error
+		})
+		if errors.Is(err, middleware.ErrInvalidUserToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+	}
+}
+
+// ResendVerificationEmail sends a new verification link to the logged-in user
+func ResendVerificationEmail(db *gorm.DB, m mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if user.EmailVerified {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+			return
+		}
+
+		if err := sendVerificationEmail(db, m, &user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+	}
+}
@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/middleware"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+)
+
+// CreateAPIKeyRequest represents the request body for issuing an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse describes an API key without its secret
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(key *models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// CreateAPIKey issues a new scoped API key (admin only). The raw key is only
+// returned in this response.
+func CreateAPIKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, scope := range req.Scopes {
+			if !models.IsValidScope(scope) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":        "Unknown scope: " + scope,
+					"valid_scopes": models.APIKeyScopes,
+				})
+				return
+			}
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry must be in the future"})
+			return
+		}
+
+		raw, prefix, hash, err := middleware.GenerateAPIKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+			return
+		}
+
+		key := models.APIKey{
+			Name:        req.Name,
+			Prefix:      prefix,
+			KeyHash:     hash,
+			ExpiresAt:   req.ExpiresAt,
+			CreatedByID: userID.(uint),
+		}
+		key.SetScopes(req.Scopes)
+		if err := db.Create(&key).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "API key created successfully. Store the key now, it will not be shown again.",
+			"key":     raw,
+			"api_key": newAPIKeyResponse(&key),
+		})
+	}
+}
+
+// ListAPIKeys returns all API keys without their secrets (admin only)
+func ListAPIKeys(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var keys []models.APIKey
+		if err := db.Order("created_at DESC").Find(&keys).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+			return
+		}
+
+		data := make([]APIKeyResponse, 0, len(keys))
+		for i := range keys {
+			data = append(data, newAPIKeyResponse(&keys[i]))
+		}
+		c.JSON(http.StatusOK, gin.H{"data": data})
+	}
+}
+
+// RevokeAPIKey permanently disables an API key (admin only)
+func RevokeAPIKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var key models.APIKey
+		if err := db.First(&key, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+
+		if key.RevokedAt == nil {
+			now := time.Now()
+			if err := db.Model(&key).Update("revoked_at", now).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+	}
+}
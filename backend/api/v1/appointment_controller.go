@@ -1,13 +1,18 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
 	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetDoctorAppointments returns a list of appointments for the logged-in doctor
@@ -51,12 +56,17 @@ func GetDoctorAppointments(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		loc := doctor.Location()
+		for i := range appointments {
+			appointments[i].InLocation(loc)
+		}
+
 		c.JSON(http.StatusOK, appointments)
 	}
 }
 
 // UpdateAppointmentStatus updates the status of an appointment
-func UpdateAppointmentStatus(db *gorm.DB) gin.HandlerFunc {
+func UpdateAppointmentStatus(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 		appointmentID := c.Param("id")
@@ -70,29 +80,106 @@ func UpdateAppointmentStatus(db *gorm.DB) gin.HandlerFunc {
 
 		// Parse request body
 		var request struct {
-			Status string `json:"status" binding:"required,oneof=confirmed cancelled completed"`
+			Status string `json:"status" binding:"required,oneof=confirmed cancelled completed no_show"`
+			Reason string `json:"reason"`
 		}
 		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Update appointment status
-		result := db.Model(&models.Appointment{}).
-			Where("id = ? AND doctor_id = ?", appointmentID, doctor.ID).
-			Update("status", request.Status)
-
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update appointment status"})
+		var offer *models.WaitlistOffer
+		var cancelled, noShow *models.Appointment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, appointment, err := lockDoctorAppointment(tx, func(tx *gorm.DB) *gorm.DB {
+				return tx.Where("doctor_id = ?", doctor.ID)
+			}, appointmentID)
+			if err != nil {
+				return err
+			}
+			role := models.UserRole(c.GetString("userRole"))
+			switch request.Status {
+			case models.StatusCancelled:
+				// Doctors are not bound by their own cancellation policy
+				if err := appointment.Cancel(tx, userID.(uint), role, request.Reason, 0); err != nil {
+					return err
+				}
+				cancelled = appointment
+				offer, err = offerCancelledSlot(tx, appointment)
+				return err
+			case models.StatusNoShow:
+				noShow = appointment
+				return markNoShow(tx, appointment, userID.(uint), role, request.Reason)
+			}
+			return appointment.Transition(tx, request.Status, userID.(uint), role, request.Reason)
+		})
+		if errors.Is(err, errNoShowTooEarly) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		if err != nil {
+			respondTransitionError(c, err, "Failed to update appointment status")
 			return
 		}
+		if offer != nil {
+			notifyWaitlistOffers(db, m, []models.WaitlistOffer{*offer})
+		}
+		if cancelled != nil {
+			refundCancelled(c.Request.Context(), db, gateway, []*models.Appointment{cancelled})
+		}
+
+		response := gin.H{"message": "Appointment status updated successfully"}
+		if noShow != nil {
+			addBookingRestriction(db, response, noShow.PatientID)
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Appointment status updated successfully"})
+// lockAppointment loads an appointment with FOR UPDATE so that concurrent
+// status changes are applied one at a time
+func lockAppointment(tx *gorm.DB, appointmentID interface{}) (*models.Appointment, error) {
+	var appointment models.Appointment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", appointmentID).
+		First(&appointment).Error; err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// lockDoctorAppointment locks the doctor of the appointment selected by scope
+// and then the appointment itself. The doctor is locked first to keep the
+// lock order the same as in booking and schedule changes.
+func lockDoctorAppointment(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, appointmentID interface{}) (models.Doctor, *models.Appointment, error) {
+	var current models.Appointment
+	if err := scope(tx).Select("id", "doctor_id").Where("id = ?", appointmentID).First(&current).Error; err != nil {
+		return models.Doctor{}, nil, err
+	}
+	doctor, err := lockDoctor(tx, current.DoctorID)
+	if err != nil {
+		return models.Doctor{}, nil, err
+	}
+	appointment, err := lockAppointment(scope(tx), current.ID)
+	if err != nil {
+		return models.Doctor{}, nil, err
+	}
+	return doctor, appointment, nil
+}
+
+// respondTransitionError writes the HTTP response for a failed status change
+func respondTransitionError(c *gin.Context, err error, fallback string) {
+	var invalid *models.InvalidTransitionError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          invalid.Error(),
+			"current_status": invalid.From,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
 	}
 }
 
@@ -100,14 +187,6 @@ func UpdateAppointmentStatus(db *gorm.DB) gin.HandlerFunc {
 func GetDoctorAvailability(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		doctorID := c.Param("id")
-		dateStr := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
-
-		// Parse date
-		date, err := time.Parse("2006-01-02", dateStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
-			return
-		}
 
 		// Find doctor
 		var doctor models.Doctor
@@ -116,64 +195,74 @@ func GetDoctorAvailability(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Get doctor's working hours (simplified)
-		// In a real app, this would come from the doctor's schedule
-		startHour := 9
-		endHour := 17
-		duration := 30 // minutes
-
-		// Generate time slots
-		var timeSlots []string
-		currentTime := time.Date(date.Year(), date.Month(), date.Day(), startHour, 0, 0, 0, time.UTC)
-		endTime := time.Date(date.Year(), date.Month(), date.Day(), endHour, 0, 0, 0, time.UTC)
-
-		for currentTime.Before(endTime) {
-			timeSlots = append(timeSlots, currentTime.Format("15:04"))
-			currentTime = currentTime.Add(time.Duration(duration) * time.Minute)
+		// The date is a calendar date in the doctor's time zone, defaulting to
+		// the doctor's today rather than the server's
+		loc := doctor.Location()
+		dateStr := c.DefaultQuery("date", time.Now().In(loc).Format("2006-01-02"))
+		date, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
 		}
 
-		// Get booked appointments for the day
-		var bookedAppointments []models.Appointment
-		if err := db.Where("doctor_id = ? AND DATE(appointment_date) = ?", doctorID, dateStr).
-			Find(&bookedAppointments).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointments"})
+		// Slots are sized for the requested appointment type, if any
+		var typeID *uint
+		if raw := c.Query("appointment_type_id"); raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment_type_id"})
+				return
+			}
+			value := uint(id)
+			typeID = &value
+		}
+		v, err := loadVisit(db, doctor, typeID)
+		if err != nil {
+			respondSlotError(c, err, "Failed to compute availability")
 			return
 		}
 
-		// Filter out booked time slots
-		availableSlots := make([]string, 0)
-		bookedSlots := make(map[string]bool)
-		for _, appt := range bookedAppointments {
-			t, err := time.Parse("15:04:05", appt.StartTime.Format("15:04:05"))
-			if err == nil {
-				bookedSlots[t.Format("15:04")] = true
-			}
+		// Compute open slots from the doctor's published schedule
+		slots, err := computeAvailability(db, doctor, v, date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute availability"})
+			return
 		}
 
-		for _, slot := range timeSlots {
-			if !bookedSlots[slot] {
-				availableSlots = append(availableSlots, slot)
-			}
+		availableSlots := make([]string, 0, len(slots))
+		for _, slot := range slots {
+			availableSlots = append(availableSlots, slot.StartTime.Format("15:04"))
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"doctor_id":        doctorID,
-			"date":            dateStr,
-			"available_slots": availableSlots,
+			"doctor_id":           doctorID,
+			"date":                dateStr,
+			"time_zone":           loc.String(),
+			"appointment_type_id": v.TypeID,
+			"slot_duration":       int(v.Length / time.Minute),
+			"buffer_minutes":      int(v.Buffer / time.Minute),
+			"fee":                 v.Fee,
+			"available_slots":     availableSlots,
+			"slots":               slots,
 		})
 	}
 }
 
-// BookAppointment creates a new appointment
-func BookAppointment(db *gorm.DB) gin.HandlerFunc {
+// BookAppointment creates a new appointment, of the given appointment type if
+// any. Passing the ID of one of the patient's slot holds books the held slot.
+// When online payments are enabled the response includes the payment intent
+// for the appointment's fee.
+func BookAppointment(db *gorm.DB, gateway payments.PaymentGateway) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 
 		// Parse request body
 		var request struct {
-			DoctorID    uint      `json:"doctor_id" binding:"required"`
-			ScheduledAt time.Time `json:"scheduled_at" binding:"required"`
-			Notes       string    `json:"notes"`
+			DoctorID          uint      `json:"doctor_id" binding:"required_without=HoldID"`
+			ScheduledAt       time.Time `json:"scheduled_at" binding:"required_without=HoldID"`
+			HoldID            *uint     `json:"hold_id"`
+			AppointmentTypeID *uint     `json:"appointment_type_id"`
+			Notes             string    `json:"notes"`
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
@@ -181,39 +270,112 @@ func BookAppointment(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check if doctor exists
-		var doctor models.Doctor
-		if err := db.First(&doctor, request.DoctorID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
-			return
-		}
+		var appointment models.Appointment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if request.HoldID == nil {
+				var err error
+				appointment, err = bookSlot(tx, userID.(uint), request.DoctorID, request.AppointmentTypeID, request.ScheduledAt, request.Notes, nil)
+				return err
+			}
 
-		// Check if doctor is available at the requested time
-		var existingAppointment models.Appointment
-		if err := db.Where("doctor_id = ? AND appointment_date = ? AND start_time = ?", 
-			request.DoctorID, request.ScheduledAt, request.ScheduledAt).
-			First(&existingAppointment).Error; err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "Doctor is not available at the requested time"})
+			hold, err := lockPatientHold(tx, userID, *request.HoldID)
+			if err != nil {
+				return err
+			}
+			if (request.DoctorID != 0 && request.DoctorID != hold.DoctorID) ||
+				(!request.ScheduledAt.IsZero() && !request.ScheduledAt.Equal(hold.StartTime)) ||
+				(request.AppointmentTypeID != nil && !sameTypeID(request.AppointmentTypeID, hold.AppointmentTypeID)) {
+				return errHoldMismatch
+			}
+			appointment, err = bookSlot(tx, userID.(uint), hold.DoctorID, hold.AppointmentTypeID, hold.StartTime, request.Notes, nil)
+			if err != nil {
+				return err
+			}
+			return tx.Model(hold).Updates(map[string]interface{}{
+				"status":         models.HoldConverted,
+				"appointment_id": appointment.ID,
+			}).Error
+		})
+		if err != nil {
+			respondBookingError(c, err)
 			return
 		}
 
-		// Create appointment
-		appointment := models.Appointment{
-			PatientID:       userID.(uint),
-			DoctorID:        request.DoctorID,
-			AppointmentDate: request.ScheduledAt,
-			StartTime:       request.ScheduledAt,
-			EndTime:         request.ScheduledAt.Add(30 * time.Minute), // Default 30-minute appointment
-			Status:          "pending",
-			Notes:           request.Notes,
-		}
+		respondBooked(c, db, gateway, appointment)
+	}
+}
 
-		if err := db.Create(&appointment).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to book appointment"})
-			return
+// bookSlot creates a pending appointment of the given type, or of the
+// doctor's standard length when typeID is nil, for the patient at the
+// requested time after validating the slot. bookedByID records the staff
+// member who booked on the patient's behalf, if any; patients booking for
+// themselves must not have reached the no-show limit.
+func bookSlot(tx *gorm.DB, patientID, doctorID uint, typeID *uint, scheduledAt time.Time, notes string, bookedByID *uint) (models.Appointment, error) {
+	if bookedByID == nil {
+		if err := checkBookingAllowed(tx, patientID); err != nil {
+			return models.Appointment{}, err
 		}
+	}
+
+	// Lock the doctor so overlapping requests are checked one at a time
+	doctor, err := lockDoctor(tx, doctorID)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+	v, err := loadVisit(tx, doctor, typeID)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+
+	// Keep the instant but express it in the doctor's zone for the response
+	start := scheduledAt.In(doctor.Location())
+	slot := timeRange{Start: start, End: start.Add(v.Length)}
+	if err := checkSlotBookable(tx, doctor, slot, v.Buffer, 0, patientID); err != nil {
+		return models.Appointment{}, err
+	}
+
+	appointment := models.Appointment{
+		PatientID:         patientID,
+		DoctorID:          doctor.ID,
+		BookedByID:        bookedByID,
+		AppointmentTypeID: v.TypeID,
+		AppointmentDate:   slot.Start,
+		StartTime:         slot.Start,
+		EndTime:           slot.End,
+		Status:            models.StatusPending,
+		Notes:             notes,
+		BufferMinutes:     int(v.Buffer / time.Minute),
+		Fee:               v.Fee,
+	}
+	if err := applyEligibility(tx, &appointment, doctor.Location()); err != nil {
+		return models.Appointment{}, err
+	}
+	err = tx.Create(&appointment).Error
+	return appointment, err
+}
 
-		c.JSON(http.StatusCreated, appointment)
+// sameTypeID reports whether two optional appointment type IDs are equal
+func sameTypeID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// respondBookingError writes the HTTP response for an error from bookSlot
+// or from converting a slot hold
+func respondBookingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errHoldNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errHoldNotActive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errHoldMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+	default:
+		respondSlotError(c, err, "Failed to book appointment")
 	}
 }
 
@@ -253,39 +415,291 @@ func GetPatientAppointments(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Each appointment is shown in its own doctor's time zone
+		for i := range appointments {
+			appointments[i].InLocation(appointments[i].Doctor.Location())
+		}
+
 		c.JSON(http.StatusOK, appointments)
 	}
 }
 
-// CancelAppointment cancels an appointment
-func CancelAppointment(db *gorm.DB) gin.HandlerFunc {
+// CancelRequest represents a cancellation made by a patient
+type CancelRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CancelAppointment cancels one of the logged-in patient's appointments,
+// subject to the doctor's cancellation policy. Paid appointments are refunded
+// less any late cancellation fee.
+func CancelAppointment(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
-		appointmentID := c.Param("id")
 
-		// Find appointment
-		var appointment models.Appointment
-		if err := db.Where("id = ? AND patient_id = ?", appointmentID, userID).
-			First(&appointment).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
-			return
+		var req CancelRequest
+		// The body is optional
+		_ = c.ShouldBindJSON(&req)
+
+		cancelAppointment(c, db, m, gateway, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("patient_id = ?", userID)
+		}, req.Reason, true)
+	}
+}
+
+// cancelAppointment cancels the appointment selected by scope on behalf of the
+// caller and offers the freed slots to the doctor's waitlist. The series query
+// parameter extends the cancellation to later or all occurrences of a series.
+// With enforcePolicy the doctor's cancellation policy may refuse the
+// cancellation or charge a late cancellation fee, which is kept back from the
+// refund of a paid appointment.
+func cancelAppointment(c *gin.Context, db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway, scope func(*gorm.DB) *gorm.DB, reason string, enforcePolicy bool) {
+	userID, _ := c.Get("userID")
+	appointmentID := c.Param("id")
+
+	occurrences, ok := seriesScope(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "series must be one, following or all"})
+		return
+	}
+
+	var cancelled []*models.Appointment
+	var offers []models.WaitlistOffer
+	lateFees := 0.0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, appointment, err := lockDoctorAppointment(tx, scope, appointmentID)
+		if err != nil {
+			return err
+		}
+		targets, err := seriesTargets(scope(tx), appointment, occurrences)
+		if err != nil {
+			return err
 		}
 
-		// Check if appointment can be cancelled
-		if appointment.Status == models.StatusCancelled {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Appointment is already cancelled"})
+		var policy models.CancellationPolicy
+		if enforcePolicy {
+			if policy, err = loadCancellationPolicy(tx, appointment.DoctorID); err != nil {
+				return err
+			}
+			if err := checkCancellationLimit(tx, policy, appointment.PatientID, len(targets)); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		for _, target := range targets {
+			fee := 0.0
+			// Completed and other closed appointments are left to Cancel to refuse
+			if enforcePolicy && !models.IsTerminalStatus(target.Status) && policy.IsLate(target.StartTime, now) {
+				if policy.BlockLateCancellations {
+					return &lateCancellationError{Notice: policy.MinNotice()}
+				}
+				fee = policy.LateCancelFee
+			}
+			if err := target.Cancel(tx, userID.(uint), models.UserRole(c.GetString("userRole")), reason, fee); err != nil {
+				return err
+			}
+			lateFees += fee
+
+			offer, err := offerCancelledSlot(tx, target)
+			if err != nil {
+				return err
+			}
+			if offer != nil {
+				offers = append(offers, *offer)
+			}
+		}
+		cancelled = targets
+
+		if occurrences == seriesScopeAll {
+			return tx.Model(&models.AppointmentSeries{}).Where("id = ?", *appointment.SeriesID).
+				Update("status", models.SeriesCancelled).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errNotInSeries) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if respondCancellationError(c, err) {
 			return
 		}
+		respondTransitionError(c, err, "Failed to cancel appointment")
+		return
+	}
+	notifyWaitlistOffers(db, m, offers)
+	refunded := refundCancelled(c.Request.Context(), db, gateway, cancelled)
+
+	ids := make([]uint, 0, len(cancelled))
+	for _, appt := range cancelled {
+		ids = append(ids, appt.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":                "Appointment cancelled successfully",
+		"cancelled_appointments": ids,
+		"late_cancellation_fee":  lateFees,
+		"refunded_amount":        refunded,
+	})
+}
+
+var errRescheduleTooLate = errors.New("reschedule notice period has passed")
+
+// RescheduleRequest is the body accepted by the reschedule endpoints
+type RescheduleRequest struct {
+	ScheduledAt time.Time `json:"scheduled_at" binding:"required"`
+	Reason      string    `json:"reason"`
+}
+
+// rescheduleMinNotice is how long before an appointment it can still be moved
+func rescheduleMinNotice() time.Duration {
+	return config.GetDurationEnv("RESCHEDULE_MIN_NOTICE", 24*time.Hour)
+}
+
+// RescheduleAppointment moves one of the logged-in patient's appointments to a new slot
+func RescheduleAppointment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		rescheduleAppointment(c, db, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("patient_id = ?", userID)
+		}, rescheduleMinNotice())
+	}
+}
 
-		// Update status to cancelled
-		if err := db.Model(&appointment).
-			Update("status", models.StatusCancelled).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel appointment"})
+// RescheduleDoctorAppointment moves one of the logged-in doctor's appointments to a new slot
+func RescheduleDoctorAppointment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var doctor models.Doctor
+		if err := db.Where("user_id = ?", userID).First(&doctor).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Appointment cancelled successfully"})
+		rescheduleAppointment(c, db, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("doctor_id = ?", doctor.ID)
+		}, rescheduleMinNotice())
+	}
+}
+
+// rescheduleAppointment moves the appointment selected by scope to the
+// requested time, validating it with the same rules as BookAppointment. With
+// the series query parameter, later or all occurrences of its series move by
+// the same number of days to the same new wall-clock time. Both the current and
+// the new time must be at least notice away.
+func rescheduleAppointment(c *gin.Context, db *gorm.DB, scope func(*gorm.DB) *gorm.DB, notice time.Duration) {
+	appointmentID := c.Param("id")
+
+	occurrences, ok := seriesScope(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "series must be one, following or all"})
+		return
+	}
+
+	var request RescheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newStart := request.ScheduledAt.UTC()
+	if newStart.Before(time.Now().Add(notice)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New time must be at least " + notice.String() + " from now"})
+		return
 	}
+
+	var appointment *models.Appointment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		doctor, locked, err := lockDoctorAppointment(tx, scope, appointmentID)
+		if err != nil {
+			return err
+		}
+		appointment = locked
+		targets, err := seriesTargets(scope(tx), appointment, occurrences)
+		if err != nil {
+			return err
+		}
+
+		loc := doctor.Location()
+		newLocal := newStart.In(loc)
+		days := int(localDate(newLocal, loc).Sub(localDate(appointment.StartTime, loc)).Hours() / 24)
+
+		// When moving forward, move the latest occurrence first so that the
+		// series never collides with its own sessions that have yet to move
+		if newStart.After(appointment.StartTime) {
+			for i, j := 0, len(targets)-1; i < j; i, j = i+1, j-1 {
+				targets[i], targets[j] = targets[j], targets[i]
+			}
+		}
+
+		for _, target := range targets {
+			start := newLocal
+			if target != appointment {
+				t := target.StartTime.In(loc)
+				start = time.Date(t.Year(), t.Month(), t.Day()+days, newLocal.Hour(), newLocal.Minute(), 0, 0, loc)
+			}
+			if err := moveAppointment(tx, c, doctor, target, start, request.Reason, notice); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	var invalid *models.InvalidTransitionError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, appointment)
+	case errors.Is(err, errRescheduleTooLate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Appointments must be rescheduled at least " + notice.String() + " in advance"})
+	case errors.Is(err, errNotInSeries):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.As(err, &invalid):
+		respondTransitionError(c, err, "Failed to reschedule appointment")
+	default:
+		respondSlotError(c, err, "Failed to reschedule appointment")
+	}
+}
+
+// moveAppointment reschedules one locked appointment to start, keeping its
+// length and buffer, and records the move
+func moveAppointment(tx *gorm.DB, c *gin.Context, doctor models.Doctor, appointment *models.Appointment, start time.Time, reason string, notice time.Duration) error {
+	userID, _ := c.Get("userID")
+
+	if time.Until(appointment.StartTime) < notice {
+		return errRescheduleTooLate
+	}
+
+	slot := timeRange{Start: start, End: start.Add(appointment.EndTime.Sub(appointment.StartTime))}
+	buffer := time.Duration(appointment.BufferMinutes) * time.Minute
+	if err := checkSlotBookable(tx, doctor, slot, buffer, appointment.ID, appointment.PatientID); err != nil {
+		return err
+	}
+
+	reschedule := models.AppointmentReschedule{
+		AppointmentID:     appointment.ID,
+		PreviousStartTime: appointment.StartTime,
+		PreviousEndTime:   appointment.EndTime,
+		NewStartTime:      slot.Start,
+		NewEndTime:        slot.End,
+		RescheduledByID:   userID.(uint),
+		Reason:            reason,
+	}
+
+	if err := appointment.Transition(tx, models.StatusRescheduled, userID.(uint), models.UserRole(c.GetString("userRole")), reason); err != nil {
+		return err
+	}
+	if err := tx.Model(appointment).Updates(map[string]interface{}{
+		"appointment_date": slot.Start,
+		"start_time":       slot.Start,
+		"end_time":         slot.End,
+	}).Error; err != nil {
+		return err
+	}
+	appointment.AppointmentDate = slot.Start
+	appointment.StartTime = slot.Start
+	appointment.EndTime = slot.End
+
+	return tx.Create(&reschedule).Error
 }
 
 // ListAllAppointments returns a list of all appointments (admin only)
@@ -0,0 +1,205 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+)
+
+// AppointmentTypeRequest represents a kind of visit a doctor offers. Fee
+// overrides the doctor's consultation fee; leave it out to use that fee.
+type AppointmentTypeRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Description   string   `json:"description"`
+	Duration      int      `json:"duration" binding:"required,min=5,max=480"` // Minutes
+	BufferMinutes int      `json:"buffer_minutes" binding:"min=0,max=480"`    // Minutes kept free after each appointment
+	Fee           *float64 `json:"fee" binding:"omitempty,min=0"`
+}
+
+var errAppointmentTypeNotFound = errors.New("Appointment type not found")
+
+// visit describes what is being booked: the appointment type, if any, how
+// long it lasts, the buffer kept free after it and its fee
+type visit struct {
+	TypeID *uint
+	Length time.Duration
+	Buffer time.Duration
+	Fee    float64
+}
+
+// loadVisit resolves one of the doctor's appointment types. Without a type
+// the doctor's slot length and consultation fee apply, with no buffer.
+func loadVisit(db *gorm.DB, doctor models.Doctor, typeID *uint) (visit, error) {
+	if typeID == nil {
+		return visit{Length: slotDuration(doctor), Fee: doctor.ConsultationFee}, nil
+	}
+
+	var appointmentType models.AppointmentType
+	if err := db.Where("id = ? AND doctor_id = ?", *typeID, doctor.ID).First(&appointmentType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return visit{}, errAppointmentTypeNotFound
+		}
+		return visit{}, err
+	}
+	return visit{
+		TypeID: &appointmentType.ID,
+		Length: appointmentType.Length(),
+		Buffer: appointmentType.Buffer(),
+		Fee:    appointmentType.FeeFor(doctor),
+	}, nil
+}
+
+// applyAppointmentTypeRequest copies a validated request onto a type
+func applyAppointmentTypeRequest(appointmentType *models.AppointmentType, req AppointmentTypeRequest) {
+	appointmentType.Name = req.Name
+	appointmentType.Description = req.Description
+	appointmentType.Duration = req.Duration
+	appointmentType.BufferMinutes = req.BufferMinutes
+	appointmentType.Fee = req.Fee
+}
+
+// ListAppointmentTypes returns the logged-in doctor's appointment types
+func ListAppointmentTypes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		var types []models.AppointmentType
+		if err := db.Where("doctor_id = ?", doctor.ID).Order("duration ASC, name ASC").
+			Find(&types).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointment types"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": types})
+	}
+}
+
+// ListDoctorAppointmentTypes returns the appointment types a doctor offers,
+// with the fee each one is charged at
+func ListDoctorAppointmentTypes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var doctor models.Doctor
+		if err := db.First(&doctor, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+			return
+		}
+
+		var types []models.AppointmentType
+		if err := db.Where("doctor_id = ?", doctor.ID).Order("duration ASC, name ASC").
+			Find(&types).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointment types"})
+			return
+		}
+
+		// Show the fee actually charged rather than leaving it to the client
+		// to fall back to the consultation fee
+		for i := range types {
+			fee := types[i].FeeFor(doctor)
+			types[i].Fee = &fee
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"doctor_id":        doctor.ID,
+			"consultation_fee": doctor.ConsultationFee,
+			"slot_duration":    int(slotDuration(doctor) / time.Minute),
+			"data":             types,
+		})
+	}
+}
+
+// CreateAppointmentType adds an appointment type for the logged-in doctor
+func CreateAppointmentType(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		var req AppointmentTypeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		appointmentType := models.AppointmentType{DoctorID: doctor.ID}
+		applyAppointmentTypeRequest(&appointmentType, req)
+		if err := db.Create(&appointmentType).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create appointment type"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":          "Appointment type created successfully",
+			"appointment_type": appointmentType,
+		})
+	}
+}
+
+// UpdateAppointmentType replaces one of the logged-in doctor's appointment
+// types. Appointments already booked keep the length, buffer and fee they
+// were booked with.
+func UpdateAppointmentType(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		var appointmentType models.AppointmentType
+		if err := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctor.ID).First(&appointmentType).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment type not found"})
+			return
+		}
+
+		var req AppointmentTypeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		applyAppointmentTypeRequest(&appointmentType, req)
+
+		if err := db.Save(&appointmentType).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update appointment type"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Appointment type updated successfully",
+			"appointment_type": appointmentType,
+		})
+	}
+}
+
+// DeleteAppointmentType stops offering one of the logged-in doctor's
+// appointment types. Appointments already booked with it are unaffected.
+func DeleteAppointmentType(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		result := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctor.ID).Delete(&models.AppointmentType{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete appointment type"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment type not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Appointment type deleted successfully"})
+	}
+}
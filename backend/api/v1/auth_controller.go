@@ -1,10 +1,14 @@
 package v1
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
 	"github.com/sandipdas/go-doctor-booking/backend/middleware"
 	"github.com/sandipdas/go-doctor-booking/backend/models"
 	"gorm.io/gorm"
@@ -21,6 +25,8 @@ type RegisterRequest struct {
 	Experience      int     `json:"experience,omitempty"`
 	Bio             string  `json:"bio,omitempty"`
 	ConsultationFee float64 `json:"consultation_fee,omitempty"`
+	SlotDuration    int     `json:"slot_duration,omitempty" binding:"omitempty,min=5,max=240"`
+	TimeZone        string  `json:"time_zone,omitempty"` // IANA name, defaults to DEFAULT_TIME_ZONE
 }
 
 type LoginRequest struct {
@@ -28,9 +34,21 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// AllSessions revokes every token issued to the user, not just this one
+	AllSessions bool `json:"all_sessions"`
+}
+
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+	User         struct {
 		ID    uint   `json:"id"`
 		Name  string `json:"name"`
 		Email string `json:"email"`
@@ -38,8 +56,35 @@ type AuthResponse struct {
 	} `json:"user"`
 }
 
-// RegisterUser handles user registration
-func RegisterUser(db *gorm.DB) gin.HandlerFunc {
+// newAuthResponse issues an access token and a refresh token for the user
+func newAuthResponse(db *gorm.DB, user *models.User) (AuthResponse, error) {
+	token, err := middleware.GenerateToken(user)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	refreshToken, _, err := middleware.IssueRefreshToken(db, user.ID)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	return buildAuthResponse(user, token, refreshToken), nil
+}
+
+// buildAuthResponse assembles the token response returned by the auth endpoints
+func buildAuthResponse(user *models.User, token, refreshToken string) AuthResponse {
+	var resp AuthResponse
+	resp.Token = token
+	resp.RefreshToken = refreshToken
+	resp.ExpiresIn = int(middleware.AccessTokenTTL().Seconds())
+	resp.User.ID = user.ID
+	resp.User.Name = user.Name
+	resp.User.Email = user.Email
+	resp.User.Role = string(user.Role)
+	return resp
+}
+
+// RegisterUser handles user registration and emails a verification link
+func RegisterUser(db *gorm.DB, m mailer.Mailer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req RegisterRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -54,6 +99,16 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Doctors without an explicit zone get the clinic's default
+		timeZone := req.TimeZone
+		if timeZone == "" {
+			timeZone = defaultTimeZone()
+		}
+		if _, err := models.LoadTimeZone(timeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Create new user
 		user := models.User{
 			Name:     req.Name,
@@ -82,6 +137,8 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 				Experience:       req.Experience,
 				Bio:              req.Bio,
 				ConsultationFee:  req.ConsultationFee,
+				SlotDuration:     req.SlotDuration,
+				TimeZone:         timeZone,
 				Available:        true,
 			}
 
@@ -98,21 +155,18 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Generate JWT token
-		token, err := middleware.GenerateToken(user.ID, user.Email, string(user.Role))
+		// A failed email should not fail the registration; the user can ask for a new link
+		if err := sendVerificationEmail(db, m, &user); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+
+		// Generate access and refresh tokens
+		resp, err := newAuthResponse(db, &user)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 			return
 		}
 
-		// Prepare response
-		var resp AuthResponse
-		resp.Token = token
-		resp.User.ID = user.ID
-		resp.User.Name = user.Name
-		resp.User.Email = user.Email
-		resp.User.Role = string(user.Role)
-
 		c.JSON(http.StatusCreated, resp)
 	}
 }
@@ -126,28 +180,47 @@ func LoginUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Find user by email
-		var user models.User
-		if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-			log.Printf("User not found with email: %s, error: %v", req.Email, err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		accountKey := accountThrottleKey(req.Email)
+		ipKey := ipThrottleKey(c.ClientIP())
+
+		// Reject locked clients and accounts before checking the password
+		if wait, err := loginLockedFor(db, ipKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+			return
+		} else if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+			return
+		}
+		if wait, err := loginLockedFor(db, accountKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+			return
+		} else if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked, try again later"})
 			return
 		}
 
-		log.Printf("Found user: ID=%d, Email=%s, Active=%v", user.ID, user.Email, user.Active)
-
-		// Check password
-		log.Printf("Checking password for user: %s", user.Email)
-		log.Printf("Stored password hash: %s", user.Password)
-		log.Printf("Provided password: %s", req.Password)
-
-		if err := user.CheckPassword(req.Password); err != nil {
-			log.Printf("Password check failed: %v", err)
+		// Find user by email and check password
+		var user models.User
+		err := db.Where("email = ?", req.Email).First(&user).Error
+		if err == nil {
+			err = user.CheckPassword(req.Password)
+		}
+		if err != nil {
+			if err := recordLoginFailure(db, accountKey, accountThrottlePolicy()); err != nil {
+				log.Printf("Failed to record login failure: %v", err)
+			}
+			if err := recordLoginFailure(db, ipKey, ipThrottlePolicy()); err != nil {
+				log.Printf("Failed to record login failure: %v", err)
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
 
-		log.Printf("Password check successful for user: %s", user.Email)
+		if err := clearLoginFailures(db, accountKey); err != nil {
+			log.Printf("Failed to clear login failures for user %d: %v", user.ID, err)
+		}
 
 		// Check if user is active
 		if !user.Active {
@@ -155,21 +228,135 @@ func LoginUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Generate JWT token
-		token, err := middleware.GenerateToken(user.ID, user.Email, string(user.Role))
+		if !user.EmailVerified && config.GetEnv("REQUIRE_EMAIL_VERIFICATION", "false") == "true" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address has not been verified"})
+			return
+		}
+
+		respondLoggedIn(c, db, &user)
+	}
+}
+
+// respondLoggedIn finishes a successful sign-in: accounts with two-factor
+// authentication get a challenge, accounts whose role requires it but have not
+// enrolled get an enrollment token, and everyone else gets their tokens
+func respondLoggedIn(c *gin.Context, db *gorm.DB, user *models.User) {
+	if user.TOTPEnabled {
+		challenge, err := middleware.GenerateChallengeToken(user, middleware.PurposeTwoFactorLogin, twoFactorLoginTTL)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
 
-		// Prepare response
-		var resp AuthResponse
-		resp.Token = token
-		resp.User.ID = user.ID
-		resp.User.Name = user.Name
-		resp.User.Email = user.Email
-		resp.User.Role = string(user.Role)
+	policy, err := loadSecurityPolicy(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load security policy"})
+		return
+	}
+	if policy.RequiresTwoFactor(user.Role) {
+		enrollment, err := middleware.GenerateChallengeToken(user, middleware.PurposeTwoFactorEnroll, twoFactorEnrollTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            "Two-factor authentication enrollment required",
+			"enrollment_token": enrollment,
+		})
+		return
+	}
+
+	// Generate access and refresh tokens
+	resp, err := newAuthResponse(db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token pair
+func RefreshToken(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, refreshToken, err := middleware.RotateRefreshToken(db, req.RefreshToken)
+		if errors.Is(err, middleware.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+			return
+		}
+
+		token, err := middleware.GenerateToken(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, buildAuthResponse(user, token, refreshToken))
+	}
+}
+
+// LogoutUser revokes the caller's access token and refresh token
+func LogoutUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req LogoutRequest
+		// The body is optional
+		_ = c.ShouldBindJSON(&req)
+
+		if req.AllSessions {
+			if err := middleware.RevokeAllTokens(db, userID.(uint)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+			return
+		}
+
+		if claims, ok := c.Get("tokenClaims"); ok {
+			if err := middleware.RevokeAccessToken(db, claims.(*middleware.Claims)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+				return
+			}
+		}
+		if req.RefreshToken != "" {
+			if err := middleware.RevokeRefreshToken(db, userID.(uint), req.RefreshToken); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
+
+// GetJWKS publishes the public keys used to sign access tokens so that other
+// services can verify them. The set is empty when tokens are signed with HS256.
+func GetJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ks := middleware.CurrentKeySet()
+		if ks == nil {
+			c.JSON(http.StatusOK, gin.H{"keys": []middleware.JWK{}})
+			return
+		}
 
-		c.JSON(http.StatusOK, resp)
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, ks.JWKS())
 	}
 }
@@ -0,0 +1,418 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultSlotDuration is used when a doctor has not configured a slot length
+const defaultSlotDuration = 30 * time.Minute
+
+// timeRange is a half-open [Start, End) interval
+type timeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// overlaps reports whether two ranges share any instant
+func (r timeRange) overlaps(other timeRange) bool {
+	return r.Start.Before(other.End) && other.Start.Before(r.End)
+}
+
+// contains reports whether other lies entirely within r
+func (r timeRange) contains(other timeRange) bool {
+	return !other.Start.Before(r.Start) && !other.End.After(r.End)
+}
+
+// slotDuration returns the configured slot length for a doctor
+func slotDuration(doctor models.Doctor) time.Duration {
+	if doctor.SlotDuration <= 0 {
+		return defaultSlotDuration
+	}
+	return time.Duration(doctor.SlotDuration) * time.Minute
+}
+
+// clockRange converts "15:04" wall-clock start and end times into a range on
+// the calendar date of date in loc. time.Date resolves DST transitions, so a
+// window spanning a change is an hour shorter or longer in real time.
+func clockRange(date time.Time, startTime, endTime string, loc *time.Location) (timeRange, error) {
+	start, err := time.Parse("15:04", startTime)
+	if err != nil {
+		return timeRange{}, fmt.Errorf("invalid start time %q: %w", startTime, err)
+	}
+	end, err := time.Parse("15:04", endTime)
+	if err != nil {
+		return timeRange{}, fmt.Errorf("invalid end time %q: %w", endTime, err)
+	}
+
+	return timeRange{
+		Start: time.Date(date.Year(), date.Month(), date.Day(), start.Hour(), start.Minute(), 0, 0, loc),
+		End:   time.Date(date.Year(), date.Month(), date.Day(), end.Hour(), end.Minute(), 0, 0, loc),
+	}, nil
+}
+
+// scheduleRange converts a schedule row's "15:04" times into a range on its date in loc
+func scheduleRange(schedule models.Schedule, loc *time.Location) (timeRange, error) {
+	return clockRange(schedule.Date.UTC(), schedule.StartTime, schedule.EndTime, loc)
+}
+
+// splitSchedules separates published working windows from blocked-out windows
+func splitSchedules(schedules []models.Schedule, loc *time.Location) (open []timeRange, blocked []timeRange, err error) {
+	for _, s := range schedules {
+		r, err := scheduleRange(s, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if s.IsAvailable {
+			open = append(open, r)
+		} else {
+			blocked = append(blocked, r)
+		}
+	}
+	return open, blocked, nil
+}
+
+// generateSlots steps through each open window every step and offers a slot
+// of the given length wherever it fits inside the window. A slot is dropped
+// if it, or the buffer that follows it, overlaps a busy range.
+func generateSlots(open []timeRange, busy []timeRange, step, length, buffer time.Duration) []models.TimeSlot {
+	sort.Slice(open, func(i, j int) bool { return open[i].Start.Before(open[j].Start) })
+
+	slots := make([]models.TimeSlot, 0)
+	seen := make(map[time.Time]bool)
+	for _, window := range open {
+		for start := window.Start; !start.Add(length).After(window.End); start = start.Add(step) {
+			slot := timeRange{Start: start, End: start.Add(length)}
+			occupied := timeRange{Start: start, End: slot.End.Add(buffer)}
+			if seen[slot.Start] || overlapsAny(occupied, busy) {
+				continue
+			}
+			seen[slot.Start] = true
+			slots = append(slots, models.TimeSlot{StartTime: slot.Start, EndTime: slot.End})
+		}
+	}
+	return slots
+}
+
+// overlapsAny reports whether r overlaps any of the given ranges
+func overlapsAny(r timeRange, ranges []timeRange) bool {
+	for _, other := range ranges {
+		if r.overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// dayRange returns the range covering the calendar date of date in loc. On
+// DST transition days it is 23 or 25 hours long.
+func dayRange(date time.Time, loc *time.Location) timeRange {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	return timeRange{Start: start, End: time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, loc)}
+}
+
+// localDate returns the calendar date of t in loc as midnight UTC, the form
+// in which schedule, template and exception dates are stored
+func localDate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// onDate restricts a query to rows whose date column holds the given calendar
+// date. Comparing against the stored midnight-UTC instants rather than using
+// DATE() keeps the result independent of the database session's time zone.
+func onDate(db *gorm.DB, date time.Time) *gorm.DB {
+	day := dayRange(date, time.UTC)
+	return db.Where("date >= ? AND date < ?", day.Start, day.End)
+}
+
+// inDateRange restricts a query to rows whose date column falls between the
+// optional from and to dates ("2006-01-02"), inclusive, comparing instants in
+// the same way as onDate
+func inDateRange(db *gorm.DB, from, to string) (*gorm.DB, error) {
+	if from != "" {
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("date >= ?", dayRange(date, time.UTC).Start)
+	}
+	if to != "" {
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("date < ?", dayRange(date, time.UTC).End)
+	}
+	return db, nil
+}
+
+// loadSchedules returns the doctor's schedule rows for a calendar date
+func loadSchedules(db *gorm.DB, doctorID uint, date time.Time) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	err := onDate(db.Where("doctor_id = ?", doctorID), date).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// recurringWindows expands the templates that occur on date into open windows
+// and turns the exceptions into blocked windows, all in loc
+func recurringWindows(templates []models.ScheduleTemplate, exceptions []models.ScheduleException, date time.Time, loc *time.Location) (open []timeRange, blocked []timeRange, err error) {
+	for i := range templates {
+		if !templates[i].OccursOn(date) {
+			continue
+		}
+		r, err := clockRange(date, templates[i].StartTime, templates[i].EndTime, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		open = append(open, r)
+	}
+
+	for i := range exceptions {
+		if exceptions[i].FullDay() {
+			blocked = append(blocked, dayRange(date, loc))
+			continue
+		}
+		r, err := clockRange(date, exceptions[i].StartTime, exceptions[i].EndTime, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		blocked = append(blocked, r)
+	}
+	return open, blocked, nil
+}
+
+// loadWindows returns the doctor's open and blocked windows for a calendar
+// date in the doctor's time zone, combining one-off schedule rows, recurring
+// templates and exceptions
+func loadWindows(db *gorm.DB, doctor models.Doctor, date time.Time) (open []timeRange, blocked []timeRange, err error) {
+	loc := doctor.Location()
+	schedules, err := loadSchedules(db, doctor.ID, date)
+	if err != nil {
+		return nil, nil, err
+	}
+	open, blocked, err = splitSchedules(schedules, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	day := dayRange(date, time.UTC)
+	var templates []models.ScheduleTemplate
+	if err := db.Where("doctor_id = ? AND effective_from < ? AND (effective_until IS NULL OR effective_until >= ?)",
+		doctor.ID, day.End, day.Start).Find(&templates).Error; err != nil {
+		return nil, nil, err
+	}
+	var exceptions []models.ScheduleException
+	if err := onDate(db.Where("doctor_id = ?", doctor.ID), date).
+		Find(&exceptions).Error; err != nil {
+		return nil, nil, err
+	}
+
+	recurringOpen, recurringBlocked, err := recurringWindows(templates, exceptions, date, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(open, recurringOpen...), append(blocked, recurringBlocked...), nil
+}
+
+// busyUntilSQL is the end of an appointment's buffer, matching Appointment.BusyUntil
+const busyUntilSQL = "end_time + buffer_minutes * INTERVAL '1 minute'"
+
+// loadBusyRanges returns the time ranges, buffers included, of the doctor's
+// non-cancelled appointments that overlap the given range
+func loadBusyRanges(db *gorm.DB, doctorID uint, within timeRange) ([]timeRange, error) {
+	var appointments []models.Appointment
+	if err := db.Where("doctor_id = ? AND status <> ? AND start_time < ? AND "+busyUntilSQL+" > ?",
+		doctorID, models.StatusCancelled, within.End, within.Start).
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	busy := make([]timeRange, 0, len(appointments))
+	for _, appt := range appointments {
+		busy = append(busy, timeRange{Start: appt.StartTime, End: appt.BusyUntil()})
+	}
+	return busy, nil
+}
+
+// loadHeldRanges returns the time ranges of slots held by active checkout
+// holds or pending waitlist offers that overlap the given range. Holds
+// belonging to holderID are skipped so that a patient can book the slot held
+// for them.
+func loadHeldRanges(db *gorm.DB, doctorID uint, within timeRange, holderID uint) ([]timeRange, error) {
+	scope := func(status string) *gorm.DB {
+		query := db.Where("doctor_id = ? AND status = ? AND expires_at > ? AND start_time < ? AND end_time > ?",
+			doctorID, status, time.Now(), within.End, within.Start)
+		if holderID != 0 {
+			query = query.Where("patient_id <> ?", holderID)
+		}
+		return query
+	}
+
+	var holds []models.SlotHold
+	if err := scope(models.HoldActive).Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	var offers []models.WaitlistOffer
+	if err := scope(models.OfferPending).Find(&offers).Error; err != nil {
+		return nil, err
+	}
+
+	held := make([]timeRange, 0, len(holds)+len(offers))
+	for _, hold := range holds {
+		held = append(held, timeRange{Start: hold.StartTime, End: hold.EndTime})
+	}
+	for _, offer := range offers {
+		held = append(held, timeRange{Start: offer.StartTime, End: offer.EndTime})
+	}
+	return held, nil
+}
+
+// computeAvailability returns the open slots for an appointment type with a
+// doctor on a calendar date in the doctor's time zone. Slots start on the
+// doctor's slot grid and carry that zone's offset. Days without a published
+// schedule or recurring template have no slots.
+func computeAvailability(db *gorm.DB, doctor models.Doctor, v visit, date time.Time) ([]models.TimeSlot, error) {
+	open, blocked, err := loadWindows(db, doctor, date)
+	if err != nil {
+		return nil, err
+	}
+	if len(open) == 0 {
+		return []models.TimeSlot{}, nil
+	}
+
+	day := dayRange(date, doctor.Location())
+	busy, err := loadBusyRanges(db, doctor.ID, day)
+	if err != nil {
+		return nil, err
+	}
+	held, err := loadHeldRanges(db, doctor.ID, day, 0)
+	if err != nil {
+		return nil, err
+	}
+	busy = append(busy, held...)
+
+	return generateSlots(open, append(blocked, busy...), slotDuration(doctor), v.Length, v.Buffer), nil
+}
+
+var (
+	errSlotInPast      = errors.New("Appointment time must be in the future")
+	errOutsideSchedule = errors.New("Requested time is outside the doctor's published schedule")
+	errSlotHeld        = errors.New("Requested time is being held for another patient")
+)
+
+// slotConflictError reports an existing appointment overlapping a requested slot
+type slotConflictError struct {
+	Conflict models.Appointment
+}
+
+func (e *slotConflictError) Error() string {
+	return "Doctor is not available at the requested time"
+}
+
+// lockDoctor loads the doctor row with FOR UPDATE so that concurrent bookings
+// for the same doctor are serialized for the rest of the transaction
+func lockDoctor(tx *gorm.DB, doctorID uint) (models.Doctor, error) {
+	var doctor models.Doctor
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doctor, doctorID).Error
+	return doctor, err
+}
+
+// checkSlotBookable validates that slot is in the future, falls inside one of
+// the doctor's published schedule windows and, together with the buffer that
+// follows it, does not overlap a blocked window, another non-cancelled
+// appointment or a slot held for someone other than holderID. excludeID skips
+// an existing appointment, which lets a reschedule move within its own time
+// range.
+func checkSlotBookable(tx *gorm.DB, doctor models.Doctor, slot timeRange, buffer time.Duration, excludeID, holderID uint) error {
+	if !slot.Start.After(time.Now()) {
+		return errSlotInPast
+	}
+
+	open, blocked, err := loadWindows(tx, doctor, localDate(slot.Start, doctor.Location()))
+	if err != nil {
+		return err
+	}
+
+	inWindow := false
+	for _, window := range open {
+		if window.contains(slot) {
+			inWindow = true
+			break
+		}
+	}
+	occupied := timeRange{Start: slot.Start, End: slot.End.Add(buffer)}
+	if !inWindow || overlapsAny(occupied, blocked) {
+		return errOutsideSchedule
+	}
+
+	query := tx.Where("doctor_id = ? AND status <> ? AND start_time < ? AND "+busyUntilSQL+" > ?",
+		doctor.ID, models.StatusCancelled, occupied.End, occupied.Start)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+
+	var conflict models.Appointment
+	err = query.Order("start_time ASC").First(&conflict).Error
+	if err == nil {
+		return &slotConflictError{Conflict: conflict}
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	held, err := loadHeldRanges(tx, doctor.ID, occupied, holderID)
+	if err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		return errSlotHeld
+	}
+	return nil
+}
+
+// slotUnavailable reports whether err from checkSlotBookable means the slot
+// cannot be booked, as opposed to a database failure
+func slotUnavailable(err error) bool {
+	var conflict *slotConflictError
+	return errors.As(err, &conflict) || errors.Is(err, errSlotInPast) ||
+		errors.Is(err, errOutsideSchedule) || errors.Is(err, errSlotHeld)
+}
+
+// respondSlotError writes the HTTP response for an error from checkSlotBookable,
+// loadVisit or checkBookingAllowed, falling back to a 500 with the given
+// message for anything else
+func respondSlotError(c *gin.Context, err error, fallback string) {
+	var conflict *slotConflictError
+	var restricted *bookingRestrictedError
+	switch {
+	case errors.As(err, &conflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": conflict.Error(),
+			"conflicting_slot": models.TimeSlot{
+				StartTime: conflict.Conflict.StartTime,
+				EndTime:   conflict.Conflict.EndTime,
+			},
+		})
+	case errors.Is(err, errSlotInPast), errors.Is(err, errOutsideSchedule), errors.Is(err, errAppointmentTypeNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errSlotHeld):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &restricted):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            restricted.Error(),
+			"restricted_until": restricted.Until,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,174 @@
+package v1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2030, time.January, 7, hour, minute, 0, 0, time.UTC)
+}
+
+func TestGenerateSlots(t *testing.T) {
+	testCases := []struct {
+		name     string
+		open     []timeRange
+		busy     []timeRange
+		duration time.Duration
+		expected []string
+	}{
+		{
+			name:     "No schedule",
+			duration: 30 * time.Minute,
+			expected: []string{},
+		},
+		{
+			name:     "Single window",
+			open:     []timeRange{{Start: at(9, 0), End: at(10, 30)}},
+			duration: 30 * time.Minute,
+			expected: []string{"09:00", "09:30", "10:00"},
+		},
+		{
+			name:     "Partial slot at window end is dropped",
+			open:     []timeRange{{Start: at(9, 0), End: at(10, 15)}},
+			duration: 30 * time.Minute,
+			expected: []string{"09:00", "09:30"},
+		},
+		{
+			name:     "Blocked window and overlapping appointment",
+			open:     []timeRange{{Start: at(9, 0), End: at(12, 0)}},
+			busy:     []timeRange{{Start: at(9, 15), End: at(9, 45)}, {Start: at(11, 0), End: at(12, 0)}},
+			duration: 30 * time.Minute,
+			expected: []string{"10:00", "10:30"},
+		},
+		{
+			name:     "Custom slot length across windows",
+			open:     []timeRange{{Start: at(14, 0), End: at(15, 0)}, {Start: at(9, 0), End: at(10, 0)}},
+			duration: 20 * time.Minute,
+			expected: []string{"09:00", "09:20", "09:40", "14:00", "14:20", "14:40"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			slots := generateSlots(tc.open, tc.busy, tc.duration, tc.duration, 0)
+
+			starts := make([]string, 0, len(slots))
+			for _, slot := range slots {
+				starts = append(starts, slot.StartTime.Format("15:04"))
+				assert.Equal(t, tc.duration, slot.EndTime.Sub(slot.StartTime))
+			}
+			assert.Equal(t, tc.expected, starts)
+		})
+	}
+}
+
+func TestGenerateSlotsForAppointmentType(t *testing.T) {
+	open := []timeRange{{Start: at(9, 0), End: at(12, 0)}}
+	busy := []timeRange{{Start: at(10, 30), End: at(11, 0)}}
+
+	starts := func(slots []models.TimeSlot) []string {
+		result := make([]string, 0, len(slots))
+		for _, slot := range slots {
+			result = append(result, slot.StartTime.Format("15:04"))
+		}
+		return result
+	}
+
+	// A 60-minute procedure on a 30-minute grid is only offered where it fits
+	slots := generateSlots(open, busy, 30*time.Minute, time.Hour, 0)
+	assert.Equal(t, []string{"09:00", "09:30", "11:00"}, starts(slots))
+	assert.Equal(t, at(10, 0), slots[0].EndTime)
+
+	// The buffer after each slot must also stay clear of busy ranges, but may
+	// run past the end of the window
+	slots = generateSlots(open, busy, 30*time.Minute, time.Hour, 15*time.Minute)
+	assert.Equal(t, []string{"09:00", "11:00"}, starts(slots))
+}
+
+func TestRecurringWindows(t *testing.T) {
+	// 2030-01-07 is a Monday
+	monday := at(0, 0)
+	templates := []models.ScheduleTemplate{
+		{Weekdays: "MO,WE", Interval: 1, StartTime: "09:00", EndTime: "12:00", EffectiveFrom: monday.AddDate(0, 0, -7)},
+		{Weekdays: "TU", Interval: 1, StartTime: "14:00", EndTime: "16:00", EffectiveFrom: monday.AddDate(0, 0, -7)},
+	}
+
+	open, blocked, err := recurringWindows(templates, nil, monday, time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, []timeRange{{Start: at(9, 0), End: at(12, 0)}}, open)
+	assert.Empty(t, blocked)
+
+	// A partial exception blocks part of the day, a full-day one all of it
+	exceptions := []models.ScheduleException{{StartTime: "10:00", EndTime: "11:00"}}
+	_, blocked, err = recurringWindows(templates, exceptions, monday, time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, []timeRange{{Start: at(10, 0), End: at(11, 0)}}, blocked)
+
+	exceptions = []models.ScheduleException{{Reason: "Holiday"}}
+	open, blocked, err = recurringWindows(templates, exceptions, monday, time.UTC)
+	assert.NoError(t, err)
+	slots := generateSlots(open, blocked, 30*time.Minute, 30*time.Minute, 0)
+	assert.Empty(t, slots)
+}
+
+func TestClockRangeAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		date     time.Time
+		expected []string
+		hours    time.Duration
+		dayHours time.Duration
+	}{
+		{
+			// Clocks jump from 02:00 to 03:00, so the window is five hours long
+			name:     "spring forward",
+			date:     time.Date(2030, 3, 10, 0, 0, 0, 0, time.UTC),
+			expected: []string{"00:00-05:00", "01:00-05:00", "03:00-04:00", "04:00-04:00", "05:00-04:00"},
+			hours:    5,
+			dayHours: 23,
+		},
+		{
+			// 01:00 happens twice, once in each offset
+			name:     "fall back",
+			date:     time.Date(2030, 11, 3, 0, 0, 0, 0, time.UTC),
+			expected: []string{"00:00-04:00", "01:00-04:00", "01:00-05:00", "02:00-05:00", "03:00-05:00", "04:00-05:00", "05:00-05:00"},
+			hours:    7,
+			dayHours: 25,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			window, err := clockRange(tc.date, "00:00", "06:00", loc)
+			require.NoError(t, err)
+			assert.Equal(t, tc.hours*time.Hour, window.End.Sub(window.Start))
+			day := dayRange(tc.date, loc)
+			assert.Equal(t, tc.dayHours*time.Hour, day.End.Sub(day.Start))
+
+			slots := generateSlots([]timeRange{window}, nil, time.Hour, time.Hour, 0)
+			starts := make([]string, 0, len(slots))
+			for _, slot := range slots {
+				starts = append(starts, slot.StartTime.Format("15:04-07:00"))
+			}
+			assert.Equal(t, tc.expected, starts)
+		})
+	}
+}
+
+func TestLocalDate(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// 20:00 UTC on the 9th is already the 10th in Tokyo
+	instant := time.Date(2030, 1, 9, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2030, 1, 10, 0, 0, 0, 0, time.UTC), localDate(instant, tokyo))
+	assert.Equal(t, time.Date(2030, 1, 9, 0, 0, 0, 0, time.UTC), localDate(instant, time.UTC))
+}
@@ -0,0 +1,277 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+)
+
+// CancellationPolicyRequest replaces a doctor's cancellation policy
+type CancellationPolicyRequest struct {
+	MinNoticeHours         int     `json:"min_notice_hours" binding:"min=0,max=720"`
+	LateCancelFee          float64 `json:"late_cancel_fee" binding:"min=0"`
+	BlockLateCancellations bool    `json:"block_late_cancellations"`
+	MaxCancellations       int     `json:"max_cancellations" binding:"min=0"` // 0 means unlimited
+	PeriodDays             int     `json:"period_days" binding:"omitempty,min=1,max=365"`
+}
+
+// NoShowRequest marks an appointment as a no-show
+type NoShowRequest struct {
+	Reason string `json:"reason"`
+}
+
+var (
+	errCancellationLimit = errors.New("Cancellation limit reached for this doctor, please contact the clinic")
+	errNoShowTooEarly    = errors.New("An appointment can only be marked as a no-show once it has started")
+)
+
+// lateCancellationError is returned when a policy refuses a late cancellation
+type lateCancellationError struct {
+	Notice time.Duration
+}
+
+func (e *lateCancellationError) Error() string {
+	return "Appointments must be cancelled at least " + e.Notice.String() + " in advance"
+}
+
+// bookingRestrictedError is returned when a patient with too many recent
+// no-shows tries to book online
+type bookingRestrictedError struct {
+	Until time.Time
+}
+
+func (e *bookingRestrictedError) Error() string {
+	return "Online booking is suspended after repeated no-shows, please contact the clinic"
+}
+
+// noShowLimit is how many no-shows within noShowWindow suspend a patient's
+// online booking. Zero disables the restriction.
+func noShowLimit() int {
+	return config.GetIntEnv("NO_SHOW_LIMIT", 3)
+}
+
+// noShowWindow is how long a no-show counts towards noShowLimit
+func noShowWindow() time.Duration {
+	return config.GetDurationEnv("NO_SHOW_WINDOW", 90*24*time.Hour)
+}
+
+// checkBookingAllowed returns a *bookingRestrictedError if the patient has
+// reached the no-show limit. The restriction lifts by itself once enough of
+// the no-shows are older than the window.
+func checkBookingAllowed(tx *gorm.DB, patientID uint) error {
+	limit := noShowLimit()
+	if limit <= 0 {
+		return nil
+	}
+
+	window := noShowWindow()
+	var noShows []models.Appointment
+	if err := tx.Where("patient_id = ? AND status = ? AND start_time > ?", patientID, models.StatusNoShow, time.Now().Add(-window)).
+		Order("start_time DESC").
+		Limit(limit).
+		Find(&noShows).Error; err != nil {
+		return err
+	}
+	if len(noShows) < limit {
+		return nil
+	}
+	return &bookingRestrictedError{Until: noShows[limit-1].StartTime.Add(window)}
+}
+
+// loadCancellationPolicy returns the doctor's cancellation policy, or a policy
+// allowing any cancellation free of charge if the doctor has none
+func loadCancellationPolicy(db *gorm.DB, doctorID uint) (models.CancellationPolicy, error) {
+	policy := models.CancellationPolicy{DoctorID: doctorID, PeriodDays: models.DefaultCancellationPeriodDays}
+	err := db.Where("doctor_id = ?", doctorID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return policy, nil
+	}
+	return policy, err
+}
+
+// checkCancellationLimit returns errCancellationLimit if cancelling count
+// more of the patient's appointments with the policy's doctor would exceed
+// the policy's limit for the current period
+func checkCancellationLimit(tx *gorm.DB, policy models.CancellationPolicy, patientID uint, count int) error {
+	if policy.MaxCancellations <= 0 {
+		return nil
+	}
+
+	var recent int64
+	if err := tx.Model(&models.Appointment{}).
+		Where("patient_id = ? AND doctor_id = ? AND status = ? AND cancelled_by_id = ? AND cancelled_at > ?",
+			patientID, policy.DoctorID, models.StatusCancelled, patientID, policy.PeriodStart(time.Now())).
+		Count(&recent).Error; err != nil {
+		return err
+	}
+	if int(recent)+count > policy.MaxCancellations {
+		return errCancellationLimit
+	}
+	return nil
+}
+
+// GetCancellationPolicy returns the logged-in doctor's cancellation policy
+func GetCancellationPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		policy, err := loadCancellationPolicy(db, doctor.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cancellation policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// GetDoctorCancellationPolicy returns a doctor's cancellation policy so that
+// patients can see it before booking
+func GetDoctorCancellationPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var doctor models.Doctor
+		if err := db.First(&doctor, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+			return
+		}
+
+		policy, err := loadCancellationPolicy(db, doctor.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cancellation policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// UpdateCancellationPolicy replaces the logged-in doctor's cancellation
+// policy. It applies to cancellations made from then on.
+func UpdateCancellationPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		var req CancellationPolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		policy, err := loadCancellationPolicy(db, doctor.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cancellation policy"})
+			return
+		}
+		policy.MinNoticeHours = req.MinNoticeHours
+		policy.LateCancelFee = req.LateCancelFee
+		policy.BlockLateCancellations = req.BlockLateCancellations
+		policy.MaxCancellations = req.MaxCancellations
+		policy.PeriodDays = req.PeriodDays
+		if policy.PeriodDays == 0 {
+			policy.PeriodDays = models.DefaultCancellationPeriodDays
+		}
+
+		// Save also writes false and zero values, which Create would replace
+		// with column defaults
+		if err := db.Save(&policy).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cancellation policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Cancellation policy updated successfully",
+			"policy":  policy,
+		})
+	}
+}
+
+// MarkNoShow records that the patient did not attend one of the logged-in
+// doctor's confirmed appointments. Patients who reach the no-show limit can
+// no longer book online until their no-shows age out.
+func MarkNoShow(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var doctor models.Doctor
+		if err := db.Where("user_id = ?", userID).First(&doctor).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+			return
+		}
+
+		var req NoShowRequest
+		// The body is optional
+		_ = c.ShouldBindJSON(&req)
+
+		var appointment *models.Appointment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			appointment, err = lockAppointment(tx.Where("doctor_id = ?", doctor.ID), c.Param("id"))
+			if err != nil {
+				return err
+			}
+			return markNoShow(tx, appointment, userID.(uint), models.UserRole(c.GetString("userRole")), req.Reason)
+		})
+		if errors.Is(err, errNoShowTooEarly) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			respondTransitionError(c, err, "Failed to mark appointment as a no-show")
+			return
+		}
+
+		response := gin.H{
+			"message":     "Appointment marked as a no-show",
+			"appointment": appointment,
+		}
+		addBookingRestriction(db, response, appointment.PatientID)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// markNoShow moves an appointment that has already started to no_show
+func markNoShow(tx *gorm.DB, appointment *models.Appointment, userID uint, role models.UserRole, reason string) error {
+	if appointment.StartTime.After(time.Now()) {
+		return errNoShowTooEarly
+	}
+	return appointment.Transition(tx, models.StatusNoShow, userID, role, reason)
+}
+
+// addBookingRestriction reports in response whether the patient's no-shows
+// have suspended their online booking
+func addBookingRestriction(db *gorm.DB, response gin.H, patientID uint) {
+	response["booking_restricted"] = false
+	var restricted *bookingRestrictedError
+	if err := checkBookingAllowed(db, patientID); errors.As(err, &restricted) {
+		response["booking_restricted"] = true
+		response["restricted_until"] = restricted.Until
+	}
+}
+
+// respondCancellationError writes the HTTP response for a cancellation refused
+// by the doctor's policy, reporting whether err was one
+func respondCancellationError(c *gin.Context, err error) bool {
+	var late *lateCancellationError
+	switch {
+	case errors.As(err, &late):
+		c.JSON(http.StatusBadRequest, gin.H{"error": late.Error()})
+	case errors.Is(err, errCancellationLimit):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
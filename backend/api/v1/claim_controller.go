@@ -0,0 +1,286 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClaimRequest submits a claim for a completed appointment
+type ClaimRequest struct {
+	AppointmentID  uint   `json:"appointment_id" binding:"required"`
+	PayerReference string `json:"payer_reference" binding:"max=100"`
+}
+
+// ClaimStatusRequest moves a claim to a new status. ApprovedAmount defaults
+// to the billed amount and PaidAmount to the approved amount; Reason is
+// required when denying.
+type ClaimStatusRequest struct {
+	Status         string   `json:"status" binding:"required,oneof=submitted approved denied paid"`
+	ApprovedAmount *float64 `json:"approved_amount" binding:"omitempty,gt=0"`
+	PaidAmount     *float64 `json:"paid_amount" binding:"omitempty,gt=0"`
+	PayerReference string   `json:"payer_reference" binding:"max=100"`
+	Reason         string   `json:"reason"`
+}
+
+var (
+	errClaimNotCompleted = errors.New("Claims can only be made for completed appointments")
+	errClaimNotCovered   = errors.New("Appointment is not covered by an insurance policy")
+	errClaimExists       = errors.New("A claim has already been made for this appointment")
+	errClaimAmount       = errors.New("Amount must not exceed the billed or approved amount")
+	errDenialReason      = errors.New("reason is required when denying a claim")
+)
+
+// respondClaimError writes the HTTP response for an error from creating or
+// updating a claim
+func respondClaimError(c *gin.Context, err error, fallback string) {
+	var invalid *models.InvalidClaimTransitionError
+	switch {
+	case errors.Is(err, errClaimExists), errors.As(err, &invalid):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errClaimNotCompleted), errors.Is(err, errClaimNotCovered),
+		errors.Is(err, errClaimAmount), errors.Is(err, errDenialReason):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// loadClaim returns a claim with its policy, including a since deleted one,
+// and its status history
+func loadClaim(db *gorm.DB, claimID interface{}) (*models.Claim, error) {
+	var claim models.Claim
+	err := db.Preload("Policy", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+		Preload("History", func(tx *gorm.DB) *gorm.DB { return tx.Order("changed_at ASC, id ASC") }).
+		First(&claim, claimID).Error
+	return &claim, err
+}
+
+// CreateClaim submits a claim to the payer of a completed appointment's
+// policy for the part of the fee the co-pay does not cover (admin only)
+func CreateClaim(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req ClaimRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var claim models.Claim
+		err := db.Transaction(func(tx *gorm.DB) error {
+			appointment, err := lockAppointment(tx, req.AppointmentID)
+			if err != nil {
+				return err
+			}
+			if appointment.Status != models.StatusCompleted {
+				return errClaimNotCompleted
+			}
+			if appointment.InsurancePolicyID == nil || appointment.InsuredAmount() <= 0 {
+				return errClaimNotCovered
+			}
+			var existing int64
+			if err := tx.Model(&models.Claim{}).Where("appointment_id = ?", appointment.ID).Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				return errClaimExists
+			}
+
+			now := time.Now()
+			claim = models.Claim{
+				AppointmentID:  appointment.ID,
+				PolicyID:       *appointment.InsurancePolicyID,
+				PatientID:      appointment.PatientID,
+				DoctorID:       appointment.DoctorID,
+				PayerReference: req.PayerReference,
+				BilledAmount:   appointment.InsuredAmount(),
+				Status:         models.ClaimSubmitted,
+				SubmittedAt:    now,
+			}
+			if err := tx.Create(&claim).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.ClaimStatusChange{
+				ClaimID:     claim.ID,
+				ToStatus:    models.ClaimSubmitted,
+				ChangedByID: userID.(uint),
+				ChangedAt:   now,
+			}).Error
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+				return
+			}
+			respondClaimError(c, err, "Failed to create claim")
+			return
+		}
+
+		created, err := loadClaim(db, claim.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch claim"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Claim submitted successfully",
+			"claim":   created,
+		})
+	}
+}
+
+// UpdateClaimStatus records the payer's decision on a claim, its payment or
+// its resubmission after a denial (admin only)
+func UpdateClaimStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req ClaimStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var claim models.Claim
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&claim, c.Param("id")).Error; err != nil {
+				return err
+			}
+
+			updates := map[string]interface{}{}
+			if req.PayerReference != "" {
+				updates["payer_reference"] = req.PayerReference
+			}
+			switch req.Status {
+			case models.ClaimApproved:
+				amount := claim.BilledAmount
+				if req.ApprovedAmount != nil {
+					amount = *req.ApprovedAmount
+				}
+				if amount > claim.BilledAmount {
+					return errClaimAmount
+				}
+				updates["approved_amount"] = amount
+			case models.ClaimDenied:
+				if req.Reason == "" {
+					return errDenialReason
+				}
+				updates["denial_reason"] = req.Reason
+			case models.ClaimPaid:
+				amount := claim.ApprovedAmount
+				if req.PaidAmount != nil {
+					amount = *req.PaidAmount
+				}
+				if amount > claim.ApprovedAmount {
+					return errClaimAmount
+				}
+				updates["paid_amount"] = amount
+			case models.ClaimSubmitted:
+				updates["denial_reason"] = ""
+			}
+			return claim.Transition(tx, req.Status, userID.(uint), req.Reason, updates)
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+				return
+			}
+			respondClaimError(c, err, "Failed to update claim")
+			return
+		}
+
+		claim, err := loadClaim(db, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch claim"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Claim status updated successfully",
+			"claim":   claim,
+		})
+	}
+}
+
+// GetClaim returns a claim with its policy and status history (admin only)
+func GetClaim(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claim, err := loadClaim(db, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, claim)
+	}
+}
+
+// ListClaims returns claims, optionally filtered by status, patient or
+// doctor (admin only)
+func ListClaims(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.Claim{}).Order("submitted_at DESC")
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if patientID := c.Query("patient_id"); patientID != "" {
+			query = query.Where("patient_id = ?", patientID)
+		}
+		if doctorID := c.Query("doctor_id"); doctorID != "" {
+			query = query.Where("doctor_id = ?", doctorID)
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 {
+			limit = 10
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var claims []models.Claim
+		if err := query.Preload("Policy", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+			Offset((page - 1) * limit).Limit(limit).Find(&claims).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch claims"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": claims,
+			"meta": gin.H{
+				"total":     total,
+				"page":      page,
+				"limit":     limit,
+				"totalPage": (int(total) + limit - 1) / limit,
+			},
+		})
+	}
+}
+
+// ListPatientClaims returns the claims made for the logged-in patient's appointments
+func ListPatientClaims(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var claims []models.Claim
+		if err := db.Where("patient_id = ?", userID).
+			Preload("Policy", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+			Order("submitted_at DESC").
+			Find(&claims).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch claims"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": claims})
+	}
+}
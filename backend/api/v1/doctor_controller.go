@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -94,6 +95,7 @@ func GetDoctorProfile(db *gorm.DB) gin.HandlerFunc {
 			"experience":       doctor.Experience,
 			"bio":              doctor.Bio,
 			"consultation_fee": doctor.ConsultationFee,
+			"time_zone":        doctor.Location().String(),
 		})
 	}
 }
@@ -109,11 +111,13 @@ func GetDoctorDashboard(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Get today's appointments
+		// Get today's appointments, where today is the doctor's calendar day
+		loc := doctor.Location()
+		today := dayRange(localDate(time.Now(), loc), loc)
 		var todayAppointments []models.Appointment
-		today := time.Now().Format("2006-01-02")
 		if err := db.Preload("Patient").
-			Where("doctor_id = ? AND DATE(appointment_date) = ?", doctor.ID, today).
+			Where("doctor_id = ? AND start_time >= ? AND start_time < ?", doctor.ID, today.Start, today.End).
+			Order("start_time ASC").
 			Find(&todayAppointments).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointments"})
 			return
@@ -130,8 +134,17 @@ func GetDoctorDashboard(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		for i := range todayAppointments {
+			todayAppointments[i].InLocation(loc)
+		}
+		for i := range upcomingAppointments {
+			upcomingAppointments[i].InLocation(loc)
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"doctor_id":            doctor.ID,
+			"time_zone":            loc.String(),
+			"date":                 today.Start.Format("2006-01-02"),
 			"name":                 doctor.User.Name,
 			"today_appointments":   todayAppointments,
 			"upcoming_appointments": upcomingAppointments,
@@ -193,13 +206,26 @@ func CreateSchedule(db *gorm.DB) gin.HandlerFunc {
 		schedule := models.Schedule{
 			DoctorID:    doctor.ID,
 			Date:        date,
-			StartTime:   req.StartTime,
-			EndTime:     req.EndTime,
+			StartTime:   startTime.Format("15:04"),
+			EndTime:     endTime.Format("15:04"),
 			IsAvailable: true,
 		}
 
-		if err := db.Create(&schedule).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule"})
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if _, err := lockDoctor(tx, doctor.ID); err != nil {
+				return err
+			}
+			existing, err := findOverlappingSchedule(tx, doctor.ID, date, schedule.StartTime, schedule.EndTime, true, 0)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				return &scheduleOverlapError{Existing: *existing}
+			}
+			return tx.Create(&schedule).Error
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to create schedule")
 			return
 		}
 
@@ -209,3 +235,25 @@ func CreateSchedule(db *gorm.DB) gin.HandlerFunc {
 		})
 	}
 }
+
+// ensureDoctorProfile gives a user who has been made a doctor an empty doctor
+// profile, restoring a removed one, so that the doctor routes work for them.
+// RegisterUser creates the profile itself from the registration details.
+func ensureDoctorProfile(tx *gorm.DB, userID uint) error {
+	var doctor models.Doctor
+	err := tx.Unscoped().Where("user_id = ?", userID).First(&doctor).Error
+	if err == nil {
+		if doctor.DeletedAt.Valid {
+			return tx.Unscoped().Model(&doctor).Update("deleted_at", nil).Error
+		}
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return tx.Create(&models.Doctor{
+		UserID:    userID,
+		TimeZone:  defaultTimeZone(),
+		Available: true,
+	}).Error
+}
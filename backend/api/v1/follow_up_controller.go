@@ -0,0 +1,338 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FollowUpRequest recommends a follow-up after a completed appointment. The
+// window dates are calendar dates in the doctor's time zone and are both
+// optional. With scheduled_at the follow-up is booked straight away.
+type FollowUpRequest struct {
+	FromDate          string     `json:"from_date"` // Format: "2006-01-02"
+	ToDate            string     `json:"to_date"`   // Format: "2006-01-02", inclusive
+	AppointmentTypeID *uint      `json:"appointment_type_id"`
+	DiscountPercent   int        `json:"discount_percent" binding:"min=0,max=100"` // 100 makes the follow-up free
+	Notes             string     `json:"notes"`
+	ScheduledAt       *time.Time `json:"scheduled_at"`
+}
+
+// BookFollowUpRequest books a recommended follow-up
+type BookFollowUpRequest struct {
+	ScheduledAt time.Time `json:"scheduled_at" binding:"required"`
+	Notes       string    `json:"notes"`
+}
+
+var (
+	errParentNotCompleted    = errors.New("Follow-ups can only be created from completed appointments")
+	errFollowUpExists        = errors.New("A follow-up has already been recommended for this appointment")
+	errFollowUpNotFound      = errors.New("Follow-up not found")
+	errFollowUpClosed        = errors.New("Follow-up has already been booked or cancelled")
+	errOutsideFollowUpWindow = errors.New("Requested time is outside the recommended follow-up window")
+)
+
+// parseFollowUpWindow parses the optional window dates of a follow-up request
+func parseFollowUpWindow(req FollowUpRequest, today time.Time) (from, to *time.Time, err error) {
+	parse := func(value string) (*time.Time, error) {
+		if value == "" {
+			return nil, nil
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, errors.New("Invalid date format. Use YYYY-MM-DD")
+		}
+		return &date, nil
+	}
+
+	if from, err = parse(req.FromDate); err != nil {
+		return nil, nil, err
+	}
+	if to, err = parse(req.ToDate); err != nil {
+		return nil, nil, err
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, errors.New("to_date must not be before from_date")
+	}
+	if to != nil && to.Before(today) {
+		return nil, nil, errors.New("to_date must not be in the past")
+	}
+	return from, to, nil
+}
+
+// lockPatientFollowUp locks the doctor of one of the patient's follow-ups and
+// then the pending follow-up itself. The doctor is locked first to keep the
+// lock order the same as in booking.
+func lockPatientFollowUp(tx *gorm.DB, patientID, followUpID interface{}) (*models.FollowUp, error) {
+	var followUp models.FollowUp
+	if err := tx.Where("id = ? AND patient_id = ?", followUpID, patientID).First(&followUp).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errFollowUpNotFound
+		}
+		return nil, err
+	}
+	if _, err := lockDoctor(tx, followUp.DoctorID); err != nil {
+		return nil, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&followUp, followUp.ID).Error; err != nil {
+		return nil, err
+	}
+	if followUp.Status != models.FollowUpPending {
+		return nil, errFollowUpClosed
+	}
+	return &followUp, nil
+}
+
+// bookFollowUp books a pending follow-up at scheduledAt, within its window,
+// links the new appointment to its parent and charges the discounted fee
+func bookFollowUp(tx *gorm.DB, followUp *models.FollowUp, scheduledAt time.Time, notes string, bookedByID *uint) (models.Appointment, error) {
+	appointment, err := bookSlot(tx, followUp.PatientID, followUp.DoctorID, followUp.AppointmentTypeID, scheduledAt, notes, bookedByID)
+	if err != nil {
+		return appointment, err
+	}
+	// bookSlot returns the start in the doctor's zone
+	if !followUp.Covers(localDate(appointment.StartTime, appointment.StartTime.Location())) {
+		return appointment, errOutsideFollowUpWindow
+	}
+
+	appointment.IsFollowUp = true
+	appointment.ParentAppointmentID = &followUp.ParentAppointmentID
+	appointment.FollowUpNotes = followUp.Notes
+	appointment.Fee = followUp.DiscountedFee(appointment.Fee)
+	// The co-pay is worked out again on the discounted fee
+	if err := applyEligibility(tx, &appointment, appointment.StartTime.Location()); err != nil {
+		return appointment, err
+	}
+	updates := eligibilityUpdates(&appointment)
+	updates["is_follow_up"] = true
+	updates["parent_appointment_id"] = followUp.ParentAppointmentID
+	updates["follow_up_notes"] = followUp.Notes
+	updates["fee"] = appointment.Fee
+	if err := tx.Model(&appointment).Updates(updates).Error; err != nil {
+		return appointment, err
+	}
+
+	if err := tx.Model(followUp).Updates(map[string]interface{}{
+		"status":         models.FollowUpBooked,
+		"appointment_id": appointment.ID,
+	}).Error; err != nil {
+		return appointment, err
+	}
+	followUp.Status = models.FollowUpBooked
+	followUp.AppointmentID = &appointment.ID
+	return appointment, nil
+}
+
+// respondFollowUpError writes the HTTP response for an error from creating or
+// booking a follow-up
+func respondFollowUpError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errFollowUpNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errParentNotCompleted), errors.Is(err, errOutsideFollowUpWindow):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errFollowUpExists), errors.Is(err, errFollowUpClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		respondBookingError(c, err)
+	}
+}
+
+// notifyFollowUp tells the patient that their doctor recommends a follow-up
+func notifyFollowUp(db *gorm.DB, m mailer.Mailer, followUp models.FollowUp) {
+	var patient models.User
+	if err := db.First(&patient, followUp.PatientID).Error; err != nil {
+		log.Printf("Failed to load patient %d for follow-up: %v", followUp.PatientID, err)
+		return
+	}
+	if strings.HasSuffix(patient.Email, "@"+placeholderEmailDomain) {
+		return
+	}
+	var doctor models.Doctor
+	if err := db.Preload("User").First(&doctor, followUp.DoctorID).Error; err != nil {
+		log.Printf("Failed to load doctor %d for follow-up: %v", followUp.DoctorID, err)
+		return
+	}
+
+	window := "at a time that suits you"
+	switch {
+	case followUp.FromDate != nil && followUp.ToDate != nil:
+		window = fmt.Sprintf("between %s and %s", followUp.FromDate.Format("2 January 2006"), followUp.ToDate.Format("2 January 2006"))
+	case followUp.FromDate != nil:
+		window = "from " + followUp.FromDate.Format("2 January 2006")
+	case followUp.ToDate != nil:
+		window = "by " + followUp.ToDate.Format("2 January 2006")
+	}
+
+	if err := m.Send(mailer.Message{
+		To:      patient.Email,
+		Subject: "Your doctor recommends a follow-up appointment",
+		Body: fmt.Sprintf("Hi %s,\n\nDr. %s recommends a follow-up appointment %s.\n\n%s\n\nBook it from your pending follow-ups in the app.\n",
+			patient.Name, doctor.User.Name, window, followUp.Notes),
+	}); err != nil {
+		log.Printf("Failed to send follow-up %d: %v", followUp.ID, err)
+	}
+}
+
+// CreateFollowUp recommends a follow-up after one of the logged-in doctor's
+// completed appointments. The patient is emailed unless the follow-up is
+// booked straight away with scheduled_at.
+func CreateFollowUp(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var doctor models.Doctor
+		if err := db.Where("user_id = ?", userID).First(&doctor).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+			return
+		}
+
+		var req FollowUpRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		from, to, err := parseFollowUpWindow(req, localDate(time.Now(), doctor.Location()))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := loadVisit(db, doctor, req.AppointmentTypeID); err != nil {
+			respondSlotError(c, err, "Failed to create follow-up")
+			return
+		}
+
+		var followUp models.FollowUp
+		var appointment *models.Appointment
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Lock the doctor before the parent, as booking the follow-up does
+			if _, err := lockDoctor(tx, doctor.ID); err != nil {
+				return err
+			}
+			parent, err := lockAppointment(tx.Where("doctor_id = ?", doctor.ID), c.Param("id"))
+			if err != nil {
+				return err
+			}
+			if parent.Status != models.StatusCompleted {
+				return errParentNotCompleted
+			}
+
+			var existing int64
+			if err := tx.Model(&models.FollowUp{}).
+				Where("parent_appointment_id = ? AND status <> ?", parent.ID, models.FollowUpCancelled).
+				Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				return errFollowUpExists
+			}
+
+			followUp = models.FollowUp{
+				ParentAppointmentID: parent.ID,
+				PatientID:           parent.PatientID,
+				DoctorID:            doctor.ID,
+				AppointmentTypeID:   req.AppointmentTypeID,
+				FromDate:            from,
+				ToDate:              to,
+				DiscountPercent:     req.DiscountPercent,
+				Notes:               req.Notes,
+				Status:              models.FollowUpPending,
+			}
+			if err := tx.Create(&followUp).Error; err != nil {
+				return err
+			}
+
+			if req.ScheduledAt != nil {
+				booked, err := bookFollowUp(tx, &followUp, *req.ScheduledAt, "", &doctor.UserID)
+				if err != nil {
+					return err
+				}
+				appointment = &booked
+			}
+			return nil
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+			return
+		}
+		if err != nil {
+			respondFollowUpError(c, err)
+			return
+		}
+
+		response := gin.H{
+			"message":     "Follow-up created successfully",
+			"follow_up":   followUp,
+			"appointment": nil,
+		}
+		if appointment == nil {
+			notifyFollowUp(db, m, followUp)
+		} else {
+			response["appointment"] = newBookingResponse(c, db, gateway, *appointment)
+		}
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// ListFollowUps returns the logged-in patient's follow-ups, by default the
+// pending recommendations that still have to be booked
+func ListFollowUps(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		query := db.Where("patient_id = ?", userID)
+		if status := c.DefaultQuery("status", models.FollowUpPending); status != "all" {
+			query = query.Where("status = ?", status)
+		}
+
+		var followUps []models.FollowUp
+		if err := query.Preload("Doctor").Preload("Doctor.User").Preload("AppointmentType").
+			Order("created_at DESC").
+			Find(&followUps).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follow-ups"})
+			return
+		}
+
+		c.JSON(http.StatusOK, followUps)
+	}
+}
+
+// BookFollowUp books one of the logged-in patient's pending follow-ups. The
+// payment intent, if any, is for the discounted fee.
+func BookFollowUp(db *gorm.DB, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req BookFollowUpRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var appointment models.Appointment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			followUp, err := lockPatientFollowUp(tx, userID, c.Param("id"))
+			if err != nil {
+				return err
+			}
+			appointment, err = bookFollowUp(tx, followUp, req.ScheduledAt, req.Notes, nil)
+			return err
+		})
+		if err != nil {
+			respondFollowUpError(c, err)
+			return
+		}
+
+		respondBooked(c, db, gateway, appointment)
+	}
+}
@@ -0,0 +1,273 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+)
+
+// InsurancePolicyRequest adds or replaces one of a patient's insurance policies
+type InsurancePolicyRequest struct {
+	Payer              string  `json:"payer" binding:"required,max=100"`
+	PlanName           string  `json:"plan_name" binding:"max=100"`
+	MemberID           string  `json:"member_id" binding:"required,max=64"`
+	GroupNumber        string  `json:"group_number" binding:"max=64"`
+	CoverageStart      string  `json:"coverage_start" binding:"required"` // Format: "2006-01-02"
+	CoverageEnd        string  `json:"coverage_end"`                      // Format: "2006-01-02", inclusive; empty while the cover runs
+	CopayAmount        float64 `json:"copay_amount" binding:"min=0"`
+	CoinsurancePercent int     `json:"coinsurance_percent" binding:"min=0,max=100"`
+}
+
+var errEligibilityFixed = errors.New("Eligibility cannot change for cancelled, paid or claimed appointments")
+
+// applyInsurancePolicyRequest validates the coverage dates of a request and
+// copies it onto a policy
+func applyInsurancePolicyRequest(policy *models.InsurancePolicy, req InsurancePolicyRequest) error {
+	start, err := time.Parse("2006-01-02", req.CoverageStart)
+	if err != nil {
+		return errors.New("Invalid coverage_start format. Use YYYY-MM-DD")
+	}
+	var end *time.Time
+	if req.CoverageEnd != "" {
+		date, err := time.Parse("2006-01-02", req.CoverageEnd)
+		if err != nil {
+			return errors.New("Invalid coverage_end format. Use YYYY-MM-DD")
+		}
+		if date.Before(start) {
+			return errors.New("coverage_end must not be before coverage_start")
+		}
+		end = &date
+	}
+
+	policy.Payer = req.Payer
+	policy.PlanName = req.PlanName
+	policy.MemberID = req.MemberID
+	policy.GroupNumber = req.GroupNumber
+	policy.CoverageStart = start
+	policy.CoverageEnd = end
+	policy.CopayAmount = req.CopayAmount
+	policy.CoinsurancePercent = req.CoinsurancePercent
+	return nil
+}
+
+// applyEligibility sets the appointment's eligibility from the patient's
+// policies on the appointment date in loc. The first policy added that
+// covers the date is billed and sets the co-pay. Nothing is saved.
+func applyEligibility(tx *gorm.DB, appointment *models.Appointment, loc *time.Location) error {
+	var policies []models.InsurancePolicy
+	if err := tx.Where("patient_id = ?", appointment.PatientID).Order("id ASC").Find(&policies).Error; err != nil {
+		return err
+	}
+
+	appointment.InsurancePolicyID = nil
+	appointment.CopayAmount = 0
+	appointment.EligibilityStatus = models.EligibilitySelfPay
+	if len(policies) > 0 {
+		appointment.EligibilityStatus = models.EligibilityIneligible
+	}
+
+	date := localDate(appointment.StartTime, loc)
+	for i := range policies {
+		if policies[i].Covers(date) {
+			appointment.InsurancePolicyID = &policies[i].ID
+			appointment.CopayAmount = policies[i].Copay(appointment.Fee)
+			appointment.EligibilityStatus = models.EligibilityEligible
+			break
+		}
+	}
+	return nil
+}
+
+// eligibilityUpdates returns the columns applyEligibility sets
+func eligibilityUpdates(appointment *models.Appointment) map[string]interface{} {
+	return map[string]interface{}{
+		"insurance_policy_id": appointment.InsurancePolicyID,
+		"eligibility_status":  appointment.EligibilityStatus,
+		"copay_amount":        appointment.CopayAmount,
+	}
+}
+
+// ListInsurancePolicies returns the logged-in patient's insurance policies
+func ListInsurancePolicies(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var policies []models.InsurancePolicy
+		if err := db.Where("patient_id = ?", userID).Order("id ASC").Find(&policies).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch insurance policies"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": policies})
+	}
+}
+
+// CreateInsurancePolicy adds an insurance policy for the logged-in patient.
+// Appointments already booked keep their eligibility until it is checked again.
+func CreateInsurancePolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req InsurancePolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		policy := models.InsurancePolicy{PatientID: userID.(uint)}
+		if err := applyInsurancePolicyRequest(&policy, req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Create(&policy).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create insurance policy"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Insurance policy added successfully",
+			"policy":  policy,
+		})
+	}
+}
+
+// UpdateInsurancePolicy replaces one of the logged-in patient's insurance policies
+func UpdateInsurancePolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var policy models.InsurancePolicy
+		if err := db.Where("id = ? AND patient_id = ?", c.Param("id"), userID).First(&policy).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Insurance policy not found"})
+			return
+		}
+
+		var req InsurancePolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := applyInsurancePolicyRequest(&policy, req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Save(&policy).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update insurance policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Insurance policy updated successfully",
+			"policy":  policy,
+		})
+	}
+}
+
+// DeleteInsurancePolicy removes one of the logged-in patient's insurance
+// policies. Claims already made against it are unaffected.
+func DeleteInsurancePolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		result := db.Where("id = ? AND patient_id = ?", c.Param("id"), userID).Delete(&models.InsurancePolicy{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete insurance policy"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Insurance policy not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Insurance policy deleted successfully"})
+	}
+}
+
+// CheckEligibility checks one of the logged-in patient's appointments against
+// their current insurance policies
+func CheckEligibility(db *gorm.DB, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		checkEligibility(c, db, gateway, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("patient_id = ?", userID)
+		})
+	}
+}
+
+// AdminCheckEligibility checks any appointment against the patient's
+// current insurance policies
+func AdminCheckEligibility(db *gorm.DB, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkEligibility(c, db, gateway, func(tx *gorm.DB) *gorm.DB {
+			return tx
+		})
+	}
+}
+
+// checkEligibility updates the eligibility of the appointment selected by
+// scope. Appointments that have been paid or claimed keep the co-pay they
+// were billed with. An unpaid payment intent is replaced when the patient's
+// share changes.
+func checkEligibility(c *gin.Context, db *gorm.DB, gateway payments.PaymentGateway, scope func(*gorm.DB) *gorm.DB) {
+	var appointment *models.Appointment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		appointment, err = lockAppointment(scope(tx), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if appointment.Status == models.StatusCancelled || appointment.IsPaid {
+			return errEligibilityFixed
+		}
+		var claims int64
+		if err := tx.Model(&models.Claim{}).Where("appointment_id = ?", appointment.ID).Count(&claims).Error; err != nil {
+			return err
+		}
+		if claims > 0 {
+			return errEligibilityFixed
+		}
+
+		var doctor models.Doctor
+		if err := tx.First(&doctor, appointment.DoctorID).Error; err != nil {
+			return err
+		}
+		if err := applyEligibility(tx, appointment, doctor.Location()); err != nil {
+			return err
+		}
+		return tx.Model(appointment).Updates(eligibilityUpdates(appointment)).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return
+	case errors.Is(err, errEligibilityFixed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check eligibility"})
+		return
+	}
+
+	// The patient can still pay the new amount later through PayAppointment
+	if _, err := syncPayment(c.Request.Context(), db, gateway, appointment); err != nil {
+		log.Printf("Failed to update payment for appointment %d: %v", appointment.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"appointment_id":      appointment.ID,
+		"eligibility_status":  appointment.EligibilityStatus,
+		"insurance_policy_id": appointment.InsurancePolicyID,
+		"fee":                 appointment.Fee,
+		"copay_amount":        appointment.CopayAmount,
+		"insured_amount":      appointment.InsuredAmount(),
+		"patient_share":       appointment.PatientShare(),
+	})
+}
@@ -0,0 +1,279 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/invoice"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvoiceNotPaid  = errors.New("Invoices and receipts are only issued for paid appointments")
+	errInvoiceRefunded = errors.New("Invoices and receipts are not issued for cancelled or refunded appointments")
+)
+
+// invoiceTitles are the titles printed on each kind of billing document
+var invoiceTitles = map[string]string{
+	models.InvoiceKindInvoice: "Invoice",
+	models.InvoiceKindReceipt: "Receipt",
+}
+
+// issueInvoice returns the appointment's billing document of the given kind,
+// issuing it with the next number on first request. The caller must hold the
+// appointment's row lock so that concurrent requests issue it only once.
+func issueInvoice(tx *gorm.DB, appointment *models.Appointment, kind string) (*models.Invoice, error) {
+	// A document saying the appointment was paid in full would be wrong once
+	// it has been cancelled or any of the payment handed back
+	if appointment.Status == models.StatusCancelled {
+		return nil, errInvoiceRefunded
+	}
+	var payment models.Payment
+	hasPayment := false
+	if err := tx.Where("appointment_id = ?", appointment.ID).First(&payment).Error; err == nil {
+		hasPayment = true
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if hasPayment && (payment.Status == models.PaymentRefunded || payment.Status == models.PaymentPartiallyRefunded) {
+		return nil, errInvoiceRefunded
+	}
+
+	var issued models.Invoice
+	err := tx.Where("appointment_id = ? AND kind = ?", appointment.ID, kind).First(&issued).Error
+	if err == nil {
+		return &issued, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !appointment.IsPaid {
+		return nil, errInvoiceNotPaid
+	}
+
+	var patient models.User
+	if err := tx.First(&patient, appointment.PatientID).Error; err != nil {
+		return nil, err
+	}
+	var doctor models.Doctor
+	if err := tx.Preload("User").First(&doctor, appointment.DoctorID).Error; err != nil {
+		return nil, err
+	}
+
+	description := "Consultation"
+	if appointment.AppointmentTypeID != nil {
+		// Deleted types still name the appointments booked with them
+		var appointmentType models.AppointmentType
+		if err := tx.Unscoped().First(&appointmentType, *appointment.AppointmentTypeID).Error; err == nil {
+			description = appointmentType.Name
+		}
+	}
+	if appointment.IsFollowUp {
+		description += " (follow-up)"
+	}
+	description += " on " + appointment.StartTime.In(doctor.Location()).Format("2 Jan 2006 15:04 MST")
+
+	total := appointment.PaymentAmount
+	if total == 0 {
+		total = appointment.Fee
+	}
+	currency := payments.Currency()
+	var paidAt *time.Time
+	if hasPayment {
+		currency = payment.Currency
+		paidAt = payment.PaidAt
+	}
+
+	// Fees are charged tax inclusive, so the tax is carved out of the total
+	rate := config.GetFloatEnv("TAX_RATE", 0)
+	subtotal, tax := models.SplitTax(total, rate)
+
+	now := time.Now()
+	number, err := models.NextInvoiceNumber(tx, kind, now.UTC().Year())
+	if err != nil {
+		return nil, err
+	}
+	issued = models.Invoice{
+		Number:           number,
+		Kind:             kind,
+		AppointmentID:    appointment.ID,
+		Issuer:           config.GetEnv("INVOICE_ISSUER", "Doctor Booking"),
+		PatientID:        patient.ID,
+		DoctorID:         doctor.ID,
+		PatientName:      patient.Name,
+		PatientEmail:     patient.Email,
+		DoctorName:       doctor.User.Name,
+		Specialization:   string(doctor.Specialization),
+		Description:      description,
+		Subtotal:         subtotal,
+		TaxLabel:         config.GetEnv("TAX_LABEL", "Tax"),
+		TaxRate:          rate,
+		TaxAmount:        tax,
+		Total:            total,
+		Currency:         currency,
+		PaymentReference: appointment.PaymentReference,
+		PaidAt:           paidAt,
+		IssuedAt:         now,
+	}
+	if err := tx.Create(&issued).Error; err != nil {
+		return nil, err
+	}
+	return &issued, nil
+}
+
+// renderInvoice lays out an issued billing document as a PDF
+func renderInvoice(issued *models.Invoice) []byte {
+	title := invoiceTitles[issued.Kind]
+	doc := invoice.Document{
+		Title:  title,
+		Issuer: issued.Issuer,
+		Number: issued.Number,
+		Date:   issued.IssuedAt,
+		Fields: []invoice.Field{{Label: "Appointment", Value: fmt.Sprintf("#%d", issued.AppointmentID)}},
+		Parties: []invoice.Party{
+			{Heading: "Billed to", Lines: []string{issued.PatientName, issued.PatientEmail}},
+			{Heading: "Provider", Lines: []string{issued.DoctorName, issued.Specialization}},
+		},
+		Items:    []invoice.Item{{Description: issued.Description, Amount: issued.Subtotal}},
+		Subtotal: issued.Subtotal,
+		Tax:      issued.TaxAmount,
+		Total:    issued.Total,
+		Currency: issued.Currency,
+	}
+	if issued.TaxRate > 0 {
+		doc.TaxLabel = fmt.Sprintf("%s %s%%", issued.TaxLabel, strconv.FormatFloat(issued.TaxRate, 'f', -1, 64))
+	}
+	if issued.PaymentReference != "" {
+		doc.Fields = append(doc.Fields, invoice.Field{Label: "Payment reference", Value: issued.PaymentReference})
+	}
+	if issued.PaidAt != nil {
+		doc.Fields = append(doc.Fields, invoice.Field{Label: "Paid on", Value: issued.PaidAt.Format("2 January 2006")})
+	}
+
+	if issued.Kind == models.InvoiceKindReceipt {
+		doc.Notes = []string{fmt.Sprintf("Received with thanks: %s %s.", doc.Currency, invoice.FormatAmount(issued.Total))}
+	} else {
+		doc.Notes = []string{"This invoice has been paid in full."}
+	}
+	return invoice.Render(doc)
+}
+
+// DownloadInvoice returns the invoice or receipt of one of the logged-in
+// patient's paid appointments as a PDF
+func DownloadInvoice(db *gorm.DB, kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		downloadInvoice(c, db, kind, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("patient_id = ?", userID)
+		})
+	}
+}
+
+// AdminDownloadInvoice returns the invoice or receipt of any paid appointment as a PDF
+func AdminDownloadInvoice(db *gorm.DB, kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		downloadInvoice(c, db, kind, func(tx *gorm.DB) *gorm.DB {
+			return tx
+		})
+	}
+}
+
+// downloadInvoice issues, if needed, and sends the billing document of the
+// appointment selected by scope
+func downloadInvoice(c *gin.Context, db *gorm.DB, kind string, scope func(*gorm.DB) *gorm.DB) {
+	var issued *models.Invoice
+	err := db.Transaction(func(tx *gorm.DB) error {
+		appointment, err := lockAppointment(scope(tx), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		issued, err = issueInvoice(tx, appointment, kind)
+		return err
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return
+	case errors.Is(err, errInvoiceNotPaid), errors.Is(err, errInvoiceRefunded):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue " + kind})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, issued.Number))
+	c.Data(http.StatusOK, "application/pdf", renderInvoice(issued))
+}
+
+// ListInvoices returns the invoices and receipts issued to the logged-in patient
+func ListInvoices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var invoices []models.Invoice
+		if err := db.Where("patient_id = ?", userID).Order("issued_at DESC").Find(&invoices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
+			return
+		}
+
+		c.JSON(http.StatusOK, invoices)
+	}
+}
+
+// ListAllInvoices returns issued invoices and receipts, optionally filtered
+// by kind, patient or doctor (admin only)
+func ListAllInvoices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.Invoice{}).Order("issued_at DESC")
+		if kind := c.Query("kind"); kind != "" {
+			if !models.IsValidInvoiceKind(kind) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be invoice or receipt"})
+				return
+			}
+			query = query.Where("kind = ?", kind)
+		}
+		if patientID := c.Query("patient_id"); patientID != "" {
+			query = query.Where("patient_id = ?", patientID)
+		}
+		if doctorID := c.Query("doctor_id"); doctorID != "" {
+			query = query.Where("doctor_id = ?", doctorID)
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 {
+			limit = 10
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var invoices []models.Invoice
+		if err := query.Offset((page - 1) * limit).Limit(limit).Find(&invoices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": invoices,
+			"meta": gin.H{
+				"total":     total,
+				"page":      page,
+				"limit":     limit,
+				"totalPage": (int(total) + limit - 1) / limit,
+			},
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package v1
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginThrottlePolicy controls when repeated login failures lock a key
+type loginThrottlePolicy struct {
+	MaxFailures int           // failures allowed before the first lockout
+	Window      time.Duration // failures older than this are forgotten
+	BaseLockout time.Duration // first lockout; doubles with each further failure
+	MaxLockout  time.Duration
+}
+
+func accountThrottlePolicy() loginThrottlePolicy {
+	return loginThrottlePolicy{
+		MaxFailures: config.GetIntEnv("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		Window:      config.GetDurationEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		BaseLockout: config.GetDurationEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		MaxLockout:  config.GetDurationEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+	}
+}
+
+func ipThrottlePolicy() loginThrottlePolicy {
+	policy := accountThrottlePolicy()
+	policy.MaxFailures = config.GetIntEnv("LOGIN_MAX_IP_FAILURES", 20)
+	return policy
+}
+
+// lockoutFor returns the lockout after the given number of failures, doubling
+// for each failure past the threshold
+func (p loginThrottlePolicy) lockoutFor(failures int) time.Duration {
+	if failures < p.MaxFailures {
+		return 0
+	}
+	lockout := p.BaseLockout
+	for i := p.MaxFailures; i < failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > p.MaxLockout {
+		lockout = p.MaxLockout
+	}
+	return lockout
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginLockedFor returns how long the key remains locked
+func loginLockedFor(db *gorm.DB, key string) (time.Duration, error) {
+	var throttle models.LoginThrottle
+	err := db.Where("key = ?", key).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return throttle.LockedFor(time.Now()), nil
+}
+
+// recordLoginFailure counts a failed attempt against the key and locks it once
+// the policy threshold is reached
+func recordLoginFailure(db *gorm.DB, key string, policy loginThrottlePolicy) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key}).Error; err != nil {
+			return err
+		}
+
+		var throttle models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).First(&throttle).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if throttle.LockedFor(now) == 0 && now.Sub(throttle.LastFailureAt) > policy.Window {
+			throttle.Failures = 0
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		if lockout := policy.lockoutFor(throttle.Failures); lockout > 0 {
+			until := now.Add(lockout)
+			throttle.LockedUntil = &until
+		}
+
+		return tx.Save(&throttle).Error
+	})
+}
+
+// clearLoginFailures resets the failure count and any lockout for the key
+func clearLoginFailures(db *gorm.DB, key string) error {
+	return db.Model(&models.LoginThrottle{}).Where("key = ?", key).
+		Updates(map[string]interface{}{"failures": 0, "locked_until": nil}).Error
+}
@@ -0,0 +1,34 @@
+package v1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutFor(t *testing.T) {
+	policy := loginThrottlePolicy{
+		MaxFailures: 3,
+		BaseLockout: time.Minute,
+		MaxLockout:  10 * time.Minute,
+	}
+
+	testCases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: 0},
+		{failures: 2, expected: 0},
+		{failures: 3, expected: time.Minute},
+		{failures: 4, expected: 2 * time.Minute},
+		{failures: 5, expected: 4 * time.Minute},
+		{failures: 6, expected: 8 * time.Minute},
+		{failures: 7, expected: 10 * time.Minute},
+		{failures: 50, expected: 10 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, policy.lockoutFor(tc.failures), "failures=%d", tc.failures)
+	}
+}
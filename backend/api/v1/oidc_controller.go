@@ -0,0 +1,260 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/middleware"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/oidc"
+	"gorm.io/gorm"
+)
+
+const oidcLoginStateTTL = 10 * time.Minute
+
+// oidcRoleMapping maps values of an ID token claim to local roles
+type oidcRoleMapping struct {
+	Claim       string
+	Roles       map[string]models.UserRole
+	DefaultRole models.UserRole
+}
+
+// loadOIDCRoleMapping reads OIDC_ROLE_CLAIM, OIDC_ROLE_MAPPING
+// ("claim-value=role,...") and OIDC_DEFAULT_ROLE
+func loadOIDCRoleMapping() oidcRoleMapping {
+	mapping := oidcRoleMapping{
+		Claim:       config.GetEnv("OIDC_ROLE_CLAIM", "roles"),
+		Roles:       make(map[string]models.UserRole),
+		DefaultRole: models.UserRole(config.GetEnv("OIDC_DEFAULT_ROLE", string(models.PatientRole))),
+	}
+	for _, pair := range strings.Split(config.GetEnv("OIDC_ROLE_MAPPING", ""), ",") {
+		value, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && value != "" && role != "" {
+			mapping.Roles[value] = models.UserRole(role)
+		}
+	}
+	return mapping
+}
+
+// roleRank orders roles so that the most privileged mapped role wins. Custom
+// roles rank above patient and below the built-in staff roles.
+var roleRank = map[models.UserRole]int{
+	models.PatientRole:      1,
+	models.ReceptionistRole: 3,
+	models.NurseRole:        4,
+	models.DoctorRole:       5,
+	models.AdminRole:        6,
+}
+
+const customRoleRank = 2
+
+func rankRole(role models.UserRole) int {
+	if rank, ok := roleRank[role]; ok {
+		return rank
+	}
+	return customRoleRank
+}
+
+// resolve returns the mapped role for the claims and whether any claim value
+// matched. Among equally ranked roles the first matching claim value wins.
+func (m oidcRoleMapping) resolve(claims oidc.IDTokenClaims) (models.UserRole, bool) {
+	var best models.UserRole
+	for _, value := range claims.Strings(m.Claim) {
+		if role, ok := m.Roles[value]; ok && (best == "" || rankRole(role) > rankRole(best)) {
+			best = role
+		}
+	}
+	if best == "" {
+		return m.DefaultRole, false
+	}
+	return best, true
+}
+
+// OIDCLogin starts an authorization code login with PKCE by redirecting to the provider
+func OIDCLogin(db *gorm.DB, provider *oidc.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+			return
+		}
+		nonce, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+			return
+		}
+		verifier, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+			return
+		}
+
+		authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+		if err != nil {
+			log.Printf("OIDC login failed: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+			return
+		}
+
+		// Expired states no longer need to be kept
+		db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+		if err := db.Create(&models.OIDCLoginState{
+			State:        state,
+			Nonce:        nonce,
+			CodeVerifier: verifier,
+			ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+			return
+		}
+
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OIDCCallback completes the login, maps the provider account to a local user
+// and finishes the sign-in the same way as LoginUser
+func OIDCCallback(db *gorm.DB, provider *oidc.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if errParam := c.Query("error"); errParam != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login was rejected by the identity provider: " + errParam})
+			return
+		}
+
+		code := c.Query("code")
+		stateParam := c.Query("state")
+		if code == "" || stateParam == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code or state"})
+			return
+		}
+
+		// Each state is single-use
+		var state models.OIDCLoginState
+		if err := db.Where("state = ? AND expires_at > ?", stateParam, time.Now()).First(&state).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+			return
+		}
+		if result := db.Unscoped().Delete(&state); result.Error != nil || result.RowsAffected == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+			return
+		}
+
+		claims, err := provider.Exchange(c.Request.Context(), code, state.CodeVerifier, state.Nonce)
+		if err != nil {
+			log.Printf("OIDC code exchange failed: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to verify identity provider response"})
+			return
+		}
+
+		user, err := resolveOIDCUser(db, provider.Config.IssuerURL, claims, loadOIDCRoleMapping())
+		if errors.Is(err, errOIDCEmailRequired) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+			return
+		}
+		if !user.Active {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+			return
+		}
+
+		// Provider sign-in does not replace the local second factor
+		respondLoggedIn(c, db, user)
+	}
+}
+
+var errOIDCEmailRequired = errors.New("Identity provider did not supply a verified email address")
+
+// resolveOIDCUser finds the local user for a provider account: by an existing
+// link, then by verified email (linking the accounts), and finally by creating
+// a new user. The mapped role is applied whenever the claims match the mapping,
+// revoking the user's existing tokens when it changes.
+func resolveOIDCUser(db *gorm.DB, issuer string, claims oidc.IDTokenClaims, mapping oidcRoleMapping) (*models.User, error) {
+	subject := claims.String("sub")
+	email := strings.ToLower(claims.String("email"))
+	emailVerified := claims.Bool("email_verified")
+	role, mapped := mapping.resolve(claims)
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+		if err == nil {
+			if err := tx.First(&user, identity.UserID).Error; err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		} else {
+			if email == "" || !emailVerified {
+				return errOIDCEmailRequired
+			}
+
+			err := tx.Where("LOWER(email) = ?", email).First(&user).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				password, err := oidc.RandomString()
+				if err != nil {
+					return err
+				}
+				now := time.Now()
+				name := claims.String("name")
+				if name == "" {
+					name = email
+				}
+				// The random password is never shown, so the account can only sign in through the provider
+				user = models.User{
+					Name:            name,
+					Email:           email,
+					Password:        password,
+					Role:            role,
+					Active:          true,
+					EmailVerified:   true,
+					EmailVerifiedAt: &now,
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+
+			if err := tx.Create(&models.UserIdentity{
+				UserID:  user.ID,
+				Issuer:  issuer,
+				Subject: subject,
+				Email:   email,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if mapped && user.Role != role {
+			if err := tx.Model(&user).UpdateColumn("role", role).Error; err != nil {
+				return err
+			}
+			// Tokens carrying the old role must not outlive the change
+			if err := middleware.RevokeAllTokens(tx, user.ID); err != nil {
+				return err
+			}
+			if err := tx.First(&user, user.ID).Error; err != nil {
+				return err
+			}
+		}
+		if user.Role == models.DoctorRole {
+			return ensureDoctorProfile(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,50 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/oidc"
+)
+
+func TestOIDCRoleMappingResolve(t *testing.T) {
+	mapping := oidcRoleMapping{
+		Claim: "groups",
+		Roles: map[string]models.UserRole{
+			"patients":   models.PatientRole,
+			"physicians": models.DoctorRole,
+			"billing":    models.UserRole("billing"),
+			"lab":        models.UserRole("lab"),
+		},
+		DefaultRole: models.PatientRole,
+	}
+
+	testCases := []struct {
+		groups   []string
+		expected models.UserRole
+		mapped   bool
+	}{
+		{groups: []string{"billing"}, expected: "billing", mapped: true},
+		{groups: []string{"patients", "billing"}, expected: "billing", mapped: true},
+		{groups: []string{"billing", "physicians"}, expected: models.DoctorRole, mapped: true},
+		{groups: []string{"lab", "billing"}, expected: "lab", mapped: true},
+		{groups: []string{"unmapped"}, expected: models.PatientRole, mapped: false},
+		{groups: nil, expected: models.PatientRole, mapped: false},
+	}
+
+	for _, tc := range testCases {
+		claims := oidc.IDTokenClaims{}
+		if tc.groups != nil {
+			groups := make([]interface{}, len(tc.groups))
+			for i, g := range tc.groups {
+				groups[i] = g
+			}
+			claims["groups"] = groups
+		}
+		role, mapped := mapping.resolve(claims)
+		assert.Equal(t, tc.expected, role, "groups=%v", tc.groups)
+		assert.Equal(t, tc.mapped, mapped, "groups=%v", tc.groups)
+	}
+}
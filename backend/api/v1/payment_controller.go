@@ -0,0 +1,386 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentResponse is what a client needs to complete a payment with the gateway
+type PaymentResponse struct {
+	AppointmentID uint    `json:"appointment_id"`
+	IntentID      string  `json:"intent_id"`
+	ClientSecret  string  `json:"client_secret"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	Status        string  `json:"status"`
+}
+
+// bookingResponse is a newly booked appointment together with the payment
+// the patient has to complete for it, if any
+type bookingResponse struct {
+	models.Appointment
+	Payment *PaymentResponse `json:"payment,omitempty"`
+}
+
+var errAlreadyPaid = errors.New("Appointment has already been paid")
+
+func newPaymentResponse(payment *models.Payment) *PaymentResponse {
+	return &PaymentResponse{
+		AppointmentID: payment.AppointmentID,
+		IntentID:      payment.IntentID,
+		ClientSecret:  payment.ClientSecret,
+		Amount:        payment.Amount,
+		Currency:      payment.Currency,
+		Status:        payment.Status,
+	}
+}
+
+// startPayment returns the payment intent for the patient's share of an
+// appointment's fee, creating it on first use and replacing it if the share
+// has changed since. It returns nil when payments are disabled or nothing is
+// due.
+func startPayment(ctx context.Context, db *gorm.DB, gateway payments.PaymentGateway, appointment *models.Appointment) (*PaymentResponse, error) {
+	amount := appointment.PatientShare()
+	if gateway == nil || amount <= 0 {
+		return nil, nil
+	}
+	if appointment.IsPaid {
+		return nil, errAlreadyPaid
+	}
+
+	existing, err := syncPayment(ctx, db, gateway, appointment)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return newPaymentResponse(existing), nil
+	}
+
+	// The idempotency key makes a retry after a lost response reuse the intent
+	currency := payments.Currency()
+	intent, err := gateway.CreateIntent(ctx, payments.IntentRequest{
+		Amount:         payments.ToMinorUnits(amount),
+		Currency:       currency,
+		Description:    fmt.Sprintf("Appointment #%d", appointment.ID),
+		Metadata:       map[string]string{"appointment_id": fmt.Sprint(appointment.ID)},
+		IdempotencyKey: fmt.Sprintf("appointment-%d", appointment.ID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payment := models.Payment{
+		AppointmentID: appointment.ID,
+		PatientID:     appointment.PatientID,
+		Gateway:       gateway.Name(),
+		IntentID:      intent.ID,
+		ClientSecret:  intent.ClientSecret,
+		Amount:        payments.FromMinorUnits(intent.Amount),
+		Currency:      currency,
+		Status:        models.PaymentPending,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		return tx.Model(appointment).Updates(map[string]interface{}{
+			"payment_amount":    payment.Amount,
+			"payment_reference": payment.IntentID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	appointment.PaymentAmount = payment.Amount
+	appointment.PaymentReference = payment.IntentID
+	return newPaymentResponse(&payment), nil
+}
+
+// syncPayment brings an appointment's unpaid payment in line with the
+// patient's current share, e.g. after an eligibility check changed the co-pay.
+// An intent for a different amount is canceled and, if anything is still due,
+// replaced. It returns the up-to-date payment, or nil when there is none or
+// nothing is due, and errAlreadyPaid once the payment has succeeded.
+func syncPayment(ctx context.Context, db *gorm.DB, gateway payments.PaymentGateway, appointment *models.Appointment) (*models.Payment, error) {
+	if gateway == nil {
+		return nil, nil
+	}
+	amount := appointment.PatientShare()
+
+	var payment models.Payment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("appointment_id = ?", appointment.ID).
+			First(&payment).Error; err != nil {
+			return err
+		}
+		switch payment.Status {
+		case models.PaymentPending, models.PaymentFailed:
+			if payments.ToMinorUnits(payment.Amount) == payments.ToMinorUnits(amount) {
+				return nil
+			}
+			// Holding the row lock keeps a concurrent request from replacing
+			// the intent twice; the gateway refuses to cancel a paid intent
+			if err := gateway.CancelIntent(ctx, payment.IntentID); err != nil {
+				return err
+			}
+		case models.PaymentCanceled:
+			if amount <= 0 {
+				return nil
+			}
+		default:
+			return errAlreadyPaid
+		}
+
+		payment.Status = models.PaymentCanceled
+		payment.Amount = 0
+		reference := ""
+		if amount > 0 {
+			intent, err := gateway.CreateIntent(ctx, payments.IntentRequest{
+				Amount:         payments.ToMinorUnits(amount),
+				Currency:       payment.Currency,
+				Description:    fmt.Sprintf("Appointment #%d", appointment.ID),
+				Metadata:       map[string]string{"appointment_id": fmt.Sprint(appointment.ID)},
+				IdempotencyKey: fmt.Sprintf("appointment-%d-replaces-%s", appointment.ID, payment.IntentID),
+			})
+			if err != nil {
+				return err
+			}
+			payment.IntentID = intent.ID
+			payment.ClientSecret = intent.ClientSecret
+			payment.Amount = payments.FromMinorUnits(intent.Amount)
+			payment.Status = models.PaymentPending
+			reference = intent.ID
+		}
+		if err := tx.Model(&payment).Select("intent_id", "client_secret", "amount", "status").Updates(&payment).Error; err != nil {
+			return err
+		}
+		return tx.Model(appointment).Updates(map[string]interface{}{
+			"payment_amount":    payment.Amount,
+			"payment_reference": reference,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status == models.PaymentCanceled {
+		appointment.PaymentAmount = 0
+		appointment.PaymentReference = ""
+		return nil, nil
+	}
+	appointment.PaymentAmount = payment.Amount
+	appointment.PaymentReference = payment.IntentID
+	return &payment, nil
+}
+
+// respondBooked writes the response for a newly booked appointment, starting
+// its payment. A gateway failure does not undo the booking; the patient can
+// pay later through PayAppointment.
+func respondBooked(c *gin.Context, db *gorm.DB, gateway payments.PaymentGateway, appointment models.Appointment) {
+	c.JSON(http.StatusCreated, newBookingResponse(c, db, gateway, appointment))
+}
+
+// newBookingResponse starts the payment of a newly booked appointment. A
+// failure is logged and leaves the patient to pay later.
+func newBookingResponse(c *gin.Context, db *gorm.DB, gateway payments.PaymentGateway, appointment models.Appointment) bookingResponse {
+	payment, err := startPayment(c.Request.Context(), db, gateway, &appointment)
+	if err != nil {
+		log.Printf("Failed to start payment for appointment %d: %v", appointment.ID, err)
+	}
+	return bookingResponse{Appointment: appointment, Payment: payment}
+}
+
+// refundPayment refunds up to amount of an appointment's succeeded payment.
+// It returns the amount refunded, which is zero when nothing was paid.
+func refundPayment(ctx context.Context, db *gorm.DB, gateway payments.PaymentGateway, appointmentID uint, amount float64) (float64, error) {
+	if gateway == nil || amount <= 0 {
+		return 0, nil
+	}
+
+	var refunded float64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("appointment_id = ?", appointmentID).
+			First(&payment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if remaining := payment.Refundable(); amount > remaining {
+			amount = remaining
+		}
+		if amount <= 0 {
+			return nil
+		}
+
+		// Holding the payment row lock across the gateway call keeps two
+		// cancellations from refunding the same payment twice
+		refund, err := gateway.Refund(ctx, payment.IntentID, payments.ToMinorUnits(amount),
+			fmt.Sprintf("refund-%s-%d", payment.IntentID, payments.ToMinorUnits(payment.RefundedAmount)))
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		status := models.PaymentPartiallyRefunded
+		if payment.RefundedAmount+amount >= payment.Amount {
+			status = models.PaymentRefunded
+		}
+		refunded = amount
+		return tx.Model(&payment).Updates(map[string]interface{}{
+			"refunded_amount": payment.RefundedAmount + amount,
+			"refund_id":       refund.ID,
+			"refunded_at":     now,
+			"status":          status,
+		}).Error
+	})
+	return refunded, err
+}
+
+// refundCancelled refunds what each cancelled appointment is due under its
+// doctor's cancellation policy and returns the total refunded. Failures are
+// logged so that the cancellation itself still succeeds.
+func refundCancelled(ctx context.Context, db *gorm.DB, gateway payments.PaymentGateway, appointments []*models.Appointment) float64 {
+	total := 0.0
+	for _, appointment := range appointments {
+		refunded, err := refundPayment(ctx, db, gateway, appointment.ID, appointment.RefundDue())
+		if err != nil {
+			log.Printf("Failed to refund appointment %d: %v", appointment.ID, err)
+			continue
+		}
+		total += refunded
+	}
+	return total
+}
+
+// PayAppointment returns the payment intent for one of the logged-in
+// patient's appointments, creating it if booking could not
+func PayAppointment(db *gorm.DB, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		if gateway == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Online payments are not available"})
+			return
+		}
+
+		var appointment models.Appointment
+		if err := db.Where("id = ? AND patient_id = ?", c.Param("id"), userID).First(&appointment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+			return
+		}
+		if appointment.Status == models.StatusCancelled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cancelled appointments cannot be paid"})
+			return
+		}
+
+		payment, err := startPayment(c.Request.Context(), db, gateway, &appointment)
+		switch {
+		case errors.Is(err, errAlreadyPaid):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil:
+			log.Printf("Failed to start payment for appointment %d: %v", appointment.ID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start payment"})
+		case payment == nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing is due for this appointment"})
+		default:
+			c.JSON(http.StatusOK, payment)
+		}
+	}
+}
+
+// PaymentWebhook receives payment events from the gateway. Only requests
+// with a valid signature are acted on. A payment that succeeds after its
+// appointment was cancelled is refunded straight away.
+func PaymentWebhook(db *gorm.DB, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<16))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		event, err := gateway.ParseWebhook(payload, c.Request.Header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook"})
+			return
+		}
+		if event.IntentID == "" || (event.Type != payments.EventPaymentSucceeded && event.Type != payments.EventPaymentFailed) {
+			// Acknowledge events that are not acted on so they are not resent
+			c.JSON(http.StatusOK, gin.H{"received": true})
+			return
+		}
+
+		var appointment models.Appointment
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var payment models.Payment
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("intent_id = ?", event.IntentID).
+				First(&payment).Error; err != nil {
+				return err
+			}
+			if payment.Status != models.PaymentPending && payment.Status != models.PaymentFailed {
+				// Already handled; gateways deliver events at least once
+				return nil
+			}
+
+			if event.Type == payments.EventPaymentFailed {
+				return tx.Model(&payment).Update("status", models.PaymentFailed).Error
+			}
+			if event.Amount != payments.ToMinorUnits(payment.Amount) {
+				// Redelivery would not change the amount, so leave it for review
+				log.Printf("Payment event %s amount %d does not match payment %d", event.ID, event.Amount, payment.ID)
+				return nil
+			}
+
+			now := time.Now()
+			if err := tx.Model(&payment).Updates(map[string]interface{}{
+				"status":  models.PaymentSucceeded,
+				"paid_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.First(&appointment, payment.AppointmentID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&appointment).Updates(map[string]interface{}{
+				"is_paid":           true,
+				"payment_amount":    payment.Amount,
+				"payment_reference": payment.IntentID,
+			}).Error
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Intents created outside this API are not ours to handle
+			c.JSON(http.StatusOK, gin.H{"received": true})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to handle payment event %s: %v", event.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to handle event"})
+			return
+		}
+
+		if appointment.ID != 0 && appointment.Status == models.StatusCancelled {
+			if _, err := refundPayment(c.Request.Context(), db, gateway, appointment.ID, appointment.RefundDue()); err != nil {
+				log.Printf("Failed to refund cancelled appointment %d: %v", appointment.ID, err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"received": true})
+	}
+}
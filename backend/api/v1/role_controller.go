@@ -0,0 +1,319 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/middleware"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+)
+
+// roleNamePattern restricts custom role names to lowercase identifiers
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+
+// CreateRoleRequest represents the request body for defining a custom role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// RolePermissionsRequest represents the request body for replacing a role's permissions
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// UpdateUserRoleRequest represents the request body for changing a user's role
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// RoleResponse describes a role together with its permissions
+type RoleResponse struct {
+	Name        models.UserRole `json:"name"`
+	Description string          `json:"description"`
+	BuiltIn     bool            `json:"built_in"`
+	Permissions []string        `json:"permissions"`
+}
+
+// validatePermissions returns the first unknown permission, if any
+func validatePermissions(permissions []string) (string, bool) {
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			return p, false
+		}
+	}
+	return "", true
+}
+
+// loadRolePermissions returns the permissions of every role keyed by role name
+func loadRolePermissions(db *gorm.DB) (map[models.UserRole][]string, error) {
+	var rows []models.RolePermission
+	if err := db.Order("permission ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[models.UserRole][]string)
+	for _, row := range rows {
+		permissions[row.Role] = append(permissions[row.Role], row.Permission)
+	}
+	return permissions, nil
+}
+
+// ListPermissions returns every permission that can be assigned (admin only)
+func ListPermissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": models.Permissions})
+	}
+}
+
+// ListRoles returns all roles with their permissions (admin only)
+func ListRoles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var roles []models.Role
+		if err := db.Order("name ASC").Find(&roles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+			return
+		}
+
+		permissions, err := loadRolePermissions(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+			return
+		}
+
+		data := make([]RoleResponse, 0, len(roles))
+		for _, role := range roles {
+			rolePermissions := permissions[role.Name]
+			if rolePermissions == nil {
+				rolePermissions = []string{}
+			}
+			data = append(data, RoleResponse{
+				Name:        role.Name,
+				Description: role.Description,
+				BuiltIn:     role.BuiltIn,
+				Permissions: rolePermissions,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"data": data})
+	}
+}
+
+// CreateRole defines a custom role (admin only)
+func CreateRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !roleNamePattern.MatchString(req.Name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role name must be 2-32 lowercase letters, digits or underscores"})
+			return
+		}
+		if p, ok := validatePermissions(req.Permissions); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + p})
+			return
+		}
+
+		name := models.UserRole(req.Name)
+		exists, err := models.RoleExists(db, name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+			return
+		}
+		if exists {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&models.Role{Name: name, Description: req.Description}).Error; err != nil {
+				return err
+			}
+			return models.SetRolePermissions(tx, name, req.Permissions)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+			return
+		}
+
+		permissions := req.Permissions
+		if permissions == nil {
+			permissions = []string{}
+		}
+		sort.Strings(permissions)
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Role created successfully",
+			"role": RoleResponse{
+				Name:        name,
+				Description: req.Description,
+				Permissions: permissions,
+			},
+		})
+	}
+}
+
+// UpdateRolePermissions replaces the permissions granted to a role (admin only)
+func UpdateRolePermissions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := models.UserRole(c.Param("role"))
+
+		var req RolePermissionsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if p, ok := validatePermissions(req.Permissions); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + p})
+			return
+		}
+
+		// Keep administrators from locking themselves out of role management
+		if name == models.AdminRole && !containsPermission(req.Permissions, models.PermissionManageRoles) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The admin role must keep the " + models.PermissionManageRoles + " permission"})
+			return
+		}
+
+		exists, err := models.RoleExists(db, name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			return
+		}
+
+		if err := models.SetRolePermissions(db, name, req.Permissions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+
+		sort.Strings(req.Permissions)
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Role permissions updated successfully",
+			"role":        name,
+			"permissions": req.Permissions,
+		})
+	}
+}
+
+// DeleteRole removes a custom role that no user is assigned to (admin only)
+func DeleteRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var role models.Role
+		if err := db.Where("name = ?", c.Param("role")).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+			return
+		}
+		if role.BuiltIn {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles cannot be deleted"})
+			return
+		}
+
+		var users int64
+		if err := db.Model(&models.User{}).Where("role = ?", role.Name).Count(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+			return
+		}
+		if users > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role is still assigned to users", "users": users})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := models.SetRolePermissions(tx, role.Name, nil); err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&role).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+	}
+}
+
+// UpdateUserRole assigns a role to a user (admin only). The user's tokens are
+// revoked so that the new role takes effect on their next login.
+func UpdateUserRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUserID, _ := c.Get("userID")
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var req UpdateUserRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if uint(id) == currentUserID.(uint) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+			return
+		}
+
+		role := models.UserRole(req.Role)
+		exists, err := models.RoleExists(db, role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + req.Role})
+			return
+		}
+
+		// The role only changes together with the revocation of old-role tokens
+		err = db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.User{}).Where("id = ?", id).UpdateColumn("role", role)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			if err := middleware.RevokeAllTokens(tx, uint(id)); err != nil {
+				return err
+			}
+			if role == models.DoctorRole {
+				return ensureDoctorProfile(tx, uint(id))
+			}
+			return nil
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully", "role": role})
+	}
+}
+
+func containsPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
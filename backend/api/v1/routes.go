@@ -2,26 +2,71 @@ package v1
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
 	"github.com/sandipdas/go-doctor-booking/backend/middleware"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/oidc"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
 	"gorm.io/gorm"
 )
 
 // SetupRoutes initializes all the API routes
 func SetupRoutes(router *gin.Engine, db *gorm.DB) {
+	mail := mailer.NewSMTPMailerFromEnv()
+	gateway, paymentsEnabled := payments.NewGatewayFromEnv()
+
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", GetJWKS())
+
 	api := router.Group("/api/v1")
 	{
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", RegisterUser(db))
+			auth.POST("/register", RegisterUser(db, mail))
 			auth.POST("/login", LoginUser(db))
+			auth.POST("/refresh", RefreshToken(db))
+			auth.POST("/forgot-password", ForgotPassword(db, mail))
+			auth.POST("/reset-password", ResetPassword(db))
+			auth.GET("/verify-email", VerifyEmail(db))
+			auth.POST("/2fa/verify", VerifyTwoFactorLogin(db))
+
+			// Two-factor enrollment also accepts enrollment tokens from LoginUser
+			enroll := auth.Group("/2fa")
+			enroll.Use(middleware.EnrollmentAuthMiddleware(db))
+			{
+				enroll.POST("/setup", SetupTwoFactor(db))
+				enroll.POST("/enable", EnableTwoFactor(db))
+			}
+
+			// Single sign-on, only when an identity provider is configured
+			if oidcConfig, ok := oidc.LoadConfigFromEnv(); ok {
+				provider := oidc.NewProvider(oidcConfig)
+				auth.GET("/oidc/login", OIDCLogin(db, provider))
+				auth.GET("/oidc/callback", OIDCCallback(db, provider))
+			}
+		}
+
+		// Payment gateway events, only when online payments are enabled
+		if paymentsEnabled {
+			api.POST("/payments/webhook", PaymentWebhook(db, gateway))
 		}
 
 		// Protected routes
 		authorized := api.Group("/")
-		authorized.Use(middleware.AuthMiddleware())
+		authorized.Use(middleware.AuthMiddleware(db))
 		{
+			// Session routes
+			session := authorized.Group("/auth")
+			session.Use(middleware.UserOnlyMiddleware())
+			{
+				session.POST("/logout", LogoutUser(db))
+				session.POST("/2fa/disable", DisableTwoFactor(db))
+				session.POST("/verify-email/resend", ResendVerificationEmail(db, mail))
+			}
+
 			// User routes
 			users := authorized.Group("/users")
+			users.Use(middleware.UserOnlyMiddleware())
 			{
 				users.GET("/profile", GetUserProfile(db))
 				users.PUT("/profile", UpdateUserProfile(db))
@@ -35,33 +80,132 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 				api.GET("/doctors/:id", GetDoctorProfile(db))
 
 				// Protected doctor routes
-				doctors.Use(middleware.RoleMiddleware("doctor", "admin"))
+				doctors.GET("/dashboard", middleware.RequirePermission(db, models.PermissionViewDoctorDashboard), GetDoctorDashboard(db))
+				doctors.GET("/appointments", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), GetDoctorAppointments(db))
+				doctors.PUT("/appointments/:id/status", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), UpdateAppointmentStatus(db, mail, gateway))
+				doctors.PUT("/appointments/:id/reschedule", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), RescheduleDoctorAppointment(db))
+				doctors.POST("/appointments/:id/follow-up", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), CreateFollowUp(db, mail, gateway))
+				doctors.PUT("/appointments/:id/no-show", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), MarkNoShow(db))
+
+				schedules := doctors.Group("")
+				schedules.Use(middleware.RequirePermission(db, models.PermissionManageSchedules))
 				{
-					doctors.GET("/dashboard", GetDoctorDashboard(db))
-					doctors.POST("/schedules", CreateSchedule(db))
-					doctors.GET("/appointments", GetDoctorAppointments(db))
-					doctors.PUT("/appointments/:id/status", UpdateAppointmentStatus(db))
+					schedules.POST("/schedules", CreateSchedule(db))
+					schedules.POST("/schedules/bulk", BulkCreateSchedules(db, mail, gateway))
+					schedules.GET("/schedules", ListSchedules(db))
+					schedules.GET("/schedules/:id", GetSchedule(db))
+					schedules.PUT("/schedules/:id", UpdateSchedule(db, mail, gateway))
+					schedules.DELETE("/schedules/:id", DeleteSchedule(db, mail, gateway))
+					schedules.PUT("/time-zone", UpdateDoctorTimeZone(db, mail, gateway))
+					schedules.GET("/schedule-templates", ListScheduleTemplates(db))
+					schedules.POST("/schedule-templates", CreateScheduleTemplate(db, mail, gateway))
+					schedules.PUT("/schedule-templates/:id", UpdateScheduleTemplate(db, mail, gateway))
+					schedules.DELETE("/schedule-templates/:id", DeleteScheduleTemplate(db, mail, gateway))
+					schedules.GET("/schedule-exceptions", ListScheduleExceptions(db))
+					schedules.POST("/schedule-exceptions", CreateScheduleException(db, mail, gateway))
+					schedules.DELETE("/schedule-exceptions/:id", DeleteScheduleException(db))
+					schedules.GET("/appointment-types", ListAppointmentTypes(db))
+					schedules.POST("/appointment-types", CreateAppointmentType(db))
+					schedules.PUT("/appointment-types/:id", UpdateAppointmentType(db))
+					schedules.DELETE("/appointment-types/:id", DeleteAppointmentType(db))
+					schedules.GET("/cancellation-policy", GetCancellationPolicy(db))
+					schedules.PUT("/cancellation-policy", UpdateCancellationPolicy(db))
 				}
 			}
 
 			// Patient routes
 			patients := authorized.Group("/patients")
-			patients.Use(middleware.RoleMiddleware("patient"))
 			{
-				patients.GET("/doctors", ListDoctors(db))
-				patients.GET("/doctors/:id/availability", GetDoctorAvailability(db))
-				patients.POST("/appointments", BookAppointment(db))
-				patients.GET("/appointments", GetPatientAppointments(db))
-				patients.PUT("/appointments/:id/cancel", CancelAppointment(db))
+				patients.GET("/doctors", middleware.RequirePermission(db, models.PermissionViewAvailability), ListDoctors(db))
+				// Availability is also open to API keys with the doctors:read scope
+				patients.GET("/doctors/:id/availability", middleware.ScopeMiddleware(models.ScopeDoctorsRead),
+					middleware.RequirePermission(db, models.PermissionViewAvailability), GetDoctorAvailability(db))
+				patients.GET("/doctors/:id/appointment-types", middleware.RequirePermission(db, models.PermissionViewAvailability), ListDoctorAppointmentTypes(db))
+				patients.GET("/doctors/:id/cancellation-policy", middleware.RequirePermission(db, models.PermissionViewAvailability), GetDoctorCancellationPolicy(db))
+				patients.POST("/appointments", middleware.RequirePermission(db, models.PermissionBookAppointment), BookAppointment(db, gateway))
+				patients.POST("/slots/hold", middleware.RequirePermission(db, models.PermissionBookAppointment), HoldSlot(db))
+				patients.DELETE("/slots/hold/:id", middleware.RequirePermission(db, models.PermissionBookAppointment), ReleaseSlotHold(db))
+				patients.GET("/appointments", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), GetPatientAppointments(db))
+				patients.PUT("/appointments/:id/cancel", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), CancelAppointment(db, mail, gateway))
+				patients.POST("/appointments/:id/pay", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), PayAppointment(db, gateway))
+				patients.GET("/appointments/:id/invoice", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), DownloadInvoice(db, models.InvoiceKindInvoice))
+				patients.GET("/appointments/:id/receipt", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), DownloadInvoice(db, models.InvoiceKindReceipt))
+				patients.GET("/invoices", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), ListInvoices(db))
+				patients.POST("/appointments/:id/eligibility", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), CheckEligibility(db, gateway))
+				patients.GET("/insurance", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), ListInsurancePolicies(db))
+				patients.POST("/insurance", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), CreateInsurancePolicy(db))
+				patients.PUT("/insurance/:id", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), UpdateInsurancePolicy(db))
+				patients.DELETE("/insurance/:id", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), DeleteInsurancePolicy(db))
+				patients.GET("/claims", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), ListPatientClaims(db))
+				patients.PUT("/appointments/:id/reschedule", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), RescheduleAppointment(db))
+				patients.POST("/series", middleware.RequirePermission(db, models.PermissionBookAppointment), CreateAppointmentSeries(db, gateway))
+				patients.GET("/series", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), ListAppointmentSeries(db))
+				patients.GET("/series/:id", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), GetAppointmentSeries(db))
+				patients.GET("/follow-ups", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), ListFollowUps(db))
+				patients.POST("/follow-ups/:id/book", middleware.RequirePermission(db, models.PermissionBookAppointment), BookFollowUp(db, gateway))
+				patients.POST("/waitlist", middleware.RequirePermission(db, models.PermissionBookAppointment), JoinWaitlist(db))
+				patients.GET("/waitlist", middleware.RequirePermission(db, models.PermissionBookAppointment), ListWaitlist(db))
+				patients.DELETE("/waitlist/:id", middleware.RequirePermission(db, models.PermissionBookAppointment), LeaveWaitlist(db, mail))
+				patients.POST("/waitlist/offers/:id/accept", middleware.RequirePermission(db, models.PermissionBookAppointment), AcceptWaitlistOffer(db, mail, gateway))
+				patients.POST("/waitlist/offers/:id/decline", middleware.RequirePermission(db, models.PermissionBookAppointment), DeclineWaitlistOffer(db, mail))
 			}
 
-			// Admin routes
+			// Staff routes for booking on behalf of patients
+			staff := authorized.Group("/staff")
+			staff.Use(middleware.RequirePermission(db, models.PermissionBookOnBehalf))
+			{
+				staff.GET("/patients", SearchPatients(db))
+				staff.POST("/patients", CreateStaffPatient(db))
+				staff.POST("/appointments", StaffBookAppointment(db, gateway))
+				staff.PUT("/appointments/:id/reschedule", StaffRescheduleAppointment(db))
+				staff.PUT("/appointments/:id/cancel", StaffCancelAppointment(db, mail, gateway))
+			}
+
+			// Admin routes. Reporting and user status routes are also open to API keys
+			// with the matching scope.
 			admin := authorized.Group("/admin")
-			admin.Use(middleware.RoleMiddleware("admin"))
 			{
-				admin.GET("/users", ListAllUsers(db))
-				admin.PUT("/users/:id/status", UpdateUserStatus(db))
-				admin.GET("/appointments", ListAllAppointments(db))
+				admin.GET("/users", middleware.ScopeMiddleware(models.ScopeUsersRead),
+					middleware.RequirePermission(db, models.PermissionReadUsers), ListAllUsers(db))
+				admin.PUT("/users/:id/status", middleware.ScopeMiddleware(models.ScopeUsersWrite),
+					middleware.RequirePermission(db, models.PermissionManageUsers), UpdateUserStatus(db))
+				admin.POST("/users/:id/unlock", middleware.RequirePermission(db, models.PermissionManageUsers), UnlockUserLogin(db))
+				admin.PUT("/users/:id/role", middleware.RequirePermission(db, models.PermissionManageRoles), UpdateUserRole(db))
+				admin.GET("/appointments", middleware.ScopeMiddleware(models.ScopeAppointmentsRead),
+					middleware.RequirePermission(db, models.PermissionReadAllAppointments), ListAllAppointments(db))
+				admin.GET("/appointments/:id/invoice", middleware.RequirePermission(db, models.PermissionReadAllAppointments), AdminDownloadInvoice(db, models.InvoiceKindInvoice))
+				admin.GET("/appointments/:id/receipt", middleware.RequirePermission(db, models.PermissionReadAllAppointments), AdminDownloadInvoice(db, models.InvoiceKindReceipt))
+				admin.GET("/invoices", middleware.RequirePermission(db, models.PermissionReadAllAppointments), ListAllInvoices(db))
+
+				claims := admin.Group("")
+				claims.Use(middleware.RequirePermission(db, models.PermissionManageClaims))
+				{
+					claims.POST("/appointments/:id/eligibility", AdminCheckEligibility(db, gateway))
+					claims.GET("/claims", ListClaims(db))
+					claims.POST("/claims", CreateClaim(db))
+					claims.GET("/claims/:id", GetClaim(db))
+					claims.PUT("/claims/:id/status", UpdateClaimStatus(db))
+				}
+
+				security := admin.Group("")
+				security.Use(middleware.RequirePermission(db, models.PermissionManageSecurity))
+				{
+					security.GET("/security-policy", GetSecurityPolicy(db))
+					security.PUT("/security-policy", UpdateSecurityPolicy(db))
+					security.GET("/api-keys", ListAPIKeys(db))
+					security.POST("/api-keys", CreateAPIKey(db))
+					security.DELETE("/api-keys/:id", RevokeAPIKey(db))
+				}
+
+				roles := admin.Group("")
+				roles.Use(middleware.RequirePermission(db, models.PermissionManageRoles))
+				{
+					roles.GET("/permissions", ListPermissions())
+					roles.GET("/roles", ListRoles(db))
+					roles.POST("/roles", CreateRole(db))
+					roles.PUT("/roles/:role/permissions", UpdateRolePermissions(db))
+					roles.DELETE("/roles/:role", DeleteRole(db))
+				}
 			}
 		}
 	}
@@ -0,0 +1,603 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+)
+
+// Modes for schedule changes that affect booked appointments
+const (
+	// scheduleModeReport rejects the change and lists the affected appointments
+	scheduleModeReport = "report"
+	// scheduleModeCascade cancels the affected appointments, refunds any payments
+	// in full and notifies their patients
+	scheduleModeCascade = "cascade"
+)
+
+// maxBulkScheduleDays limits the date range of a bulk schedule request
+const maxBulkScheduleDays = 366
+
+// scheduleChangeCancelReason is recorded on appointments cancelled by a cascade
+const scheduleChangeCancelReason = "The doctor is no longer available at this time"
+
+// UpdateScheduleRequest represents the request body for editing a schedule entry
+type UpdateScheduleRequest struct {
+	Date        string `json:"date" binding:"required"`       // Format: "2006-01-02"
+	StartTime   string `json:"start_time" binding:"required"` // Format: "15:04"
+	EndTime     string `json:"end_time" binding:"required"`   // Format: "15:04"
+	IsAvailable *bool  `json:"is_available"`
+}
+
+// BulkScheduleRequest creates the same window on every matching date in a range.
+// IsAvailable false blocks the window out instead.
+type BulkScheduleRequest struct {
+	StartDate   string   `json:"start_date" binding:"required"` // Format: "2006-01-02"
+	EndDate     string   `json:"end_date" binding:"required"`   // Format: "2006-01-02"
+	Weekdays    []string `json:"weekdays"`                      // Optional BYDAY codes, e.g. ["MO", "WE"]
+	StartTime   string   `json:"start_time" binding:"required"` // Format: "15:04"
+	EndTime     string   `json:"end_time" binding:"required"`   // Format: "15:04"
+	IsAvailable *bool    `json:"is_available"`
+}
+
+// scheduleOverlapError reports an existing entry of the same kind overlapping a new one
+type scheduleOverlapError struct {
+	Existing models.Schedule
+}
+
+func (e *scheduleOverlapError) Error() string {
+	return "Schedule overlaps an existing entry"
+}
+
+// affectedAppointmentsError reports booked appointments a schedule change would leave
+// outside the doctor's working hours
+type affectedAppointmentsError struct {
+	Appointments []models.Appointment
+}
+
+func (e *affectedAppointmentsError) Error() string {
+	return "Schedule change affects booked appointments"
+}
+
+// scheduleMode returns the mode query parameter, defaulting to report
+func scheduleMode(c *gin.Context) (string, bool) {
+	mode := c.DefaultQuery("mode", scheduleModeReport)
+	return mode, mode == scheduleModeReport || mode == scheduleModeCascade
+}
+
+// findOverlappingSchedule returns an entry of the same kind that overlaps the
+// given window on date, or nil. Blocked entries may overlap open ones.
+func findOverlappingSchedule(tx *gorm.DB, doctorID uint, date time.Time, startTime, endTime string, isAvailable bool, excludeID uint) (*models.Schedule, error) {
+	query := onDate(tx, date).Where("doctor_id = ? AND is_available = ? AND start_time < ? AND end_time > ?",
+		doctorID, isAvailable, endTime, startTime)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+
+	var existing models.Schedule
+	err := query.First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// findAffectedAppointments returns the doctor's upcoming active appointments on
+// date, in the doctor's time zone, that no longer fit the doctor's open windows
+func findAffectedAppointments(tx *gorm.DB, doctor models.Doctor, date time.Time) ([]models.Appointment, error) {
+	day := dayRange(date, doctor.Location())
+	var appointments []models.Appointment
+	if err := tx.Where("doctor_id = ? AND status IN ? AND start_time >= ? AND start_time < ? AND start_time > ?",
+		doctor.ID, []string{models.StatusPending, models.StatusConfirmed, models.StatusRescheduled},
+		day.Start, day.End, time.Now()).
+		Order("start_time ASC").
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+	if len(appointments) == 0 {
+		return nil, nil
+	}
+
+	open, blocked, err := loadWindows(tx, doctor, date)
+	if err != nil {
+		return nil, err
+	}
+
+	var affected []models.Appointment
+	for _, appt := range appointments {
+		slot := timeRange{Start: appt.StartTime, End: appt.EndTime}
+		inWindow := false
+		for _, window := range open {
+			if window.contains(slot) {
+				inWindow = true
+				break
+			}
+		}
+		if !inWindow || overlapsAny(slot, blocked) {
+			affected = append(affected, appt)
+		}
+	}
+	return affected, nil
+}
+
+// applyScheduleChange runs change with the doctor locked and then checks the
+// given dates for appointments the change leaves outside working hours. In
+// report mode these abort the change; in cascade mode they are cancelled and
+// returned so that their patients can be notified.
+func applyScheduleChange(c *gin.Context, db *gorm.DB, doctorID uint, mode string, dates []time.Time, change func(tx *gorm.DB) error) ([]models.Appointment, error) {
+	userID, _ := c.Get("userID")
+	role := models.UserRole(c.GetString("userRole"))
+
+	var cancelled []models.Appointment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		doctor, err := lockDoctor(tx, doctorID)
+		if err != nil {
+			return err
+		}
+		if err := change(tx); err != nil {
+			return err
+		}
+		// Reload in case the change touched the doctor row, e.g. its time zone
+		if err := tx.First(&doctor, doctorID).Error; err != nil {
+			return err
+		}
+
+		var affected []models.Appointment
+		seen := make(map[string]bool)
+		for _, date := range dates {
+			key := date.Format("2006-01-02")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			appointments, err := findAffectedAppointments(tx, doctor, date)
+			if err != nil {
+				return err
+			}
+			affected = append(affected, appointments...)
+		}
+		if len(affected) == 0 {
+			return nil
+		}
+		if mode != scheduleModeCascade {
+			return &affectedAppointmentsError{Appointments: affected}
+		}
+
+		for i := range affected {
+			appt := &affected[i]
+			if err := appt.Cancel(tx, userID.(uint), role, scheduleChangeCancelReason, 0); err != nil {
+				return err
+			}
+		}
+		cancelled = affected
+		return nil
+	})
+	return cancelled, err
+}
+
+// notifyCancelledAppointments emails the patients of appointments cancelled by
+// a schedule change, quoting times in the doctor's zone. Failures are logged
+// and do not fail the request.
+func notifyCancelledAppointments(db *gorm.DB, m mailer.Mailer, doctor models.Doctor, appointments []models.Appointment) {
+	loc := doctor.Location()
+	for _, appt := range appointments {
+		var patient models.User
+		if err := db.First(&patient, appt.PatientID).Error; err != nil {
+			log.Printf("Failed to load patient %d for cancellation notice: %v", appt.PatientID, err)
+			continue
+		}
+		// Walk-in patients created by staff have no real email address
+		if strings.HasSuffix(patient.Email, "@"+placeholderEmailDomain) {
+			continue
+		}
+
+		start := appt.StartTime.In(loc)
+		if err := m.Send(mailer.Message{
+			To:      patient.Email,
+			Subject: "Your appointment has been cancelled",
+			Body: fmt.Sprintf("Hi %s,\n\nYour appointment on %s at %s has been cancelled because the doctor is no longer available at that time.\n\nPlease book a new appointment at your convenience.\n",
+				patient.Name, start.Format("Monday, 2 January 2006"), start.Format("15:04 MST")),
+		}); err != nil {
+			log.Printf("Failed to send cancellation notice for appointment %d: %v", appt.ID, err)
+		}
+	}
+}
+
+// refundScheduleCancellations refunds appointments cancelled by a schedule
+// change in full, as no late cancellation fee applies to them
+func refundScheduleCancellations(c *gin.Context, db *gorm.DB, gateway payments.PaymentGateway, appointments []models.Appointment) float64 {
+	targets := make([]*models.Appointment, 0, len(appointments))
+	for i := range appointments {
+		targets = append(targets, &appointments[i])
+	}
+	return refundCancelled(c.Request.Context(), db, gateway, targets)
+}
+
+// respondScheduleChangeError writes the HTTP response for an error from applyScheduleChange
+func respondScheduleChangeError(c *gin.Context, err error, fallback string) {
+	var overlap *scheduleOverlapError
+	var affected *affectedAppointmentsError
+	switch {
+	case errors.As(err, &overlap):
+		c.JSON(http.StatusConflict, gin.H{"error": overlap.Error(), "conflicting_schedule": overlap.Existing})
+	case errors.As(err, &affected):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":                 affected.Error() + ". Retry with mode=cascade to cancel and refund them and notify the patients.",
+			"affected_appointments": affected.Appointments,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// appointmentIDs returns the IDs of the given appointments
+func appointmentIDs(appointments []models.Appointment) []uint {
+	ids := make([]uint, 0, len(appointments))
+	for _, appt := range appointments {
+		ids = append(ids, appt.ID)
+	}
+	return ids
+}
+
+// ListSchedules returns the logged-in doctor's schedule entries, optionally
+// limited to a from/to date range
+func ListSchedules(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		query := db.Where("doctor_id = ?", doctor.ID).Order("date ASC, start_time ASC")
+		query, err = inDateRange(query, c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+
+		var schedules []models.Schedule
+		if err := query.Find(&schedules).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedules"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": schedules})
+	}
+}
+
+// GetSchedule returns one of the logged-in doctor's schedule entries
+func GetSchedule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		var schedule models.Schedule
+		if err := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctor.ID).First(&schedule).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+			return
+		}
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+// UpdateSchedule edits one of the logged-in doctor's schedule entries. Changes
+// that would leave booked appointments outside working hours are rejected
+// unless mode=cascade, which cancels and refunds them and notifies the patients.
+func UpdateSchedule(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+		mode, ok := scheduleMode(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be report or cascade"})
+			return
+		}
+
+		var schedule models.Schedule
+		if err := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctor.ID).First(&schedule).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+			return
+		}
+
+		var req UpdateScheduleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		date, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		startTime, endTime, err := normalizeClockRange(req.StartTime, req.EndTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		previousDate := schedule.Date.UTC()
+		schedule.Date = date
+		schedule.StartTime = startTime
+		schedule.EndTime = endTime
+		if req.IsAvailable != nil {
+			schedule.IsAvailable = *req.IsAvailable
+		}
+
+		cancelled, err := applyScheduleChange(c, db, doctor.ID, mode, []time.Time{previousDate, date}, func(tx *gorm.DB) error {
+			existing, err := findOverlappingSchedule(tx, doctor.ID, date, startTime, endTime, schedule.IsAvailable, schedule.ID)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				return &scheduleOverlapError{Existing: *existing}
+			}
+			return tx.Model(&schedule).Select("date", "start_time", "end_time", "is_available").Updates(&schedule).Error
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to update schedule")
+			return
+		}
+		refunded := refundScheduleCancellations(c, db, gateway, cancelled)
+		notifyCancelledAppointments(db, m, doctor, cancelled)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":                "Schedule updated successfully",
+			"schedule":               schedule,
+			"cancelled_appointments": appointmentIDs(cancelled),
+			"refunded_amount":        refunded,
+		})
+	}
+}
+
+// DeleteSchedule removes one of the logged-in doctor's schedule entries. If
+// booked appointments depend on it the deletion is rejected unless
+// mode=cascade, which cancels and refunds them and notifies the patients.
+func DeleteSchedule(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+		mode, ok := scheduleMode(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be report or cascade"})
+			return
+		}
+
+		var schedule models.Schedule
+		if err := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctor.ID).First(&schedule).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+			return
+		}
+
+		cancelled, err := applyScheduleChange(c, db, doctor.ID, mode, []time.Time{schedule.Date.UTC()}, func(tx *gorm.DB) error {
+			return tx.Delete(&schedule).Error
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to delete schedule")
+			return
+		}
+		refunded := refundScheduleCancellations(c, db, gateway, cancelled)
+		notifyCancelledAppointments(db, m, doctor, cancelled)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":                "Schedule deleted successfully",
+			"cancelled_appointments": appointmentIDs(cancelled),
+			"refunded_amount":        refunded,
+		})
+	}
+}
+
+// BulkCreateSchedules creates the same open or blocked window on every date in
+// a range, optionally limited to some weekdays. Nothing is created if any date
+// overlaps an existing entry of the same kind.
+func BulkCreateSchedules(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+		mode, ok := scheduleMode(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be report or cascade"})
+			return
+		}
+
+		var req BulkScheduleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		from, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format. Use YYYY-MM-DD"})
+			return
+		}
+		to, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format. Use YYYY-MM-DD"})
+			return
+		}
+		if to.Before(from) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+			return
+		}
+		if to.Sub(from) >= maxBulkScheduleDays*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Date range must not exceed %d days", maxBulkScheduleDays)})
+			return
+		}
+
+		startTime, endTime, err := normalizeClockRange(req.StartTime, req.EndTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		weekdays := make(map[time.Weekday]bool)
+		if len(req.Weekdays) > 0 {
+			parsed, err := models.ParseWeekdays(strings.Join(req.Weekdays, ","))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid weekdays: " + err.Error()})
+				return
+			}
+			for _, day := range parsed {
+				weekdays[day] = true
+			}
+		}
+
+		isAvailable := true
+		if req.IsAvailable != nil {
+			isAvailable = *req.IsAvailable
+		}
+
+		var schedules []models.Schedule
+		var dates []time.Time
+		for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+			if len(weekdays) > 0 && !weekdays[date.Weekday()] {
+				continue
+			}
+			dates = append(dates, date)
+			schedules = append(schedules, models.Schedule{
+				DoctorID:  doctor.ID,
+				Date:      date,
+				StartTime: startTime,
+				EndTime:   endTime,
+			})
+		}
+		if len(schedules) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No dates in the range match the given weekdays"})
+			return
+		}
+
+		cancelled, err := applyScheduleChange(c, db, doctor.ID, mode, dates, func(tx *gorm.DB) error {
+			for _, schedule := range schedules {
+				existing, err := findOverlappingSchedule(tx, doctor.ID, schedule.Date, startTime, endTime, isAvailable, 0)
+				if err != nil {
+					return err
+				}
+				if existing != nil {
+					return &scheduleOverlapError{Existing: *existing}
+				}
+			}
+			if err := tx.Create(&schedules).Error; err != nil {
+				return err
+			}
+			// IsAvailable defaults to true in the database, so blocked entries are set afterwards
+			if !isAvailable {
+				ids := make([]uint, 0, len(schedules))
+				for i := range schedules {
+					schedules[i].IsAvailable = false
+					ids = append(ids, schedules[i].ID)
+				}
+				return tx.Model(&models.Schedule{}).Where("id IN ?", ids).Update("is_available", false).Error
+			}
+			return nil
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to create schedules")
+			return
+		}
+		refunded := refundScheduleCancellations(c, db, gateway, cancelled)
+		notifyCancelledAppointments(db, m, doctor, cancelled)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":                fmt.Sprintf("%d schedules created successfully", len(schedules)),
+			"schedules":              schedules,
+			"cancelled_appointments": appointmentIDs(cancelled),
+			"refunded_amount":        refunded,
+		})
+	}
+}
+
+// defaultTimeZone is the clinic's zone, used for doctors that do not choose one
+func defaultTimeZone() string {
+	return config.GetEnv("DEFAULT_TIME_ZONE", models.DefaultTimeZone)
+}
+
+// TimeZoneRequest changes the zone a doctor's schedule is expressed in
+type TimeZoneRequest struct {
+	TimeZone string `json:"time_zone" binding:"required"` // IANA name, e.g. "America/New_York"
+}
+
+// UpdateDoctorTimeZone changes the logged-in doctor's time zone. Schedule
+// entries keep their wall-clock times, so upcoming appointments that no longer
+// fit are reported, or cancelled with mode=cascade.
+func UpdateDoctorTimeZone(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+		mode, ok := scheduleMode(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be report or cascade"})
+			return
+		}
+
+		var req TimeZoneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		loc, err := models.LoadTimeZone(req.TimeZone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Every day with an upcoming appointment, as a date in the new zone
+		var upcoming []models.Appointment
+		if err := db.Where("doctor_id = ? AND status IN ? AND start_time > ?",
+			doctor.ID, []string{models.StatusPending, models.StatusConfirmed, models.StatusRescheduled}, time.Now()).
+			Find(&upcoming).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update time zone"})
+			return
+		}
+		dates := make([]time.Time, 0, len(upcoming))
+		for _, appt := range upcoming {
+			dates = append(dates, localDate(appt.StartTime, loc))
+		}
+
+		cancelled, err := applyScheduleChange(c, db, doctor.ID, mode, dates, func(tx *gorm.DB) error {
+			return tx.Model(&models.Doctor{}).Where("id = ?", doctor.ID).Update("time_zone", loc.String()).Error
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to update time zone")
+			return
+		}
+		doctor.TimeZone = loc.String()
+		refunded := refundScheduleCancellations(c, db, gateway, cancelled)
+		notifyCancelledAppointments(db, m, doctor, cancelled)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":                "Time zone updated successfully",
+			"time_zone":              doctor.TimeZone,
+			"cancelled_appointments": appointmentIDs(cancelled),
+			"refunded_amount":        refunded,
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+)
+
+// ScheduleTemplateRequest represents a weekly recurring schedule. The
+// recurrence is given either as an RRULE or as weekdays with an interval.
+type ScheduleTemplateRequest struct {
+	Label          string   `json:"label"`
+	RRule          string   `json:"rrule"`                                     // e.g. "FREQ=WEEKLY;BYDAY=MO,WE"
+	Weekdays       []string `json:"weekdays"`                                  // e.g. ["MO", "WE"]
+	Interval       int      `json:"interval" binding:"omitempty,min=1,max=52"` // Repeat every n weeks
+	StartTime      string   `json:"start_time" binding:"required"`             // Format: "15:04"
+	EndTime        string   `json:"end_time" binding:"required"`               // Format: "15:04"
+	EffectiveFrom  string   `json:"effective_from" binding:"required"`         // Format: "2006-01-02"
+	EffectiveUntil string   `json:"effective_until"`                           // Format: "2006-01-02"
+}
+
+// ScheduleExceptionRequest represents a holiday or leave. Omitting the times
+// blocks the whole day.
+type ScheduleExceptionRequest struct {
+	Date      string `json:"date" binding:"required"` // Format: "2006-01-02"
+	StartTime string `json:"start_time"`              // Format: "15:04"
+	EndTime   string `json:"end_time"`                // Format: "15:04"
+	Reason    string `json:"reason"`
+}
+
+// ScheduleTemplateResponse is a template together with its RRULE
+type ScheduleTemplateResponse struct {
+	models.ScheduleTemplate
+	RRule string `json:"rrule"`
+}
+
+var errNotDoctor = errors.New("only doctors can manage schedules")
+
+// doctorForUser returns the doctor profile of the logged-in user
+func doctorForUser(c *gin.Context, db *gorm.DB) (models.Doctor, error) {
+	userID, _ := c.Get("userID")
+
+	var doctor models.Doctor
+	if err := db.Where("user_id = ?", userID).First(&doctor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return doctor, errNotDoctor
+		}
+		return doctor, err
+	}
+	return doctor, nil
+}
+
+// respondDoctorError writes the HTTP response for an error from doctorForUser
+func respondDoctorError(c *gin.Context, err error) {
+	if errors.Is(err, errNotDoctor) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only doctors can manage schedules"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load doctor"})
+}
+
+// normalizeClockRange validates "15:04" start and end times, checks that end is
+// after start and returns both in canonical zero-padded form
+func normalizeClockRange(startTime, endTime string) (string, string, error) {
+	start, err := time.Parse("15:04", startTime)
+	if err != nil {
+		return "", "", errors.New("Invalid start time format. Use HH:MM")
+	}
+	end, err := time.Parse("15:04", endTime)
+	if err != nil {
+		return "", "", errors.New("Invalid end time format. Use HH:MM")
+	}
+	if !end.After(start) {
+		return "", "", errors.New("End time must be after start time")
+	}
+	return start.Format("15:04"), end.Format("15:04"), nil
+}
+
+// applyTemplateRequest validates req and copies it onto template
+func applyTemplateRequest(template *models.ScheduleTemplate, req ScheduleTemplateRequest) error {
+	startTime, endTime, err := normalizeClockRange(req.StartTime, req.EndTime)
+	if err != nil {
+		return err
+	}
+
+	from, err := time.Parse("2006-01-02", req.EffectiveFrom)
+	if err != nil {
+		return errors.New("Invalid effective_from format. Use YYYY-MM-DD")
+	}
+
+	var until *time.Time
+	if req.EffectiveUntil != "" {
+		parsed, err := time.Parse("2006-01-02", req.EffectiveUntil)
+		if err != nil {
+			return errors.New("Invalid effective_until format. Use YYYY-MM-DD")
+		}
+		until = &parsed
+	}
+
+	var recurrence models.Recurrence
+	switch {
+	case req.RRule != "" && len(req.Weekdays) > 0:
+		return errors.New("Provide either rrule or weekdays, not both")
+	case req.RRule != "":
+		recurrence, err = models.ParseRRule(req.RRule)
+		if err != nil {
+			return errors.New("Invalid rrule: " + err.Error())
+		}
+		if recurrence.Until != nil {
+			if until != nil {
+				return errors.New("Provide either an UNTIL rule part or effective_until, not both")
+			}
+			until = recurrence.Until
+		}
+	default:
+		weekdays, err := models.ParseWeekdays(strings.Join(req.Weekdays, ","))
+		if err != nil {
+			return errors.New("Invalid weekdays: " + err.Error())
+		}
+		recurrence = models.Recurrence{Weekdays: weekdays, Interval: 1}
+		if req.Interval > 0 {
+			recurrence.Interval = req.Interval
+		}
+	}
+
+	if until != nil && until.Before(from) {
+		return errors.New("effective_until must not be before effective_from")
+	}
+
+	template.Label = req.Label
+	template.Weekdays = models.FormatWeekdays(recurrence.Weekdays)
+	template.Interval = recurrence.Interval
+	template.StartTime = startTime
+	template.EndTime = endTime
+	template.EffectiveFrom = from
+	template.EffectiveUntil = until
+	return nil
+}
+
+// templateDates returns the dates, in the doctor's zone, of the doctor's
+// upcoming active appointments that fall within the template's effective range
+func templateDates(db *gorm.DB, doctor models.Doctor, template models.ScheduleTemplate) ([]time.Time, error) {
+	loc := doctor.Location()
+	query := db.Model(&models.Appointment{}).
+		Where("doctor_id = ? AND status IN ? AND start_time > ? AND start_time >= ?",
+			doctor.ID, []string{models.StatusPending, models.StatusConfirmed, models.StatusRescheduled},
+			time.Now(), dayRange(template.EffectiveFrom, loc).Start)
+	if template.EffectiveUntil != nil {
+		query = query.Where("start_time < ?", dayRange(*template.EffectiveUntil, loc).End)
+	}
+
+	var starts []time.Time
+	if err := query.Pluck("start_time", &starts).Error; err != nil {
+		return nil, err
+	}
+	dates := make([]time.Time, 0, len(starts))
+	for _, start := range starts {
+		dates = append(dates, localDate(start, loc))
+	}
+	return dates, nil
+}
+
+// ListScheduleTemplates returns the logged-in doctor's recurring schedules
+func ListScheduleTemplates(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		var templates []models.ScheduleTemplate
+		if err := db.Where("doctor_id = ?", doctor.ID).Order("effective_from ASC, start_time ASC").
+			Find(&templates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule templates"})
+			return
+		}
+
+		data := make([]ScheduleTemplateResponse, 0, len(templates))
+		for _, template := range templates {
+			data = append(data, ScheduleTemplateResponse{ScheduleTemplate: template, RRule: template.RRule()})
+		}
+		c.JSON(http.StatusOK, gin.H{"data": data})
+	}
+}
+
+// CreateScheduleTemplate adds a weekly recurring schedule for the logged-in
+// doctor. Booked appointments it would leave outside working hours are
+// reported, or cancelled and refunded with mode=cascade.
+func CreateScheduleTemplate(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+		mode, ok := scheduleMode(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be report or cascade"})
+			return
+		}
+
+		var req ScheduleTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		template := models.ScheduleTemplate{DoctorID: doctor.ID}
+		if err := applyTemplateRequest(&template, req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		dates, err := templateDates(db, doctor, template)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule template"})
+			return
+		}
+		cancelled, err := applyScheduleChange(c, db, doctor.ID, mode, dates, func(tx *gorm.DB) error {
+			return tx.Create(&template).Error
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to create schedule template")
+			return
+		}
+		refunded := refundScheduleCancellations(c, db, gateway, cancelled)
+		notifyCancelledAppointments(db, m, doctor, cancelled)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":                "Schedule template created successfully",
+			"template":               ScheduleTemplateResponse{ScheduleTemplate: template, RRule: template.RRule()},
+			"cancelled_appointments": appointmentIDs(cancelled),
+			"refunded_amount":        refunded,
+		})
+	}
+}
+
+// UpdateScheduleTemplate replaces one of the logged-in doctor's recurring
+// schedules. Booked appointments under either the old or the new range that
+// no longer fit are reported, or cancelled and refunded with mode=cascade.
+func UpdateScheduleTemplate(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+		mode, ok := scheduleMode(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be report or cascade"})
+			return
+		}
+
+		var template models.ScheduleTemplate
+		if err := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctor.ID).First(&template).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule template not found"})
+			return
+		}
+
+		previous := template
+
+		var req ScheduleTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := applyTemplateRequest(&template, req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		dates, err := templateDates(db, doctor, previous)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule template"})
+			return
+		}
+		newDates, err := templateDates(db, doctor, template)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule template"})
+			return
+		}
+		cancelled, err := applyScheduleChange(c, db, doctor.ID, mode, append(dates, newDates...), func(tx *gorm.DB) error {
+			return tx.Save(&template).Error
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to update schedule template")
+			return
+		}
+		refunded := refundScheduleCancellations(c, db, gateway, cancelled)
+		notifyCancelledAppointments(db, m, doctor, cancelled)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":                "Schedule template updated successfully",
+			"template":               ScheduleTemplateResponse{ScheduleTemplate: template, RRule: template.RRule()},
+			"cancelled_appointments": appointmentIDs(cancelled),
+			"refunded_amount":        refunded,
+		})
+	}
+}
+
+// DeleteScheduleTemplate removes one of the logged-in doctor's recurring
+// schedules. If booked appointments depend on it the deletion is rejected
+// unless mode=cascade, which cancels and refunds them and notifies the patients.
+func DeleteScheduleTemplate(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+		mode, ok := scheduleMode(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be report or cascade"})
+			return
+		}
+
+		var template models.ScheduleTemplate
+		if err := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctor.ID).First(&template).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule template not found"})
+			return
+		}
+
+		dates, err := templateDates(db, doctor, template)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule template"})
+			return
+		}
+		cancelled, err := applyScheduleChange(c, db, doctor.ID, mode, dates, func(tx *gorm.DB) error {
+			return tx.Delete(&template).Error
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to delete schedule template")
+			return
+		}
+		refunded := refundScheduleCancellations(c, db, gateway, cancelled)
+		notifyCancelledAppointments(db, m, doctor, cancelled)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":                "Schedule template deleted successfully",
+			"cancelled_appointments": appointmentIDs(cancelled),
+			"refunded_amount":        refunded,
+		})
+	}
+}
+
+// ListScheduleExceptions returns the logged-in doctor's holidays and leave,
+// optionally limited to a from/to date range
+func ListScheduleExceptions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		query := db.Where("doctor_id = ?", doctor.ID).Order("date ASC")
+		query, err = inDateRange(query, c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+
+		var exceptions []models.ScheduleException
+		if err := query.Find(&exceptions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule exceptions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": exceptions})
+	}
+}
+
+// CreateScheduleException blocks out a date or part of it for the logged-in
+// doctor. Booked appointments in the blocked time are reported, or cancelled
+// and refunded with mode=cascade.
+func CreateScheduleException(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+		mode, ok := scheduleMode(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be report or cascade"})
+			return
+		}
+
+		var req ScheduleExceptionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		date, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		exception := models.ScheduleException{
+			DoctorID: doctor.ID,
+			Date:     date,
+			Reason:   req.Reason,
+		}
+		if req.StartTime != "" || req.EndTime != "" {
+			exception.StartTime, exception.EndTime, err = normalizeClockRange(req.StartTime, req.EndTime)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		cancelled, err := applyScheduleChange(c, db, doctor.ID, mode, []time.Time{date}, func(tx *gorm.DB) error {
+			return tx.Create(&exception).Error
+		})
+		if err != nil {
+			respondScheduleChangeError(c, err, "Failed to create schedule exception")
+			return
+		}
+		refunded := refundScheduleCancellations(c, db, gateway, cancelled)
+		notifyCancelledAppointments(db, m, doctor, cancelled)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":                "Schedule exception created successfully",
+			"exception":              exception,
+			"cancelled_appointments": appointmentIDs(cancelled),
+			"refunded_amount":        refunded,
+		})
+	}
+}
+
+// DeleteScheduleException removes one of the logged-in doctor's exceptions
+func DeleteScheduleException(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doctor, err := doctorForUser(c, db)
+		if err != nil {
+			respondDoctorError(c, err)
+			return
+		}
+
+		result := db.Where("id = ? AND doctor_id = ?", c.Param("id"), doctor.ID).Delete(&models.ScheduleException{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule exception"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule exception not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Schedule exception deleted successfully"})
+	}
+}
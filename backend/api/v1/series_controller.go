@@ -0,0 +1,259 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Values of the series query parameter on the cancel and reschedule endpoints
+const (
+	seriesScopeOne       = "one"
+	seriesScopeFollowing = "following"
+	seriesScopeAll       = "all"
+)
+
+var errNotInSeries = errors.New("Appointment is not part of a series")
+
+// SeriesRequest books a weekly recurring series of appointments. The first
+// session is at scheduled_at; the series ends after count sessions or on
+// end_date, whichever comes first.
+type SeriesRequest struct {
+	DoctorID          uint      `json:"doctor_id" binding:"required"`
+	ScheduledAt       time.Time `json:"scheduled_at" binding:"required"`
+	RRule             string    `json:"rrule"`                                  // e.g. "FREQ=WEEKLY;BYDAY=MO,TH"; defaults to weekly on the first session's weekday
+	Count             int       `json:"count" binding:"omitempty,min=1,max=52"` // Number of sessions
+	EndDate           string    `json:"end_date"`                               // Format: "2006-01-02", inclusive
+	Mode              string    `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort"`
+	AppointmentTypeID *uint     `json:"appointment_type_id"`
+	Notes             string    `json:"notes"`
+}
+
+// SeriesSkip describes an occurrence that could not be booked
+type SeriesSkip struct {
+	ScheduledAt time.Time `json:"scheduled_at"`
+	Reason      string    `json:"reason"`
+}
+
+// seriesUnavailableError is returned when occurrences of an all-or-nothing
+// series, or every occurrence of a best-effort one, cannot be booked
+type seriesUnavailableError struct {
+	Skipped []SeriesSkip
+}
+
+func (e *seriesUnavailableError) Error() string {
+	return "Some sessions of the series are not available"
+}
+
+// seriesScope returns the series query parameter, defaulting to one
+func seriesScope(c *gin.Context) (string, bool) {
+	scope := c.DefaultQuery("series", seriesScopeOne)
+	return scope, scope == seriesScopeOne || scope == seriesScopeFollowing || scope == seriesScopeAll
+}
+
+// seriesTargets returns the locked appointments an operation with the given
+// series scope applies to, in start time order. Beyond the selected
+// appointment these are the upcoming active occurrences of its series, from
+// the selected one onwards for following.
+func seriesTargets(tx *gorm.DB, appointment *models.Appointment, scope string) ([]*models.Appointment, error) {
+	targets := []*models.Appointment{appointment}
+	if scope == seriesScopeOne {
+		return targets, nil
+	}
+	if appointment.SeriesID == nil {
+		return nil, errNotInSeries
+	}
+
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("series_id = ? AND id <> ? AND status IN ? AND start_time > ?", *appointment.SeriesID, appointment.ID,
+			[]string{models.StatusPending, models.StatusConfirmed, models.StatusRescheduled}, time.Now())
+	if scope == seriesScopeFollowing {
+		query = query.Where("start_time > ?", appointment.StartTime)
+	}
+	var others []models.Appointment
+	if err := query.Find(&others).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range others {
+		targets = append(targets, &others[i])
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].StartTime.Before(targets[j].StartTime) })
+	return targets, nil
+}
+
+// CreateAppointmentSeries books a recurring series of appointments for the
+// logged-in patient. In all_or_nothing mode (the default) every session must
+// be available; in best_effort mode unavailable sessions are skipped.
+func CreateAppointmentSeries(db *gorm.DB, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req SeriesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		mode := req.Mode
+		if mode == "" {
+			mode = models.SeriesAllOrNothing
+		}
+
+		var doctor models.Doctor
+		if err := db.First(&doctor, req.DoctorID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+			return
+		}
+		loc := doctor.Location()
+		if _, err := loadVisit(db, doctor, req.AppointmentTypeID); err != nil {
+			respondSlotError(c, err, "Failed to book series")
+			return
+		}
+		if err := checkBookingAllowed(db, userID.(uint)); err != nil {
+			respondSlotError(c, err, "Failed to book series")
+			return
+		}
+
+		rule := strings.TrimPrefix(strings.TrimSpace(req.RRule), "RRULE:")
+		if rule == "" {
+			rule = "FREQ=WEEKLY;BYDAY=" + models.FormatWeekdays([]time.Weekday{req.ScheduledAt.In(loc).Weekday()})
+		}
+		rec, err := models.ParseRRule(rule)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rrule: " + err.Error()})
+			return
+		}
+
+		var until *time.Time
+		if req.EndDate != "" {
+			date, err := time.Parse("2006-01-02", req.EndDate)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format. Use YYYY-MM-DD"})
+				return
+			}
+			until = &date
+		}
+		if req.Count == 0 && until == nil && rec.Until == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "count or end_date is required"})
+			return
+		}
+
+		occurrences := rec.Occurrences(req.ScheduledAt, loc, req.Count, until)
+		if len(occurrences) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The recurrence produces no sessions"})
+			return
+		}
+
+		series := models.AppointmentSeries{
+			PatientID:  userID.(uint),
+			DoctorID:   doctor.ID,
+			RRule:      rule,
+			Count:      req.Count,
+			Until:      until,
+			FirstStart: occurrences[0],
+			Mode:       mode,
+			Status:     models.SeriesActive,
+			Notes:      req.Notes,
+		}
+
+		var skipped []SeriesSkip
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&series).Error; err != nil {
+				return err
+			}
+
+			for _, start := range occurrences {
+				appointment, err := bookSlot(tx, userID.(uint), doctor.ID, req.AppointmentTypeID, start, req.Notes, nil)
+				if err != nil {
+					if !slotUnavailable(err) {
+						return err
+					}
+					skipped = append(skipped, SeriesSkip{ScheduledAt: start, Reason: err.Error()})
+					continue
+				}
+				if err := tx.Model(&appointment).Update("series_id", series.ID).Error; err != nil {
+					return err
+				}
+				appointment.SeriesID = &series.ID
+				series.Appointments = append(series.Appointments, appointment)
+			}
+
+			if len(series.Appointments) == 0 || (mode == models.SeriesAllOrNothing && len(skipped) > 0) {
+				return &seriesUnavailableError{Skipped: skipped}
+			}
+			return nil
+		})
+
+		var unavailable *seriesUnavailableError
+		switch {
+		case errors.As(err, &unavailable):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":       unavailable.Error(),
+				"unavailable": unavailable.Skipped,
+			})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to book series"})
+		default:
+			if skipped == nil {
+				skipped = []SeriesSkip{}
+			}
+			// Each session is paid for separately
+			intents := []*PaymentResponse{}
+			for _, appointment := range series.Appointments {
+				if booked := newBookingResponse(c, db, gateway, appointment); booked.Payment != nil {
+					intents = append(intents, booked.Payment)
+				}
+			}
+			c.JSON(http.StatusCreated, gin.H{
+				"message":  fmt.Sprintf("Booked %d of %d sessions", len(series.Appointments), len(occurrences)),
+				"series":   series,
+				"skipped":  skipped,
+				"payments": intents,
+			})
+		}
+	}
+}
+
+// ListAppointmentSeries returns the logged-in patient's appointment series
+func ListAppointmentSeries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var series []models.AppointmentSeries
+		if err := db.Where("patient_id = ?", userID).
+			Preload("Appointments", func(tx *gorm.DB) *gorm.DB { return tx.Order("start_time ASC") }).
+			Order("first_start DESC").
+			Find(&series).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch series"})
+			return
+		}
+
+		c.JSON(http.StatusOK, series)
+	}
+}
+
+// GetAppointmentSeries returns one of the logged-in patient's series with its appointments
+func GetAppointmentSeries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var series models.AppointmentSeries
+		if err := db.Where("id = ? AND patient_id = ?", c.Param("id"), userID).
+			Preload("Appointments", func(tx *gorm.DB) *gorm.DB { return tx.Order("start_time ASC") }).
+			First(&series).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, series)
+	}
+}
@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errHoldNotFound  = errors.New("Hold not found")
+	errHoldNotActive = errors.New("Hold has expired or was released")
+	errHoldMismatch  = errors.New("doctor_id and scheduled_at must match the hold")
+)
+
+// SlotHoldRequest reserves a slot while the patient completes checkout
+type SlotHoldRequest struct {
+	DoctorID          uint      `json:"doctor_id" binding:"required"`
+	ScheduledAt       time.Time `json:"scheduled_at" binding:"required"`
+	AppointmentTypeID *uint     `json:"appointment_type_id"`
+}
+
+// slotHoldTTL is how long a checkout hold reserves its slot
+func slotHoldTTL() time.Duration {
+	return config.GetDurationEnv("SLOT_HOLD_TTL", 10*time.Minute)
+}
+
+// lockPatientHold locks the doctor of one of the patient's holds and then the
+// hold itself, which must still be active. The doctor is locked first to keep
+// the lock order the same as in booking.
+func lockPatientHold(tx *gorm.DB, patientID interface{}, holdID uint) (*models.SlotHold, error) {
+	var hold models.SlotHold
+	if err := tx.Where("id = ? AND patient_id = ?", holdID, patientID).First(&hold).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errHoldNotFound
+		}
+		return nil, err
+	}
+	if _, err := lockDoctor(tx, hold.DoctorID); err != nil {
+		return nil, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, hold.ID).Error; err != nil {
+		return nil, err
+	}
+	if !hold.Active(time.Now()) {
+		return nil, errHoldNotActive
+	}
+	return &hold, nil
+}
+
+// ExpireSlotHolds marks holds whose TTL has passed as expired. Lapsed holds
+// already stop blocking their slot; this keeps their status accurate.
+func ExpireSlotHolds(db *gorm.DB) error {
+	return db.Model(&models.SlotHold{}).
+		Where("status = ? AND expires_at <= ?", models.HoldActive, time.Now()).
+		Update("status", models.HoldExpired).Error
+}
+
+// RunHoldReaper expires stale slot holds and waitlist offers every interval.
+// It is meant to be started in its own goroutine and never returns.
+func RunHoldReaper(db *gorm.DB, m mailer.Mailer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := ExpireSlotHolds(db); err != nil {
+			log.Printf("Failed to expire slot holds: %v", err)
+		}
+		if err := ExpireWaitlistOffers(db, m); err != nil {
+			log.Printf("Failed to expire waitlist offers: %v", err)
+		}
+	}
+}
+
+// HoldSlot reserves a bookable slot for the logged-in patient for a short
+// time. The hold covers the appointment type's buffer as well as the slot.
+// Any other active hold of the patient is released, so a patient holds at
+// most one slot at a time.
+func HoldSlot(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req SlotHoldRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ttl := slotHoldTTL()
+		var hold models.SlotHold
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := checkBookingAllowed(tx, userID.(uint)); err != nil {
+				return err
+			}
+			doctor, err := lockDoctor(tx, req.DoctorID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.SlotHold{}).
+				Where("patient_id = ? AND status = ?", userID, models.HoldActive).
+				Update("status", models.HoldReleased).Error; err != nil {
+				return err
+			}
+
+			v, err := loadVisit(tx, doctor, req.AppointmentTypeID)
+			if err != nil {
+				return err
+			}
+
+			start := req.ScheduledAt.In(doctor.Location())
+			slot := timeRange{Start: start, End: start.Add(v.Length)}
+			if err := checkSlotBookable(tx, doctor, slot, v.Buffer, 0, userID.(uint)); err != nil {
+				return err
+			}
+
+			hold = models.SlotHold{
+				PatientID:         userID.(uint),
+				DoctorID:          doctor.ID,
+				AppointmentTypeID: v.TypeID,
+				StartTime:         slot.Start,
+				EndTime:           slot.End.Add(v.Buffer),
+				ExpiresAt:         time.Now().Add(ttl).In(doctor.Location()),
+				Status:            models.HoldActive,
+			}
+			return tx.Create(&hold).Error
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+				return
+			}
+			respondSlotError(c, err, "Failed to hold slot")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"hold_id":     hold.ID,
+			"ttl_seconds": int(ttl.Seconds()),
+			"hold":        hold,
+		})
+	}
+}
+
+// ReleaseSlotHold gives up one of the logged-in patient's active holds
+func ReleaseSlotHold(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		result := db.Model(&models.SlotHold{}).
+			Where("id = ? AND patient_id = ? AND status = ?", c.Param("id"), userID, models.HoldActive).
+			Update("status", models.HoldReleased)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release hold"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Active hold not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Hold released"})
+	}
+}
@@ -0,0 +1,243 @@
+package v1
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+)
+
+// placeholderEmailDomain is used for walk-in patients without an email
+// address. The .invalid TLD is reserved and never receives mail.
+const placeholderEmailDomain = "walk-in.invalid"
+
+var errPatientNotFound = errors.New("patient not found")
+
+// StaffPatientRequest describes a lightweight patient created at the front desk
+type StaffPatientRequest struct {
+	Name        string     `json:"name" binding:"required"`
+	Email       string     `json:"email" binding:"omitempty,email"`
+	Phone       string     `json:"phone" binding:"required_without=Email"`
+	DateOfBirth *time.Time `json:"date_of_birth"`
+}
+
+// StaffBookingRequest represents a booking made by staff for a patient. Either
+// PatientID or Patient must be given.
+type StaffBookingRequest struct {
+	PatientID         uint                 `json:"patient_id" binding:"required_without=Patient"`
+	Patient           *StaffPatientRequest `json:"patient"`
+	DoctorID          uint                 `json:"doctor_id" binding:"required"`
+	ScheduledAt       time.Time            `json:"scheduled_at" binding:"required"`
+	AppointmentTypeID *uint                `json:"appointment_type_id"`
+	Notes             string               `json:"notes"`
+}
+
+// StaffCancelRequest represents a cancellation made by staff
+type StaffCancelRequest struct {
+	Reason string `json:"reason"`
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// createLightweightPatient creates a patient account that staff can book for.
+// The account gets an unusable random password; the patient can claim it
+// later through the password reset flow if they have a real email address.
+func createLightweightPatient(tx *gorm.DB, req StaffPatientRequest) (*models.User, error) {
+	password, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" {
+		suffix, err := randomHex(8)
+		if err != nil {
+			return nil, err
+		}
+		email = "patient-" + suffix + "@" + placeholderEmailDomain
+	}
+
+	patient := &models.User{
+		Name:     req.Name,
+		Email:    email,
+		Password: password,
+		Role:     models.PatientRole,
+		Active:   true,
+		Phone:    req.Phone,
+	}
+	if req.DateOfBirth != nil {
+		patient.DateOfBirth = *req.DateOfBirth
+	}
+	if err := tx.Create(patient).Error; err != nil {
+		return nil, err
+	}
+	return patient, nil
+}
+
+// findPatient loads a user and checks that they are a patient
+func findPatient(tx *gorm.DB, patientID uint) (*models.User, error) {
+	var patient models.User
+	if err := tx.Where("id = ? AND role = ?", patientID, models.PatientRole).First(&patient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errPatientNotFound
+		}
+		return nil, err
+	}
+	return &patient, nil
+}
+
+// emailTaken reports whether a user with the given email already exists
+func emailTaken(db *gorm.DB, email string) (bool, error) {
+	var count int64
+	err := db.Model(&models.User{}).Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(email))).Count(&count).Error
+	return count > 0, err
+}
+
+// SearchPatients finds patients by name, email or phone for staff booking
+func SearchPatients(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.User{}).Where("role = ?", models.PatientRole)
+		if q := strings.TrimSpace(c.Query("q")); q != "" {
+			like := "%" + strings.ToLower(q) + "%"
+			query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ? OR phone LIKE ?", like, like, "%"+q+"%")
+		}
+
+		var patients []models.User
+		if err := query.Order("name ASC").Limit(50).Find(&patients).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search patients"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": patients})
+	}
+}
+
+// CreateStaffPatient creates a lightweight patient record for a walk-in or phone patient
+func CreateStaffPatient(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req StaffPatientRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.Email != "" {
+			taken, err := emailTaken(db, req.Email)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create patient"})
+				return
+			}
+			if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": "A user with this email already exists"})
+				return
+			}
+		}
+
+		patient, err := createLightweightPatient(db, req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create patient"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, patient)
+	}
+}
+
+// StaffBookAppointment books an appointment on behalf of an existing or new
+// patient. The caller is recorded as the booking staff member.
+func StaffBookAppointment(db *gorm.DB, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		staffID := userID.(uint)
+
+		var req StaffBookingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Patient != nil {
+			if req.PatientID != 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either patient_id or patient, not both"})
+				return
+			}
+			if req.Patient.Email != "" {
+				taken, err := emailTaken(db, req.Patient.Email)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to book appointment"})
+					return
+				}
+				if taken {
+					c.JSON(http.StatusConflict, gin.H{"error": "A user with this email already exists, book with patient_id instead"})
+					return
+				}
+			}
+		}
+
+		var appointment models.Appointment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			patientID := req.PatientID
+			if req.Patient != nil {
+				patient, err := createLightweightPatient(tx, *req.Patient)
+				if err != nil {
+					return err
+				}
+				patientID = patient.ID
+			} else if _, err := findPatient(tx, patientID); err != nil {
+				return err
+			}
+
+			var err error
+			appointment, err = bookSlot(tx, patientID, req.DoctorID, req.AppointmentTypeID, req.ScheduledAt, req.Notes, &staffID)
+			return err
+		})
+		if errors.Is(err, errPatientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+			return
+		}
+		if err != nil {
+			respondBookingError(c, err)
+			return
+		}
+
+		respondBooked(c, db, gateway, appointment)
+	}
+}
+
+// StaffRescheduleAppointment moves any patient's appointment to a new slot.
+// The minimum notice only applies to patients and doctors.
+func StaffRescheduleAppointment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rescheduleAppointment(c, db, func(tx *gorm.DB) *gorm.DB {
+			return tx
+		}, 0)
+	}
+}
+
+// StaffCancelAppointment cancels any patient's appointment, refunding any
+// payment in full
+func StaffCancelAppointment(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req StaffCancelRequest
+		// The body is optional
+		_ = c.ShouldBindJSON(&req)
+
+		cancelAppointment(c, db, m, gateway, func(tx *gorm.DB) *gorm.DB {
+			return tx
+		}, req.Reason, false)
+	}
+}
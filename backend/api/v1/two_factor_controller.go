@@ -0,0 +1,383 @@
+package v1
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/middleware"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/totp"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount  = 10
+	twoFactorLoginTTL  = 5 * time.Minute
+	twoFactorEnrollTTL = 15 * time.Minute
+)
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type SecurityPolicyRequest struct {
+	RequireTwoFactorRoles []string `json:"require_2fa_roles"`
+}
+
+// loadSecurityPolicy returns the stored security policy, or an empty policy if none has been saved
+func loadSecurityPolicy(db *gorm.DB) (models.SecurityPolicy, error) {
+	var policy models.SecurityPolicy
+	err := db.Order("id").First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.SecurityPolicy{}, nil
+	}
+	return policy, err
+}
+
+// newRecoveryCodes generates fresh recovery codes and their bcrypt hashes
+func newRecoveryCodes(userID uint) ([]string, []models.RecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		code = code[:5] + "-" + code[5:]
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: string(hash)})
+	}
+	return codes, records, nil
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, an unused recovery
+// code. Accepted codes are consumed with conditional updates so that the same
+// code cannot be used twice, even concurrently.
+func verifySecondFactor(db *gorm.DB, user *models.User, code, recoveryCode string) error {
+	if code != "" {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return errInvalidSecondFactor
+		}
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			UpdateColumn("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidSecondFactor
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	if recoveryCode != "" {
+		var codes []models.RecoveryCode
+		if err := db.Where("user_id = ? AND used_at IS NULL", user.ID).Find(&codes).Error; err != nil {
+			return err
+		}
+		for _, rc := range codes {
+			if bcrypt.CompareHashAndPassword([]byte(rc.CodeHash), []byte(recoveryCode)) != nil {
+				continue
+			}
+			result := db.Model(&models.RecoveryCode{}).
+				Where("id = ? AND used_at IS NULL", rc.ID).
+				Update("used_at", time.Now())
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 1 {
+				return nil
+			}
+		}
+	}
+
+	return errInvalidSecondFactor
+}
+
+// SetupTwoFactor generates a new TOTP secret for the logged-in user and returns
+// the otpauth URI for their authenticator app. The secret is not active until
+// confirmed with EnableTwoFactor.
+func SetupTwoFactor(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if user.TOTPEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+		if err := db.Model(&user).UpdateColumns(map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+			return
+		}
+
+		issuer := config.GetEnv("TOTP_ISSUER", "Doctor Booking")
+		c.JSON(http.StatusOK, gin.H{
+			"secret":      secret,
+			"otpauth_uri": totp.URI(secret, issuer, user.Email),
+		})
+	}
+}
+
+// EnableTwoFactor confirms enrollment with a code from the authenticator app
+// and returns a new set of recovery codes. The codes are only shown once.
+func EnableTwoFactor(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req TwoFactorCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Code is required"})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if user.TOTPEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		if user.TOTPSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor setup has not been started"})
+			return
+		}
+
+		codes, records, err := newRecoveryCodes(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := verifySecondFactor(tx, &user, req.Code, ""); err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&records).Error; err != nil {
+				return err
+			}
+			return tx.Model(&user).UpdateColumn("totp_enabled", true).Error
+		})
+		if errors.Is(err, errInvalidSecondFactor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Two-factor authentication enabled",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DisableTwoFactor turns off two-factor authentication for the logged-in user,
+// unless the security policy requires it for their role
+func DisableTwoFactor(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req TwoFactorCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if !user.TOTPEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+			return
+		}
+
+		policy, err := loadSecurityPolicy(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load security policy"})
+			return
+		}
+		if policy.RequiresTwoFactor(user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := verifySecondFactor(tx, &user, req.Code, req.RecoveryCode); err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&user).UpdateColumns(map[string]interface{}{
+				"totp_enabled": false,
+				"totp_secret":  "",
+			}).Error
+		})
+		if errors.Is(err, errInvalidSecondFactor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+	}
+}
+
+// VerifyTwoFactorLogin completes a login that LoginUser answered with a
+// challenge token, exchanging it and a TOTP or recovery code for access tokens
+func VerifyTwoFactorLogin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TwoFactorVerifyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		claims, err := middleware.ParseChallengeToken(req.ChallengeToken, middleware.PurposeTwoFactorLogin)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, claims.UserID).Error; err != nil ||
+			!user.Active || !user.TOTPEnabled || user.TokenVersion != claims.TokenVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+			return
+		}
+
+		// Wrong codes count towards the same lockout as wrong passwords
+		accountKey := accountThrottleKey(user.Email)
+		if wait, err := loginLockedFor(db, accountKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+			return
+		} else if wait > 0 {
+			c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked, try again later"})
+			return
+		}
+
+		if err := verifySecondFactor(db, &user, req.Code, req.RecoveryCode); err != nil {
+			if errors.Is(err, errInvalidSecondFactor) {
+				_ = recordLoginFailure(db, accountKey, accountThrottlePolicy())
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+			return
+		}
+
+		resp, err := newAuthResponse(db, &user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetSecurityPolicy returns the authentication policy (admin only)
+func GetSecurityPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy, err := loadSecurityPolicy(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load security policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"require_2fa_roles": policy.TwoFactorRoles()})
+	}
+}
+
+// UpdateSecurityPolicy sets the roles that must use two-factor authentication (admin only)
+func UpdateSecurityPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SecurityPolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		policy, err := loadSecurityPolicy(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load security policy"})
+			return
+		}
+
+		// Custom roles can require two-factor authentication too
+		roles := make([]models.UserRole, 0, len(req.RequireTwoFactorRoles))
+		for _, name := range req.RequireTwoFactorRoles {
+			role := models.UserRole(name)
+			exists, err := models.RoleExists(db, role)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security policy"})
+				return
+			}
+			if !exists {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + name})
+				return
+			}
+			roles = append(roles, role)
+		}
+		policy.SetTwoFactorRoles(roles)
+
+		if err := db.Save(&policy).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":           "Security policy updated successfully",
+			"require_2fa_roles": policy.TwoFactorRoles(),
+		})
+	}
+}
@@ -3,8 +3,10 @@ package v1
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/middleware"
 	"github.com/sandipdas/go-doctor-booking/backend/models"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -99,6 +101,8 @@ func UpdateUserProfile(db *gorm.DB) gin.HandlerFunc {
 				return
 			}
 			user.Password = hashedPassword
+			// Changing the password signs out every existing session
+			user.TokenVersion++
 		}
 
 		if err := tx.Save(&user).Error; err != nil {
@@ -107,6 +111,16 @@ func UpdateUserProfile(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if req.Password != "" {
+			if err := tx.Model(&models.RefreshToken{}).
+				Where("user_id = ? AND revoked_at IS NULL", user.ID).
+				Update("revoked_at", time.Now()).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+				return
+			}
+		}
+
 		tx.Commit()
 
 		// Don't return password hash
@@ -184,8 +198,37 @@ func UpdateUserStatus(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Deactivation takes effect immediately for all issued tokens
+		if !req.Active {
+			id, _ := strconv.ParseUint(userID, 10, 64)
+			if err := middleware.RevokeAllTokens(db, uint(id)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke user tokens"})
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "User status updated successfully",
 		})
 	}
 }
+
+// UnlockUserLogin clears failed login attempts and any lockout for a user (admin only)
+func UnlockUserLogin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("id")
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if err := clearLoginFailures(db, accountThrottleKey(user.Email)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User login unlocked successfully"})
+	}
+}
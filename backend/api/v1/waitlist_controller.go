@@ -0,0 +1,473 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxWaitlistDays limits how far a single waitlist entry may stretch
+const maxWaitlistDays = 90
+
+var (
+	errOfferNotPending = errors.New("Offer is no longer available")
+	errOfferExpired    = errors.New("Offer has expired")
+)
+
+// WaitlistRequest registers interest in a doctor between two dates
+type WaitlistRequest struct {
+	DoctorID uint   `json:"doctor_id" binding:"required"`
+	FromDate string `json:"from_date" binding:"required"` // Format: "2006-01-02"
+	ToDate   string `json:"to_date" binding:"required"`   // Format: "2006-01-02"
+	Notes    string `json:"notes"`
+}
+
+// waitlistOfferTTL is how long a freed slot is held for a waitlisted patient
+func waitlistOfferTTL() time.Duration {
+	return config.GetDurationEnv("WAITLIST_OFFER_TTL", 30*time.Minute)
+}
+
+// offerFreedSlot offers slot to the first waiting patient whose entry covers
+// its date, holding it until the offer expires. Patients already offered the
+// same slot are skipped. It returns nil when the slot is no longer bookable
+// or nobody is waiting. The doctor must be locked by the caller. typeID is the
+// appointment type the slot was booked as, nil for a standard visit.
+func offerFreedSlot(tx *gorm.DB, doctor models.Doctor, slot timeRange, typeID *uint) (*models.WaitlistOffer, error) {
+	if err := checkSlotBookable(tx, doctor, slot, 0, 0, 0); err != nil {
+		if slotUnavailable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	date := localDate(slot.Start, doctor.Location())
+	var entry models.WaitlistEntry
+	err := tx.Where("doctor_id = ? AND status = ? AND from_date <= ? AND to_date >= ?",
+		doctor.ID, models.WaitlistWaiting, date, date).
+		Where("NOT EXISTS (SELECT 1 FROM waitlist_offers o WHERE o.entry_id = waitlist_entries.id AND o.start_time = ? AND o.deleted_at IS NULL)", slot.Start).
+		Order("created_at ASC, id ASC").
+		First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Never hold the slot past its own start
+	expiresAt := time.Now().Add(waitlistOfferTTL())
+	if expiresAt.After(slot.Start) {
+		expiresAt = slot.Start
+	}
+
+	offer := models.WaitlistOffer{
+		EntryID:           entry.ID,
+		PatientID:         entry.PatientID,
+		DoctorID:          doctor.ID,
+		StartTime:         slot.Start,
+		EndTime:           slot.End,
+		ExpiresAt:         expiresAt,
+		Status:            models.OfferPending,
+		AppointmentTypeID: typeID,
+	}
+	if err := tx.Create(&offer).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&entry).Update("status", models.WaitlistOffered).Error; err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// offerCancelledSlot passes the slot of a just-cancelled appointment on to the
+// waitlist. It runs in the cancelling transaction.
+func offerCancelledSlot(tx *gorm.DB, appointment *models.Appointment) (*models.WaitlistOffer, error) {
+	doctor, err := lockDoctor(tx, appointment.DoctorID)
+	if err != nil {
+		return nil, err
+	}
+	return offerFreedSlot(tx, doctor, timeRange{Start: appointment.StartTime, End: appointment.EndTime}, appointment.AppointmentTypeID)
+}
+
+// releaseOffer closes a pending offer with the given status, returns its
+// entry to the queue and offers the slot to the next patient in line
+func releaseOffer(tx *gorm.DB, doctor models.Doctor, offer *models.WaitlistOffer, status string) (*models.WaitlistOffer, error) {
+	now := time.Now()
+	if err := tx.Model(offer).Updates(map[string]interface{}{"status": status, "responded_at": now}).Error; err != nil {
+		return nil, err
+	}
+	offer.Status = status
+	offer.RespondedAt = &now
+
+	if err := tx.Model(&models.WaitlistEntry{}).
+		Where("id = ? AND status = ?", offer.EntryID, models.WaitlistOffered).
+		Update("status", models.WaitlistWaiting).Error; err != nil {
+		return nil, err
+	}
+	return offerFreedSlot(tx, doctor, timeRange{Start: offer.StartTime, End: offer.EndTime}, offer.AppointmentTypeID)
+}
+
+// lockOffer loads an offer with FOR UPDATE
+func lockOffer(tx *gorm.DB, offerID interface{}) (*models.WaitlistOffer, error) {
+	var offer models.WaitlistOffer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", offerID).
+		First(&offer).Error; err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// lockPatientOffer locks the doctor of one of the patient's offers and then
+// the pending offer itself. Offers change only with their doctor locked, so
+// taking the doctor first keeps the lock order the same as in booking.
+func lockPatientOffer(tx *gorm.DB, patientID, offerID interface{}) (models.Doctor, *models.WaitlistOffer, error) {
+	var offer models.WaitlistOffer
+	if err := tx.Where("id = ? AND patient_id = ?", offerID, patientID).First(&offer).Error; err != nil {
+		return models.Doctor{}, nil, err
+	}
+	doctor, err := lockDoctor(tx, offer.DoctorID)
+	if err != nil {
+		return models.Doctor{}, nil, err
+	}
+	locked, err := lockOffer(tx, offer.ID)
+	if err != nil {
+		return models.Doctor{}, nil, err
+	}
+	if locked.Status != models.OfferPending {
+		return models.Doctor{}, nil, errOfferNotPending
+	}
+	return doctor, locked, nil
+}
+
+// ExpireWaitlistOffers releases offers whose hold has lapsed and passes each
+// slot on to the next waiting patient, who is notified by email. An offer
+// that fails to expire is logged and retried on the next run.
+func ExpireWaitlistOffers(db *gorm.DB, m mailer.Mailer) error {
+	var lapsed []models.WaitlistOffer
+	if err := db.Where("status = ? AND expires_at <= ?", models.OfferPending, time.Now()).
+		Find(&lapsed).Error; err != nil {
+		return err
+	}
+
+	var offers []models.WaitlistOffer
+	for _, candidate := range lapsed {
+		var next *models.WaitlistOffer
+		err := db.Transaction(func(tx *gorm.DB) error {
+			doctor, err := lockDoctor(tx, candidate.DoctorID)
+			if err != nil {
+				return err
+			}
+			// Re-check under the lock in case the patient has just responded
+			offer, err := lockOffer(tx, candidate.ID)
+			if err != nil {
+				return err
+			}
+			if offer.Status != models.OfferPending {
+				return nil
+			}
+			next, err = releaseOffer(tx, doctor, offer, models.OfferExpired)
+			return err
+		})
+		if err != nil {
+			// Offers already passed on must still be announced
+			log.Printf("Failed to expire waitlist offer %d: %v", candidate.ID, err)
+			continue
+		}
+		if next != nil {
+			offers = append(offers, *next)
+		}
+	}
+
+	notifyWaitlistOffers(db, m, offers)
+	return nil
+}
+
+// notifyWaitlistOffers emails each offered patient the slot and the deadline
+// to accept it, in the doctor's time zone. Failures are logged.
+func notifyWaitlistOffers(db *gorm.DB, m mailer.Mailer, offers []models.WaitlistOffer) {
+	for _, offer := range offers {
+		var patient models.User
+		if err := db.First(&patient, offer.PatientID).Error; err != nil {
+			log.Printf("Failed to load patient %d for waitlist offer: %v", offer.PatientID, err)
+			continue
+		}
+		if strings.HasSuffix(patient.Email, "@"+placeholderEmailDomain) {
+			continue
+		}
+		var doctor models.Doctor
+		if err := db.Preload("User").First(&doctor, offer.DoctorID).Error; err != nil {
+			log.Printf("Failed to load doctor %d for waitlist offer: %v", offer.DoctorID, err)
+			continue
+		}
+
+		loc := doctor.Location()
+		start := offer.StartTime.In(loc)
+		if err := m.Send(mailer.Message{
+			To:      patient.Email,
+			Subject: "An appointment slot has opened up",
+			Body: fmt.Sprintf("Hi %s,\n\nA slot with Dr. %s is available on %s at %s. It is being held for you until %s.\n\nAccept the offer in the app to book it automatically.\n",
+				patient.Name, doctor.User.Name, start.Format("Monday, 2 January 2006"), start.Format("15:04 MST"),
+				offer.ExpiresAt.In(loc).Format("15:04 MST")),
+		}); err != nil {
+			log.Printf("Failed to send waitlist offer %d: %v", offer.ID, err)
+		}
+	}
+}
+
+// JoinWaitlist adds the logged-in patient to a doctor's waitlist
+func JoinWaitlist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req WaitlistRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		from, err := time.Parse("2006-01-02", req.FromDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from_date format. Use YYYY-MM-DD"})
+			return
+		}
+		to, err := time.Parse("2006-01-02", req.ToDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to_date format. Use YYYY-MM-DD"})
+			return
+		}
+		if to.Before(from) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to_date must not be before from_date"})
+			return
+		}
+		if to.Sub(from) > maxWaitlistDays*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A waitlist entry may cover at most %d days", maxWaitlistDays)})
+			return
+		}
+
+		var doctor models.Doctor
+		if err := db.First(&doctor, req.DoctorID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+			return
+		}
+		if to.Before(localDate(time.Now(), doctor.Location())) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to_date must not be in the past"})
+			return
+		}
+
+		var existing int64
+		if err := db.Model(&models.WaitlistEntry{}).
+			Where("patient_id = ? AND doctor_id = ? AND status IN ?", userID, doctor.ID,
+				[]string{models.WaitlistWaiting, models.WaitlistOffered}).
+			Count(&existing).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
+			return
+		}
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already on this doctor's waitlist"})
+			return
+		}
+
+		entry := models.WaitlistEntry{
+			PatientID: userID.(uint),
+			DoctorID:  doctor.ID,
+			FromDate:  from,
+			ToDate:    to,
+			Notes:     req.Notes,
+			Status:    models.WaitlistWaiting,
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, entry)
+	}
+}
+
+// ListWaitlist returns the logged-in patient's waitlist entries with any
+// pending offers. Lapsed offers are left out; the hold reaper passes them on.
+func ListWaitlist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var entries []models.WaitlistEntry
+		if err := db.Where("patient_id = ?", userID).
+			Preload("Doctor").
+			Preload("Offers", "status = ? AND expires_at > ?", models.OfferPending, time.Now()).
+			Order("created_at DESC").
+			Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+			return
+		}
+
+		for i := range entries {
+			loc := entries[i].Doctor.Location()
+			for j := range entries[i].Offers {
+				offer := &entries[i].Offers[j]
+				offer.StartTime = offer.StartTime.In(loc)
+				offer.EndTime = offer.EndTime.In(loc)
+				offer.ExpiresAt = offer.ExpiresAt.In(loc)
+			}
+		}
+
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// LeaveWaitlist cancels one of the logged-in patient's waitlist entries. A
+// pending offer on the entry is declined and passed on.
+func LeaveWaitlist(db *gorm.DB, m mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var entry models.WaitlistEntry
+		if err := db.Where("id = ? AND patient_id = ?", c.Param("id"), userID).First(&entry).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+			return
+		}
+		if !entry.Open() {
+			c.JSON(http.StatusConflict, gin.H{"error": "Waitlist entry is already closed"})
+			return
+		}
+
+		var next *models.WaitlistOffer
+		err := db.Transaction(func(tx *gorm.DB) error {
+			doctor, err := lockDoctor(tx, entry.DoctorID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&entry).Update("status", models.WaitlistCancelled).Error; err != nil {
+				return err
+			}
+
+			var offer models.WaitlistOffer
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("entry_id = ? AND status = ?", entry.ID, models.OfferPending).
+				First(&offer).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			next, err = releaseOffer(tx, doctor, &offer, models.OfferDeclined)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+			return
+		}
+		if next != nil {
+			notifyWaitlistOffers(db, m, []models.WaitlistOffer{*next})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Removed from waitlist"})
+	}
+}
+
+// AcceptWaitlistOffer books the slot held by one of the logged-in patient's
+// offers
+func AcceptWaitlistOffer(db *gorm.DB, m mailer.Mailer, gateway payments.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var appointment models.Appointment
+		var next *models.WaitlistOffer
+		expired := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			doctor, offer, err := lockPatientOffer(tx, userID, c.Param("id"))
+			if err != nil {
+				return err
+			}
+			if !offer.Active(time.Now()) {
+				// Pass the lapsed offer on and keep that change
+				next, err = releaseOffer(tx, doctor, offer, models.OfferExpired)
+				expired = true
+				return err
+			}
+
+			var entry models.WaitlistEntry
+			if err := tx.First(&entry, offer.EntryID).Error; err != nil {
+				return err
+			}
+			appointment, err = bookSlot(tx, offer.PatientID, offer.DoctorID, offer.AppointmentTypeID, offer.StartTime, entry.Notes, nil)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			if err := tx.Model(offer).Updates(map[string]interface{}{
+				"status":         models.OfferAccepted,
+				"responded_at":   now,
+				"appointment_id": appointment.ID,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&entry).Updates(map[string]interface{}{
+				"status":         models.WaitlistBooked,
+				"appointment_id": appointment.ID,
+			}).Error
+		})
+		if next != nil {
+			notifyWaitlistOffers(db, m, []models.WaitlistOffer{*next})
+		}
+
+		switch {
+		case err == nil && expired:
+			c.JSON(http.StatusConflict, gin.H{"error": errOfferExpired.Error()})
+		case err == nil:
+			respondBooked(c, db, gateway, appointment)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+		case errors.Is(err, errOfferNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			respondSlotError(c, err, "Failed to book appointment")
+		}
+	}
+}
+
+// DeclineWaitlistOffer turns down one of the logged-in patient's offers. The
+// entry stays on the waitlist and the slot goes to the next patient.
+func DeclineWaitlistOffer(db *gorm.DB, m mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var next *models.WaitlistOffer
+		err := db.Transaction(func(tx *gorm.DB) error {
+			doctor, offer, err := lockPatientOffer(tx, userID, c.Param("id"))
+			if err != nil {
+				return err
+			}
+			next, err = releaseOffer(tx, doctor, offer, models.OfferDeclined)
+			return err
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+			return
+		case errors.Is(err, errOfferNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline offer"})
+			return
+		}
+		if next != nil {
+			notifyWaitlistOffers(db, m, []models.WaitlistOffer{*next})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Offer declined"})
+	}
+}
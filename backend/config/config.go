@@ -1,6 +1,10 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 // Config holds the application configuration
 type Config struct {
@@ -29,3 +33,34 @@ func GetEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// GetDurationEnv returns the environment variable named by the key parsed as a
+// time.Duration (e.g. "24h"). If the variable is unset or invalid, it returns
+// the default value.
+func GetDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetIntEnv returns the environment variable named by the key parsed as an
+// int. If the variable is unset or invalid, it returns the default value.
+func GetIntEnv(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetFloatEnv returns the environment variable named by the key parsed as a
+// float64. If the variable is unset or invalid, it returns the default value.
+func GetFloatEnv(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,137 @@
+// Package invoice renders billing documents such as invoices and receipts as
+// PDF files. The PDF is written directly using the standard Helvetica fonts,
+// which is all a one page document of text and rules needs.
+package invoice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Field is a labelled value shown under the document title
+type Field struct {
+	Label string
+	Value string
+}
+
+// Party is a heading followed by lines of text, such as the patient billed
+type Party struct {
+	Heading string
+	Lines   []string
+}
+
+// Item is a billed line and its amount
+type Item struct {
+	Description string
+	Amount      float64
+}
+
+// Document is the content of an invoice or receipt. Amounts are in Currency
+// and are shown as given; the caller is responsible for Subtotal, Tax and
+// Total adding up.
+type Document struct {
+	Title    string // e.g. "Invoice" or "Receipt"
+	Issuer   string
+	Number   string
+	Date     time.Time
+	Fields   []Field
+	Parties  []Party // Shown side by side; at most two fit the page
+	Items    []Item
+	Subtotal float64
+	TaxLabel string // e.g. "VAT 20%"; the tax line is omitted when empty
+	Tax      float64
+	Total    float64
+	Currency string
+	Notes    []string // Shown below the totals
+}
+
+// maxDescription is the number of characters of an item description that
+// fit before the amount column
+const maxDescription = 70
+
+// FormatAmount formats an amount with two decimals
+func FormatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// Render returns doc as a single page PDF
+func Render(doc Document) []byte {
+	p := &page{}
+	left, right := float64(margin), float64(pageWidth-margin)
+	y := float64(pageHeight - margin - 20)
+
+	p.text(left, y, fontBold, 20, doc.Issuer)
+	p.textRight(right, y, fontBold, 20, strings.ToUpper(doc.Title))
+	y -= 40
+
+	fields := append([]Field{
+		{Label: doc.Title + " number", Value: doc.Number},
+		{Label: "Date", Value: doc.Date.Format("2 January 2006")},
+	}, doc.Fields...)
+	for _, field := range fields {
+		p.text(left, y, fontBold, 10, field.Label+":")
+		p.text(left+120, y, fontRegular, 10, field.Value)
+		y -= 15
+	}
+	y -= 15
+
+	partiesTop, partiesBottom := y, y
+	for i, party := range doc.Parties {
+		x := left + float64(i)*(right-left)/2
+		py := partiesTop
+		p.text(x, py, fontBold, 11, party.Heading)
+		py -= 15
+		for _, line := range party.Lines {
+			p.text(x, py, fontRegular, 10, line)
+			py -= 14
+		}
+		if py < partiesBottom {
+			partiesBottom = py
+		}
+	}
+	y = partiesBottom - 20
+
+	currency := strings.ToUpper(doc.Currency)
+	p.text(left, y, fontBold, 10, "Description")
+	p.textRight(right, y, fontBold, 10, "Amount ("+currency+")")
+	y -= 6
+	p.line(left, y, right, y)
+	y -= 16
+	for _, item := range doc.Items {
+		p.text(left, y, fontRegular, 10, truncate(item.Description, maxDescription))
+		p.textRight(right, y, fontRegular, 10, FormatAmount(item.Amount))
+		y -= 16
+	}
+	p.line(left, y+10, right, y+10)
+	y -= 6
+
+	totalsLabel := right - 160
+	p.text(totalsLabel, y, fontRegular, 10, "Subtotal")
+	p.textRight(right, y, fontRegular, 10, FormatAmount(doc.Subtotal))
+	y -= 16
+	if doc.TaxLabel != "" {
+		p.text(totalsLabel, y, fontRegular, 10, doc.TaxLabel)
+		p.textRight(right, y, fontRegular, 10, FormatAmount(doc.Tax))
+		y -= 16
+	}
+	p.text(totalsLabel, y, fontBold, 11, "Total ("+currency+")")
+	p.textRight(right, y, fontBold, 11, FormatAmount(doc.Total))
+	y -= 36
+
+	for _, note := range doc.Notes {
+		p.text(left, y, fontRegular, 9, note)
+		y -= 13
+	}
+
+	return build(p, doc.Title+" "+doc.Number, doc.Date)
+}
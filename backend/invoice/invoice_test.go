@@ -0,0 +1,83 @@
+package invoice_test
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/invoice"
+)
+
+func testDocument() invoice.Document {
+	return invoice.Document{
+		Title:  "Receipt",
+		Issuer: "Riverside Clinic",
+		Number: "RCT-2026-000042",
+		Date:   time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Fields: []invoice.Field{{Label: "Payment reference", Value: "pi_123"}},
+		Parties: []invoice.Party{
+			{Heading: "Billed to", Lines: []string{"Zoë Patient", "zoe@example.com"}},
+			{Heading: "Provider", Lines: []string{"Dr Test Doctor", "Cardiology"}},
+		},
+		Items:    []invoice.Item{{Description: "Consultation (follow-up)", Amount: 50}},
+		Subtotal: 41.67,
+		TaxLabel: "VAT 20%",
+		Tax:      8.33,
+		Total:    50,
+		Currency: "usd",
+		Notes:    []string{"Paid in full. Thank you."},
+	}
+}
+
+func TestRender(t *testing.T) {
+	pdf := invoice.Render(testDocument())
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "(RCT-2026-000042)")
+	assert.Contains(t, string(pdf), "(Consultation \\(follow-up\\))")
+	assert.Contains(t, string(pdf), "(Zo\xeb Patient)")
+	assert.Contains(t, string(pdf), "(Amount \\(USD\\))")
+	assert.Contains(t, string(pdf), "(8.33)")
+
+	t.Run("Cross-reference table points at each object", func(t *testing.T) {
+		match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+		require.NotNil(t, match)
+		xref, err := strconv.Atoi(string(match[1]))
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n0 8\n")))
+
+		entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(pdf[xref:], -1)
+		require.Len(t, entries, 7)
+		for i, entry := range entries {
+			offset, err := strconv.Atoi(string(entry[1]))
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+		}
+	})
+
+	t.Run("Stream length matches its content", func(t *testing.T) {
+		match := regexp.MustCompile(`(?s)<< /Length (\d+) >>\nstream\n(.*?)endstream`).FindSubmatch(pdf)
+		require.NotNil(t, match)
+		length, err := strconv.Atoi(string(match[1]))
+		require.NoError(t, err)
+		assert.Equal(t, length, len(match[2]))
+	})
+
+	t.Run("Rendering is deterministic", func(t *testing.T) {
+		assert.Equal(t, pdf, invoice.Render(testDocument()))
+	})
+}
+
+func TestRenderWithoutTax(t *testing.T) {
+	doc := testDocument()
+	doc.TaxLabel = ""
+	pdf := invoice.Render(doc)
+	assert.NotContains(t, string(pdf), "VAT")
+}
@@ -0,0 +1,132 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Page size and margins in PDF points (1/72 inch), for A4 paper
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 50
+)
+
+// Fonts available to page content; both are standard PDF fonts that every
+// viewer provides, so nothing is embedded
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+// winAnsi maps the characters outside Latin-1 that WinAnsiEncoding supports
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// encodeText converts s to a WinAnsiEncoding PDF string literal. Characters
+// the encoding lacks are replaced with '?'.
+func encodeText(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			b.WriteByte(byte(r))
+		default:
+			if c, ok := winAnsi[r]; ok {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('?')
+			}
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// textWidth estimates the width of s in points. It is exact for amounts,
+// whose digits and separators have fixed widths in Helvetica, and close
+// enough for short labels.
+func textWidth(s string, size float64) float64 {
+	units := 0
+	for _, r := range s {
+		switch r {
+		case '.', ',', ' ', ':':
+			units += 278
+		case '-', '(', ')':
+			units += 333
+		case '%':
+			units += 889
+		default:
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// page collects the content stream of a single page
+type page struct {
+	content bytes.Buffer
+}
+
+// text draws s with its baseline starting at (x, y)
+func (p *page) text(x, y float64, font string, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, y, encodeText(s))
+}
+
+// textRight draws s so that it ends at x
+func (p *page) textRight(x, y float64, font string, size float64, s string) {
+	p.text(x-textWidth(s, size), y, font, size, s)
+}
+
+// line draws a thin rule from (x1, y1) to (x2, y2)
+func (p *page) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// build wraps a page's content in a complete single page PDF document. The
+// output only depends on its arguments, so rendering the same document
+// twice gives identical files.
+func build(p *page, title string, created time.Time) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /%s 4 0 R /%s 5 0 R >> >> /Contents 6 0 R >>",
+			pageWidth, pageHeight, fontRegular, fontBold),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+		fmt.Sprintf("<< /Title %s /Producer (go-doctor-booking) /CreationDate (D:%s) >>",
+			encodeText(title), created.UTC().Format("20060102150405Z")),
+	}
+
+	var out bytes.Buffer
+	// The binary comment marks the file as binary for transfer tools
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, len(objects), xref)
+	return out.Bytes()
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/gin-gonic/gin"
 	v1 "github.com/sandipdas/go-doctor-booking/backend/api/v1"
 	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
+	"github.com/sandipdas/go-doctor-booking/backend/middleware"
 	"github.com/sandipdas/go-doctor-booking/backend/models"
 	"gorm.io/gorm"
 )
@@ -28,16 +30,59 @@ func initDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// Accounts that predate email verification must not be locked out by it
+	backfillVerified := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "EmailVerified")
+
 	// Auto migrate models
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Doctor{},
 		&models.Schedule{},
 		&models.Appointment{},
+		&models.AppointmentStatusChange{},
+		&models.AppointmentReschedule{},
+		&models.AppointmentSeries{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.UserToken{},
+		&models.LoginThrottle{},
+		&models.RecoveryCode{},
+		&models.SecurityPolicy{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+		&models.APIKey{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.SeededPermission{},
+		&models.ScheduleTemplate{},
+		&models.ScheduleException{},
+		&models.WaitlistEntry{},
+		&models.WaitlistOffer{},
+		&models.SlotHold{},
+		&models.AppointmentType{},
+		&models.FollowUp{},
+		&models.CancellationPolicy{},
+		&models.Payment{},
+		&models.Invoice{},
+		&models.InvoiceCounter{},
+		&models.InsurancePolicy{},
+		&models.Claim{},
+		&models.ClaimStatusChange{},
 	); err != nil {
 		return nil, err
 	}
 
+	if backfillVerified {
+		if err := models.MarkExistingUsersVerified(db); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create the built-in roles and their default permissions
+	if err := models.SeedDefaultRoles(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
@@ -101,6 +146,16 @@ func main() {
 		}
 	}
 
+	// Load asymmetric JWT signing keys, falling back to HS256 when none are configured
+	keySet, err := middleware.LoadKeySetFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	if keySet != nil {
+		middleware.SetKeySet(keySet)
+		log.Printf("Signing tokens with key %s", keySet.Active().ID)
+	}
+
 	// Set Gin mode
 	if os.Getenv("ENV") == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -111,6 +166,9 @@ func main() {
 	// Initialize router
 	r := setupRouter()
 
+	// Expire stale slot holds and waitlist offers in the background
+	go v1.RunHoldReaper(db, mailer.NewSMTPMailerFromEnv(), config.GetDurationEnv("HOLD_REAPER_INTERVAL", time.Minute))
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
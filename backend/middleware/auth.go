@@ -1,6 +1,10 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
@@ -8,44 +12,206 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
 )
 
 var jwtKey = []byte(os.Getenv("JWT_SECRET"))
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID uint     `json:"user_id"`
-	Email  string   `json:"email"`
-	Role   string   `json:"role"`
+	UserID       uint   `json:"user_id"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"ver"`
+	// Purpose is empty for access tokens and set for restricted challenge tokens
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a new JWT token
-func GenerateToken(userID uint, email string, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// Challenge token purposes
+const (
+	// PurposeTwoFactorLogin is carried by tokens exchanged for access tokens after a TOTP check
+	PurposeTwoFactorLogin = "2fa_login"
+	// PurposeTwoFactorEnroll is carried by tokens that may only enroll in two-factor authentication
+	PurposeTwoFactorEnroll = "2fa_enroll"
+)
+
+// AccessTokenTTL returns the lifetime of access tokens
+func AccessTokenTTL() time.Duration {
+	return config.GetDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// newTokenID returns a random hex identifier of n bytes
+func newTokenID(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateToken generates a new short-lived JWT access token for the user
+func GenerateToken(user *models.User) (string, error) {
+	jti, err := newTokenID(16)
+	if err != nil {
+		return "", err
+	}
 
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:       user.ID,
+		Email:        user.Email,
+		Role:         string(user.Role),
+		TokenVersion: user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	// Use the JWT_SECRET environment variable or fall back to the default key
+	return signClaims(claims)
+}
+
+// GenerateChallengeToken generates a short-lived token that is only valid for
+// the given purpose and is rejected by AuthMiddleware
+func GenerateChallengeToken(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	jti, err := newTokenID(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return signClaims(&Claims{
+		UserID:       user.ID,
+		Email:        user.Email,
+		Role:         string(user.Role),
+		TokenVersion: user.TokenVersion,
+		Purpose:      purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+}
+
+// ParseChallengeToken validates a challenge token issued for the given purpose
+func ParseChallengeToken(tokenString, purpose string) (*Claims, error) {
+	claims, err := parseToken(tokenString, signingSecret())
+	if err != nil {
+		return nil, errors.New("invalid or expired challenge token")
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("token was not issued for this purpose")
+	}
+	return claims, nil
+}
+
+// signingSecret returns the JWT_SECRET environment variable or falls back to the default key
+func signingSecret() string {
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		jwtSecret = string(jwtKey)
 	}
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	return jwtSecret
+}
+
+// signClaims signs the claims with the active key of the installed key set,
+// or with the HS256 secret when no key set is configured
+func signClaims(claims *Claims) (string, error) {
+	if ks := CurrentKeySet(); ks != nil {
+		key := ks.Active()
+		token := jwt.NewWithClaims(key.Method, claims)
+		token.Header["kid"] = key.ID
+		return token.SignedString(key.Private)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(signingSecret()))
+}
+
+// parseToken verifies a token against the installed key set, selecting the key
+// by its kid header. HS256 tokens are accepted only when hmacSecret is set,
+// which keeps tokens issued before a switch to asymmetric keys valid until
+// JWT_SECRET is removed.
+func parseToken(tokenString, hmacSecret string) (*Claims, error) {
+	ks := CurrentKeySet()
 
-	return tokenString, err
+	var methods []string
+	if ks != nil {
+		methods = ks.Methods()
+	}
+	if hmacSecret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no token verification keys configured")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			return []byte(hmacSecret), nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if key.Method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("key %q does not use %s", kid, token.Method.Alg())
+		}
+		return key.Public, nil
+	}, jwt.WithValidMethods(methods))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
 }
 
-// AuthMiddleware verifies the JWT token
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware verifies the JWT token. When db is non-nil the token is also
+// checked against the server-side revocation state: the user must still be
+// active, the token version must match and the jti must not be revoked.
+// With a db, requests may instead authenticate with an API key in the
+// X-API-Key header; those carry no user and must pass ScopeMiddleware.
+func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+	jwtAuth := authMiddleware(db, "")
+	return func(c *gin.Context) {
+		raw := c.GetHeader(APIKeyHeader)
+		if raw == "" || db == nil {
+			jwtAuth(c)
+			return
+		}
+
+		key, err := AuthenticateAPIKey(db, raw, c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+			c.Abort()
+			return
+		}
+
+		c.Set("apiKey", key)
+		c.Set("apiKeyID", key.ID)
+		c.Next()
+	}
+}
+
+// EnrollmentAuthMiddleware behaves like AuthMiddleware but also accepts the
+// enrollment tokens given to users who must set up two-factor authentication
+// before they can log in
+func EnrollmentAuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return authMiddleware(db, "", PurposeTwoFactorEnroll)
+}
+
+// authMiddleware verifies the JWT token and accepts only the listed purposes
+func authMiddleware(db *gorm.DB, allowedPurposes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -66,32 +232,76 @@ func AuthMiddleware() gin.HandlerFunc {
 		if jwtSecret == "" {
 			jwtSecret = os.Getenv("JWT_SECRET")
 		}
-		if jwtSecret == "" {
+		if jwtSecret == "" && CurrentKeySet() == nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "JWT secret not configured"})
 			c.Abort()
 			return
 		}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := parseToken(tokenString, jwtSecret)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
+		if !containsString(allowedPurposes, claims.Purpose) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is not valid for this endpoint"})
+			c.Abort()
+			return
+		}
+
+		if db != nil && isRevoked(db, claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("userID", claims.UserID)
 		c.Set("userRole", claims.Role)
+		c.Set("tokenClaims", claims)
 		c.Next()
 	}
 }
 
+// isRevoked reports whether a token with valid signature must still be rejected
+func isRevoked(db *gorm.DB, claims *Claims) bool {
+	var user models.User
+	if err := db.Select("id", "active", "token_version").First(&user, claims.UserID).Error; err != nil {
+		return true
+	}
+	if !user.Active || user.TokenVersion != claims.TokenVersion {
+		return true
+	}
+
+	if claims.ID == "" {
+		return false
+	}
+	var count int64
+	if err := db.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&count).Error; err != nil {
+		return true
+	}
+	return count > 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // RoleMiddleware restricts access based on user role
 func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API keys are authorized by their scopes instead of a role
+		if c.GetBool("scopeAuthorized") {
+			c.Next()
+			return
+		}
+
 		userRole, exists := c.Get("userRole")
 		if !exists {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: No role information"})
@@ -26,7 +26,7 @@ func setupRouter() *gin.Engine {
 		c.Set("jwtSecret", "test-secret-key")
 		c.Next()
 	})
-	r.Use(AuthMiddleware())
+	r.Use(AuthMiddleware(nil))
 
 	r.GET("/protected", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "protected"})
@@ -0,0 +1,277 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is an asymmetric JWT key. Keys without a private part can only
+// verify tokens, which is how retired keys are kept during rotation.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds the key used to sign new tokens and every key accepted for verification
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeySet creates a key set that signs with the key identified by activeID
+func NewKeySet(activeID string, keys ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, key := range keys {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	active, ok := ks.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+	if active.Private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// Active returns the key used to sign new tokens
+func (ks *KeySet) Active() *SigningKey {
+	return ks.active
+}
+
+// Lookup returns the key with the given ID
+func (ks *KeySet) Lookup(kid string) (*SigningKey, bool) {
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// Methods returns the names of the signing algorithms in the set
+func (ks *KeySet) Methods() []string {
+	seen := make(map[string]bool)
+	methods := make([]string, 0, 2)
+	for _, key := range ks.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS returns the public keys of the set as a JSON Web Key Set
+func (ks *KeySet) JWKS() map[string][]JWK {
+	keys := make([]JWK, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.ID, Alg: key.Method.Alg(), Use: "sig"}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return map[string][]JWK{"keys": keys}
+}
+
+// ParseSigningKeyPEM parses a PEM encoded private or public key. RSA keys sign
+// with RS256 and P-256 EC keys with ES256.
+func ParseSigningKeyPEM(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM data found", kid)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", kid, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", kid, err)
+	}
+
+	key := &SigningKey{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case *ecdsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodES256, k, &k.PublicKey
+	case *ecdsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodES256, k
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", kid, parsed)
+	}
+
+	if ec, ok := key.Public.(*ecdsa.PublicKey); ok && ec.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("key %q: only P-256 EC keys are supported", kid)
+	}
+	return key, nil
+}
+
+// parseKeyFileList parses "kid:path,kid:path" into key IDs and file paths
+func parseKeyFileList(value string) ([][2]string, error) {
+	var entries [][2]string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(item, ":")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected kid:path", item)
+		}
+		entries = append(entries, [2]string{kid, path})
+	}
+	return entries, nil
+}
+
+// LoadKeySetFromEnv builds a key set from the environment:
+//
+//   - JWT_SIGNING_KEY_FILES: "kid:path,..." PEM private keys
+//   - JWT_SIGNING_KEY_PEM and JWT_SIGNING_KEY_ID: an inline PEM private key
+//   - JWT_VERIFY_KEY_FILES: "kid:path,..." PEM public keys of retired keys
+//   - JWT_ACTIVE_KEY_ID: the key used for signing, defaulting to the first private key
+//
+// It returns nil without error when no keys are configured, in which case
+// tokens are signed with HS256 and JWT_SECRET.
+func LoadKeySetFromEnv() (*KeySet, error) {
+	var keys []*SigningKey
+	var firstPrivate string
+
+	add := func(kid string, data []byte, requirePrivate bool) error {
+		key, err := ParseSigningKeyPEM(kid, data)
+		if err != nil {
+			return err
+		}
+		if requirePrivate && key.Private == nil {
+			return fmt.Errorf("key %q: expected a private key", kid)
+		}
+		if key.Private != nil && firstPrivate == "" {
+			firstPrivate = kid
+		}
+		keys = append(keys, key)
+		return nil
+	}
+
+	signingFiles, err := parseKeyFileList(os.Getenv("JWT_SIGNING_KEY_FILES"))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range signingFiles {
+		data, err := os.ReadFile(entry[1])
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry[0], err)
+		}
+		if err := add(entry[0], data, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if inline := os.Getenv("JWT_SIGNING_KEY_PEM"); inline != "" {
+		kid := os.Getenv("JWT_SIGNING_KEY_ID")
+		if kid == "" {
+			return nil, errors.New("JWT_SIGNING_KEY_ID is required with JWT_SIGNING_KEY_PEM")
+		}
+		// Allow the PEM to be passed on a single line with literal \n
+		if err := add(kid, []byte(strings.ReplaceAll(inline, `\n`, "\n")), true); err != nil {
+			return nil, err
+		}
+	}
+
+	verifyFiles, err := parseKeyFileList(os.Getenv("JWT_VERIFY_KEY_FILES"))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range verifyFiles {
+		data, err := os.ReadFile(entry[1])
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry[0], err)
+		}
+		if err := add(entry[0], data, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	activeID := os.Getenv("JWT_ACTIVE_KEY_ID")
+	if activeID == "" {
+		activeID = firstPrivate
+	}
+	return NewKeySet(activeID, keys...)
+}
+
+var (
+	keySetMu      sync.RWMutex
+	currentKeySet *KeySet
+)
+
+// SetKeySet installs the key set used to sign and verify tokens. Passing nil
+// reverts to HS256 with JWT_SECRET.
+func SetKeySet(ks *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	currentKeySet = ks
+}
+
+// CurrentKeySet returns the installed key set, or nil if HS256 is in use
+func CurrentKeySet() *KeySet {
+	keySetMu.RLock()
+	defer keySetMu.RUnlock()
+	return currentKeySet
+}
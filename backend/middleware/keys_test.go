@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyFiles writes an RSA and an EC private key plus the EC public key to dir
+func writeKeyFiles(t *testing.T, dir string) (rsaPath, ecPath, ecPubPath string) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	write := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+		require.NoError(t, os.WriteFile(path, data, 0600))
+		return path
+	}
+
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	ecPubDER, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+
+	rsaPath = write("rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	ecPath = write("ec.pem", "EC PRIVATE KEY", ecDER)
+	ecPubPath = write("ec.pub.pem", "PUBLIC KEY", ecPubDER)
+	return rsaPath, ecPath, ecPubPath
+}
+
+func clearKeyEnv(t *testing.T) {
+	for _, key := range []string{"JWT_SECRET", "JWT_SIGNING_KEY_FILES", "JWT_ACTIVE_KEY_ID",
+		"JWT_VERIFY_KEY_FILES", "JWT_SIGNING_KEY_ID", "JWT_SIGNING_KEY_PEM"} {
+		t.Setenv(key, "")
+	}
+}
+
+func authStatus(t *testing.T, token string) int {
+	r := gin.New()
+	r.Use(AuthMiddleware(nil))
+	r.GET("/protected", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "protected"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestKeySetRotation(t *testing.T) {
+	clearKeyEnv(t)
+	t.Cleanup(func() { SetKeySet(nil) })
+
+	rsaPath, ecPath, ecPubPath := writeKeyFiles(t, t.TempDir())
+	user := &models.User{Email: "rotate@example.com", Role: models.PatientRole}
+	user.ID = 1
+
+	// Sign with the EC key
+	t.Setenv("JWT_SIGNING_KEY_FILES", "ec-1:"+ecPath)
+	ks, err := LoadKeySetFromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, ks)
+	assert.Equal(t, "ec-1", ks.Active().ID)
+	SetKeySet(ks)
+
+	oldToken, err := GenerateToken(user)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, authStatus(t, oldToken))
+
+	// Rotate to the RSA key, keeping the EC public key for verification
+	t.Setenv("JWT_SIGNING_KEY_FILES", "rsa-2:"+rsaPath)
+	t.Setenv("JWT_VERIFY_KEY_FILES", "ec-1:"+ecPubPath)
+	ks, err = LoadKeySetFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "rsa-2", ks.Active().ID)
+	SetKeySet(ks)
+
+	newToken, err := GenerateToken(user)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, authStatus(t, newToken))
+	assert.Equal(t, http.StatusOK, authStatus(t, oldToken))
+
+	jwks := ks.JWKS()["keys"]
+	require.Len(t, jwks, 2)
+	for _, jwk := range jwks {
+		switch jwk.Kid {
+		case "rsa-2":
+			assert.Equal(t, "RSA", jwk.Kty)
+			assert.Equal(t, "RS256", jwk.Alg)
+			assert.NotEmpty(t, jwk.N)
+			assert.Equal(t, "AQAB", jwk.E)
+		case "ec-1":
+			assert.Equal(t, "EC", jwk.Kty)
+			assert.Equal(t, "ES256", jwk.Alg)
+			assert.Equal(t, "P-256", jwk.Crv)
+			assert.Len(t, jwk.X, 43)
+			assert.Len(t, jwk.Y, 43)
+		default:
+			t.Fatalf("unexpected key %q", jwk.Kid)
+		}
+	}
+
+	// Once the old key is retired its tokens are rejected
+	t.Setenv("JWT_VERIFY_KEY_FILES", "")
+	ks, err = LoadKeySetFromEnv()
+	require.NoError(t, err)
+	SetKeySet(ks)
+	assert.Equal(t, http.StatusUnauthorized, authStatus(t, oldToken))
+	assert.Equal(t, http.StatusOK, authStatus(t, newToken))
+}
+
+func TestKeySetRejectsHS256WithoutSecret(t *testing.T) {
+	clearKeyEnv(t)
+	t.Cleanup(func() { SetKeySet(nil) })
+
+	user := &models.User{Email: "legacy@example.com", Role: models.PatientRole}
+	user.ID = 1
+
+	t.Setenv("JWT_SECRET", "legacy-secret")
+	legacyToken, err := GenerateToken(user)
+	require.NoError(t, err)
+
+	rsaPath, _, _ := writeKeyFiles(t, t.TempDir())
+	t.Setenv("JWT_SIGNING_KEY_FILES", "rsa-1:"+rsaPath)
+	ks, err := LoadKeySetFromEnv()
+	require.NoError(t, err)
+	SetKeySet(ks)
+
+	// Legacy tokens stay valid while JWT_SECRET is configured
+	assert.Equal(t, http.StatusOK, authStatus(t, legacyToken))
+
+	t.Setenv("JWT_SECRET", "")
+	assert.Equal(t, http.StatusUnauthorized, authStatus(t, legacyToken))
+}
+
+func TestLoadKeySetFromEnvErrors(t *testing.T) {
+	clearKeyEnv(t)
+	_, _, ecPubPath := writeKeyFiles(t, t.TempDir())
+
+	ks, err := LoadKeySetFromEnv()
+	assert.NoError(t, err)
+	assert.Nil(t, ks)
+
+	t.Setenv("JWT_SIGNING_KEY_FILES", "missing-path")
+	_, err = LoadKeySetFromEnv()
+	assert.Error(t, err)
+
+	// A public key cannot sign
+	t.Setenv("JWT_SIGNING_KEY_FILES", "")
+	t.Setenv("JWT_VERIFY_KEY_FILES", "ec-1:"+ecPubPath)
+	_, err = LoadKeySetFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+)
+
+// RequirePermission allows the request if the caller's role has been granted
+// any of the given permissions. Like RoleMiddleware, it lets through API key
+// requests already authorized by ScopeMiddleware.
+func RequirePermission(db *gorm.DB, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("scopeAuthorized") {
+			c.Next()
+			return
+		}
+
+		role := c.GetString("userRole")
+		if role == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: No role information"})
+			c.Abort()
+			return
+		}
+
+		allowed, err := models.RoleHasPermission(db, models.UserRole(role), permissions...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
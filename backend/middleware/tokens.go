@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// RefreshTokenTTL returns the lifetime of refresh tokens
+func RefreshTokenTTL() time.Duration {
+	return config.GetDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// hashToken returns the hex SHA-256 digest under which a token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRefreshToken creates and stores a new refresh token for the user
+func IssueRefreshToken(db *gorm.DB, userID uint) (string, *models.RefreshToken, error) {
+	raw, err := newTokenID(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+	}
+	if err := db.Create(record).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, record, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one, revoking the old
+// token. Presenting an already rotated token is treated as theft: every
+// refresh token of the user is revoked and all access tokens are invalidated.
+func RotateRefreshToken(db *gorm.DB, raw string) (*models.User, string, error) {
+	var user models.User
+	var newRaw string
+	var reused bool
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(raw)).
+			First(&current).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if current.RevokedAt != nil && current.ReplacedByID != nil {
+			reused = true
+			return nil
+		}
+		if !current.Active() {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if !user.Active {
+			return ErrInvalidRefreshToken
+		}
+
+		var next *models.RefreshToken
+		var err error
+		newRaw, next, err = IssueRefreshToken(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": next.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if reused {
+		var current models.RefreshToken
+		if err := db.Where("token_hash = ?", hashToken(raw)).First(&current).Error; err == nil {
+			_ = RevokeAllTokens(db, current.UserID)
+		}
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	return &user, newRaw, nil
+}
+
+// RevokeRefreshToken revokes a single refresh token if it belongs to the user
+func RevokeRefreshToken(db *gorm.DB, userID uint, raw string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND user_id = ? AND revoked_at IS NULL", hashToken(raw), userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAccessToken adds an access token's jti to the revocation list until it expires
+func RevokeAccessToken(db *gorm.DB, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	// Expired entries no longer need to be checked
+	db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}).Error
+}
+
+// RevokeAllTokens invalidates every access and refresh token issued to the user
+func RevokeAllTokens(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// ErrInvalidUserToken is returned when a single-use token is unknown, used or expired
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// IssueUserToken creates a single-use token for the given purpose, invalidating
+// any earlier unused tokens for the same user and purpose
+func IssueUserToken(db *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	raw, err := newTokenID(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(raw),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	return raw, err
+}
+
+// ConsumeUserToken marks a single-use token as used and returns it. The update
+// is conditional so that a token can only be consumed once.
+func ConsumeUserToken(db *gorm.DB, raw string, purpose string) (*models.UserToken, error) {
+	var token models.UserToken
+	if err := db.Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).
+		First(&token).Error; err != nil {
+		return nil, ErrInvalidUserToken
+	}
+
+	now := time.Now()
+	result := db.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidUserToken
+	}
+
+	token.UsedAt = &now
+	return &token, nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API key scopes. Each one must be accepted by at least one route, otherwise
+// a key granted it would authorize nothing.
+const (
+	ScopeAppointmentsRead = "appointments:read"
+	ScopeDoctorsRead      = "doctors:read"
+	ScopeUsersRead        = "users:read"
+	ScopeUsersWrite       = "users:write"
+)
+
+// APIKeyScopes lists every scope that can be granted to an API key
+var APIKeyScopes = []string{
+	ScopeAppointmentsRead,
+	ScopeDoctorsRead,
+	ScopeUsersRead,
+	ScopeUsersWrite,
+}
+
+// IsValidScope reports whether scope is a known API key scope
+func IsValidScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is an admin-issued credential for machine-to-machine integrations.
+// Only the SHA-256 hash of the key is stored; Prefix identifies it in listings.
+type APIKey struct {
+	gorm.Model
+	Name        string     `json:"name" gorm:"not null"`
+	Prefix      string     `json:"prefix" gorm:"type:varchar(16);not null;index"`
+	KeyHash     string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes      string     `json:"-" gorm:"not null;default:''"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedByID uint       `json:"created_by_id"`
+}
+
+// ScopeList returns the scopes granted to the key
+func (k *APIKey) ScopeList() []string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(k.Scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// SetScopes stores the given scopes on the key
+func (k *APIKey) SetScopes(scopes []string) {
+	k.Scopes = strings.Join(scopes, ",")
+}
+
+// HasScope reports whether the key was granted scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key can be used at the given time
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
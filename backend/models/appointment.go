@@ -11,6 +11,13 @@ type Appointment struct {
 	Patient          User             `json:"patient" gorm:"foreignKey:PatientID"`
 	DoctorID         uint             `json:"doctor_id" gorm:"not null;index"`
 	Doctor           Doctor           `json:"doctor" gorm:"foreignKey:DoctorID"`
+	BookedByID       *uint            `json:"booked_by_id" gorm:"index"` // Staff member who booked on the patient's behalf
+	BookedBy         *User            `json:"booked_by,omitempty" gorm:"foreignKey:BookedByID"`
+	SeriesID         *uint            `json:"series_id" gorm:"index"` // Recurring series the appointment belongs to
+	AppointmentTypeID *uint           `json:"appointment_type_id" gorm:"index"`
+	AppointmentType  *AppointmentType `json:"appointment_type,omitempty" gorm:"foreignKey:AppointmentTypeID"`
+	BufferMinutes    int              `json:"buffer_minutes" gorm:"not null;default:0"` // Minutes kept free after the appointment
+	Fee              float64          `json:"fee" gorm:"not null;default:0"`            // Fee for the booked appointment type
 	AppointmentDate  time.Time        `json:"appointment_date" gorm:"not null;index"`
 	StartTime        time.Time        `json:"start_time" gorm:"not null"`
 	EndTime          time.Time        `json:"end_time" gorm:"not null"`
@@ -18,16 +25,51 @@ type Appointment struct {
 	Reason           string           `json:"reason" gorm:"type:text"`
 	Notes            string           `json:"notes" gorm:"type:text"`
 	IsFollowUp       bool             `json:"is_follow_up" gorm:"default:false"`
+	ParentAppointmentID *uint         `json:"parent_appointment_id" gorm:"index"` // Appointment this one follows up on
 	FollowUpNotes    string           `json:"follow_up_notes" gorm:"type:text"`
 	IsPaid           bool             `json:"is_paid" gorm:"default:false"`
 	PaymentAmount    float64          `json:"payment_amount" gorm:"default:0"`
 	PaymentReference string           `json:"payment_reference" gorm:"type:varchar(255)"`
 	CancellationReason string         `json:"cancellation_reason" gorm:"type:text"`
+	CancelledByID    *uint            `json:"cancelled_by_id"`
+	CancelledAt      *time.Time       `json:"cancelled_at"`
+	LateCancellationFee float64       `json:"late_cancellation_fee" gorm:"not null;default:0"` // Charged under the doctor's cancellation policy
+	InsurancePolicyID *uint           `json:"insurance_policy_id" gorm:"index"` // Policy the appointment is billed to
+	EligibilityStatus string          `json:"eligibility_status" gorm:"type:varchar(20);not null;default:'not_checked'"`
+	CopayAmount      float64          `json:"copay_amount" gorm:"not null;default:0"` // Patient's share of the fee when insurance covers it
+	StatusHistory    []AppointmentStatusChange `json:"status_history,omitempty" gorm:"foreignKey:AppointmentID"`
+	Reschedules      []AppointmentReschedule   `json:"reschedules,omitempty" gorm:"foreignKey:AppointmentID"`
 	CreatedAt        time.Time        `json:"created_at"`
 	UpdatedAt        time.Time        `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt   `json:"-" gorm:"index"`
 }
 
+// InLocation converts the appointment's times to loc so that they serialize
+// with that zone's offset. The instants themselves are unchanged.
+func (a *Appointment) InLocation(loc *time.Location) {
+	a.AppointmentDate = a.AppointmentDate.In(loc)
+	a.StartTime = a.StartTime.In(loc)
+	a.EndTime = a.EndTime.In(loc)
+}
+
+// BusyUntil returns when the doctor is free again after the appointment,
+// including its buffer
+func (a *Appointment) BusyUntil() time.Time {
+	return a.EndTime.Add(time.Duration(a.BufferMinutes) * time.Minute)
+}
+
+// AppointmentReschedule links an appointment's previous and new times
+type AppointmentReschedule struct {
+	gorm.Model
+	AppointmentID     uint      `json:"appointment_id" gorm:"not null;index"`
+	PreviousStartTime time.Time `json:"previous_start_time" gorm:"not null"`
+	PreviousEndTime   time.Time `json:"previous_end_time" gorm:"not null"`
+	NewStartTime      time.Time `json:"new_start_time" gorm:"not null"`
+	NewEndTime        time.Time `json:"new_end_time" gorm:"not null"`
+	RescheduledByID   uint      `json:"rescheduled_by_id" gorm:"not null"`
+	Reason            string    `json:"reason" gorm:"type:text"`
+}
+
 type Schedule struct {
 	gorm.Model
 	DoctorID    uint      `json:"doctor_id" gorm:"not null;index"`
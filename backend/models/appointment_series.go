@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Booking modes for appointment series
+const (
+	// SeriesAllOrNothing books every occurrence or none of them
+	SeriesAllOrNothing = "all_or_nothing"
+	// SeriesBestEffort books the occurrences that are available and skips the rest
+	SeriesBestEffort = "best_effort"
+)
+
+// Appointment series statuses
+const (
+	SeriesActive    = "active"
+	SeriesCancelled = "cancelled"
+)
+
+// MaxSeriesOccurrences caps how many appointments one series may create
+const MaxSeriesOccurrences = 52
+
+// AppointmentSeries groups the appointments booked from one weekly recurrence
+// rule, e.g. a course of physiotherapy sessions
+type AppointmentSeries struct {
+	gorm.Model
+	PatientID    uint          `json:"patient_id" gorm:"not null;index"`
+	DoctorID     uint          `json:"doctor_id" gorm:"not null;index"`
+	RRule        string        `json:"rrule" gorm:"type:varchar(255);not null"`
+	Count        int           `json:"count"`
+	Until        *time.Time    `json:"until"`
+	FirstStart   time.Time     `json:"first_start" gorm:"not null"`
+	Mode         string        `json:"mode" gorm:"type:varchar(20);not null"`
+	Status       string        `json:"status" gorm:"type:varchar(20);not null;default:'active'"`
+	Notes        string        `json:"notes" gorm:"type:text"`
+	Appointments []Appointment `json:"appointments,omitempty" gorm:"foreignKey:SeriesID"`
+}
+
+// Occurrences expands the recurrence from first, repeating first's wall-clock
+// time in loc on every matching day so that DST changes do not shift the
+// sessions. It stops after count occurrences when count is positive, after
+// the earlier of the rule's UNTIL and until, and at MaxSeriesOccurrences.
+func (r Recurrence) Occurrences(first time.Time, loc *time.Location, count int, until *time.Time) []time.Time {
+	if len(r.Weekdays) == 0 {
+		return nil
+	}
+	limit := MaxSeriesOccurrences
+	if count > 0 && count < limit {
+		limit = count
+	}
+	end := r.Until
+	if until != nil && (end == nil || until.Before(*end)) {
+		end = until
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	matches := make(map[time.Weekday]bool)
+	for _, day := range r.Weekdays {
+		matches[day] = true
+	}
+
+	first = first.In(loc)
+	firstDay := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	var occurrences []time.Time
+	for week := weekStart(firstDay); len(occurrences) < limit; week = week.AddDate(0, 0, 7*interval) {
+		for offset := 0; offset < 7 && len(occurrences) < limit; offset++ {
+			day := week.AddDate(0, 0, offset)
+			if day.Before(firstDay) || !matches[day.Weekday()] {
+				continue
+			}
+			if end != nil && day.After(civilDate(*end)) {
+				return occurrences
+			}
+			occurrences = append(occurrences, time.Date(day.Year(), day.Month(), day.Day(),
+				first.Hour(), first.Minute(), first.Second(), 0, loc))
+		}
+	}
+	return occurrences
+}
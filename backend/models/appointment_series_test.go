@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	format := func(times []time.Time) []string {
+		out := make([]string, 0, len(times))
+		for _, t := range times {
+			out = append(out, t.Format("Mon 2006-01-02 15:04 -07:00"))
+		}
+		return out
+	}
+
+	// Weekly on Mondays across the spring DST change keeps 09:00 local time
+	first := time.Date(2030, 3, 4, 9, 0, 0, 0, loc)
+	rec, err := ParseRRule("FREQ=WEEKLY;BYDAY=MO")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Mon 2030-03-04 09:00 -05:00",
+		"Mon 2030-03-11 09:00 -04:00",
+		"Mon 2030-03-18 09:00 -04:00",
+	}, format(rec.Occurrences(first, loc, 3, nil)))
+
+	// Twice a week every other week, ending on a date
+	rec, err = ParseRRule("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH")
+	require.NoError(t, err)
+	until := time.Date(2030, 3, 21, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{
+		"Mon 2030-03-04 09:00 -05:00",
+		"Thu 2030-03-07 09:00 -05:00",
+		"Mon 2030-03-18 09:00 -04:00",
+		"Thu 2030-03-21 09:00 -04:00",
+	}, format(rec.Occurrences(first, loc, 0, &until)))
+
+	// Days before the first session in its week are skipped, and the cap applies
+	rec, err = ParseRRule("FREQ=WEEKLY;BYDAY=SU,MO")
+	require.NoError(t, err)
+	occurrences := rec.Occurrences(time.Date(2030, 3, 5, 9, 0, 0, 0, loc), loc, 0, nil)
+	assert.Len(t, occurrences, MaxSeriesOccurrences)
+	assert.Equal(t, time.Sunday, occurrences[0].Weekday())
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// appointmentTransitions lists the statuses each appointment status may move to.
+// Statuses without an entry are terminal.
+var appointmentTransitions = map[string][]string{
+	StatusPending:     {StatusConfirmed, StatusCancelled, StatusRescheduled},
+	StatusConfirmed:   {StatusCompleted, StatusCancelled, StatusNoShow, StatusRescheduled},
+	StatusRescheduled: {StatusConfirmed, StatusCompleted, StatusCancelled, StatusNoShow, StatusRescheduled},
+}
+
+// InvalidTransitionError is returned when an appointment cannot move between two statuses
+type InvalidTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot change appointment status from %s to %s", e.From, e.To)
+}
+
+// CanTransition reports whether an appointment may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range appointmentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminalStatus reports whether no further transitions are allowed from status
+func IsTerminalStatus(status string) bool {
+	return len(appointmentTransitions[status]) == 0
+}
+
+// AppointmentStatusChange records who moved an appointment between statuses and when
+type AppointmentStatusChange struct {
+	gorm.Model
+	AppointmentID uint      `json:"appointment_id" gorm:"not null;index"`
+	FromStatus    string    `json:"from_status" gorm:"type:varchar(20);not null"`
+	ToStatus      string    `json:"to_status" gorm:"type:varchar(20);not null"`
+	ChangedByID   uint      `json:"changed_by_id" gorm:"not null;index"`
+	ChangedByRole UserRole  `json:"changed_by_role" gorm:"type:varchar(32)"`
+	Reason        string    `json:"reason" gorm:"type:text"`
+	ChangedAt     time.Time `json:"changed_at" gorm:"not null"`
+}
+
+// Transition moves the appointment to a new status and records the change.
+// It returns an *InvalidTransitionError if the move is not allowed.
+func (a *Appointment) Transition(tx *gorm.DB, to string, actorID uint, actorRole UserRole, reason string) error {
+	if !CanTransition(a.Status, to) {
+		return &InvalidTransitionError{From: a.Status, To: to}
+	}
+
+	change := AppointmentStatusChange{
+		AppointmentID: a.ID,
+		FromStatus:    a.Status,
+		ToStatus:      to,
+		ChangedByID:   actorID,
+		ChangedByRole: actorRole,
+		Reason:        reason,
+		ChangedAt:     time.Now(),
+	}
+	if err := tx.Model(a).Update("status", to).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return err
+	}
+
+	a.Status = to
+	return nil
+}
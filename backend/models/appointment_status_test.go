@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	testCases := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{StatusPending, StatusConfirmed, true},
+		{StatusPending, StatusCancelled, true},
+		{StatusPending, StatusCompleted, false},
+		{StatusPending, StatusNoShow, false},
+		{StatusConfirmed, StatusCompleted, true},
+		{StatusConfirmed, StatusNoShow, true},
+		{StatusConfirmed, StatusPending, false},
+		{StatusRescheduled, StatusConfirmed, true},
+		{StatusRescheduled, StatusCompleted, true},
+		{StatusRescheduled, StatusNoShow, true},
+		{StatusRescheduled, StatusPending, false},
+		{StatusCompleted, StatusConfirmed, false},
+		{StatusCancelled, StatusCompleted, false},
+		{StatusNoShow, StatusConfirmed, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.from+"->"+tc.to, func(t *testing.T) {
+			assert.Equal(t, tc.expected, CanTransition(tc.from, tc.to))
+		})
+	}
+}
+
+func TestIsTerminalStatus(t *testing.T) {
+	for _, status := range []string{StatusCancelled, StatusCompleted, StatusNoShow} {
+		assert.True(t, IsTerminalStatus(status), status)
+	}
+	for _, status := range []string{StatusPending, StatusConfirmed, StatusRescheduled} {
+		assert.False(t, IsTerminalStatus(status), status)
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AppointmentType is a kind of visit a doctor offers, such as a new
+// consultation, a follow-up or a procedure. Each type has its own length,
+// a buffer kept free after it and optionally its own fee.
+type AppointmentType struct {
+	gorm.Model
+	DoctorID      uint     `json:"doctor_id" gorm:"not null;index"`
+	Name          string   `json:"name" gorm:"type:varchar(100);not null"`
+	Description   string   `json:"description" gorm:"type:text"`
+	Duration      int      `json:"duration" gorm:"not null"`                 // Minutes
+	BufferMinutes int      `json:"buffer_minutes" gorm:"not null;default:0"` // Minutes kept free after each appointment
+	Fee           *float64 `json:"fee"`                                      // Overrides the doctor's consultation fee when set
+}
+
+// Length returns how long an appointment of this type lasts
+func (t *AppointmentType) Length() time.Duration {
+	return time.Duration(t.Duration) * time.Minute
+}
+
+// Buffer returns the time kept free after an appointment of this type
+func (t *AppointmentType) Buffer() time.Duration {
+	return time.Duration(t.BufferMinutes) * time.Minute
+}
+
+// FeeFor returns the fee charged for this type by the given doctor
+func (t *AppointmentType) FeeFor(doctor Doctor) float64 {
+	if t.Fee != nil {
+		return *t.Fee
+	}
+	return doctor.ConsultationFee
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppointmentTypeFeeFor(t *testing.T) {
+	doctor := Doctor{ConsultationFee: 80}
+	fee := 150.0
+
+	procedure := AppointmentType{Duration: 60, BufferMinutes: 15, Fee: &fee}
+	assert.Equal(t, 150.0, procedure.FeeFor(doctor))
+	assert.Equal(t, time.Hour, procedure.Length())
+	assert.Equal(t, 15*time.Minute, procedure.Buffer())
+
+	followUp := AppointmentType{Duration: 15}
+	assert.Equal(t, 80.0, followUp.FeeFor(doctor))
+	assert.Equal(t, time.Duration(0), followUp.Buffer())
+}
@@ -18,9 +18,11 @@ type UserRole string
 
 // Role constants
 const (
-	PatientRole UserRole = "patient"
-	DoctorRole  UserRole = "doctor"
-	AdminRole   UserRole = "admin"
+	PatientRole      UserRole = "patient"
+	DoctorRole       UserRole = "doctor"
+	AdminRole        UserRole = "admin"
+	ReceptionistRole UserRole = "receptionist"
+	NurseRole        UserRole = "nurse"
 )
 
 // Status constants for appointments
@@ -29,6 +31,8 @@ const (
 	StatusConfirmed  = "confirmed"
 	StatusCancelled = "cancelled"
 	StatusCompleted  = "completed"
+	StatusNoShow     = "no_show"
+	StatusRescheduled = "rescheduled"
 )
 
 // TimeSlot represents an available time slot for appointments
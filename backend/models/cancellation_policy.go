@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultCancellationPeriodDays is the period MaxCancellations applies to
+// when a policy does not set one
+const DefaultCancellationPeriodDays = 30
+
+// CancellationPolicy sets how a doctor's patients may cancel. Cancelling with
+// less than MinNoticeHours' notice is a late cancellation, which is either
+// refused or charged LateCancelFee. Doctors without a policy allow any
+// cancellation free of charge.
+type CancellationPolicy struct {
+	gorm.Model
+	DoctorID               uint    `json:"doctor_id" gorm:"not null;uniqueIndex"`
+	MinNoticeHours         int     `json:"min_notice_hours" gorm:"not null;default:0"`
+	LateCancelFee          float64 `json:"late_cancel_fee" gorm:"not null;default:0"`
+	BlockLateCancellations bool    `json:"block_late_cancellations" gorm:"not null;default:false"` // Refuse late cancellations instead of charging
+	MaxCancellations       int     `json:"max_cancellations" gorm:"not null;default:0"`            // Per period; 0 means unlimited
+	PeriodDays             int     `json:"period_days" gorm:"not null;default:30"`
+}
+
+// MinNotice returns how long before an appointment it can be cancelled on time
+func (p *CancellationPolicy) MinNotice() time.Duration {
+	return time.Duration(p.MinNoticeHours) * time.Hour
+}
+
+// IsLate reports whether cancelling at now an appointment that starts at
+// start is a late cancellation
+func (p *CancellationPolicy) IsLate(start, now time.Time) bool {
+	return start.Sub(now) < p.MinNotice()
+}
+
+// PeriodStart returns the start of the period, ending at now, that
+// MaxCancellations applies to
+func (p *CancellationPolicy) PeriodStart(now time.Time) time.Time {
+	days := p.PeriodDays
+	if days <= 0 {
+		days = DefaultCancellationPeriodDays
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+// Cancel cancels the appointment, recording who cancelled it and why along
+// with any late cancellation fee charged
+func (a *Appointment) Cancel(tx *gorm.DB, actorID uint, actorRole UserRole, reason string, lateFee float64) error {
+	if err := a.Transition(tx, StatusCancelled, actorID, actorRole, reason); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(a).Updates(map[string]interface{}{
+		"cancellation_reason":   reason,
+		"cancelled_by_id":       actorID,
+		"cancelled_at":          now,
+		"late_cancellation_fee": lateFee,
+	}).Error; err != nil {
+		return err
+	}
+
+	a.CancellationReason = reason
+	a.CancelledByID = &actorID
+	a.CancelledAt = &now
+	a.LateCancellationFee = lateFee
+	return nil
+}
+
+// RefundDue returns how much of a paid appointment's fee is refunded when it
+// is cancelled. Any late cancellation fee is kept.
+func (a *Appointment) RefundDue() float64 {
+	if !a.IsPaid {
+		return 0
+	}
+	due := a.PaymentAmount - a.LateCancellationFee
+	if due < 0 {
+		return 0
+	}
+	return due
+}
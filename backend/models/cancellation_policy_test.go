@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancellationPolicyIsLate(t *testing.T) {
+	now := time.Date(2030, time.March, 10, 9, 0, 0, 0, time.UTC)
+	policy := CancellationPolicy{MinNoticeHours: 24}
+
+	assert.False(t, policy.IsLate(now.Add(25*time.Hour), now))
+	assert.False(t, policy.IsLate(now.Add(24*time.Hour), now))
+	assert.True(t, policy.IsLate(now.Add(23*time.Hour), now))
+
+	// Without a notice period no cancellation is late
+	assert.False(t, (&CancellationPolicy{}).IsLate(now.Add(time.Minute), now))
+}
+
+func TestCancellationPolicyPeriodStart(t *testing.T) {
+	now := time.Date(2030, time.March, 10, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, now.AddDate(0, 0, -7), (&CancellationPolicy{PeriodDays: 7}).PeriodStart(now))
+	assert.Equal(t, now.AddDate(0, 0, -DefaultCancellationPeriodDays), (&CancellationPolicy{}).PeriodStart(now))
+}
+
+func TestAppointmentRefundDue(t *testing.T) {
+	assert.Equal(t, 0.0, (&Appointment{PaymentAmount: 50}).RefundDue())
+	assert.Equal(t, 50.0, (&Appointment{IsPaid: true, PaymentAmount: 50}).RefundDue())
+	assert.Equal(t, 30.0, (&Appointment{IsPaid: true, PaymentAmount: 50, LateCancellationFee: 20}).RefundDue())
+	assert.Equal(t, 0.0, (&Appointment{IsPaid: true, PaymentAmount: 10, LateCancellationFee: 20}).RefundDue())
+}
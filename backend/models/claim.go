@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Claim statuses
+const (
+	ClaimSubmitted = "submitted"
+	ClaimApproved  = "approved"
+	ClaimDenied    = "denied"
+	ClaimPaid      = "paid"
+)
+
+// claimTransitions lists the statuses each claim status may move to. A
+// denied claim may be corrected and submitted again; paid claims are final.
+var claimTransitions = map[string][]string{
+	ClaimSubmitted: {ClaimApproved, ClaimDenied},
+	ClaimApproved:  {ClaimPaid},
+	ClaimDenied:    {ClaimSubmitted},
+}
+
+// InvalidClaimTransitionError is returned when a claim cannot move between two statuses
+type InvalidClaimTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *InvalidClaimTransitionError) Error() string {
+	return fmt.Sprintf("cannot change claim status from %s to %s", e.From, e.To)
+}
+
+// CanTransitionClaim reports whether a claim may move from one status to another
+func CanTransitionClaim(from, to string) bool {
+	for _, allowed := range claimTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Claim bills a payer for the insured part of a completed appointment
+type Claim struct {
+	gorm.Model
+	AppointmentID  uint                `json:"appointment_id" gorm:"not null;uniqueIndex"`
+	PolicyID       uint                `json:"policy_id" gorm:"not null;index"`
+	Policy         *InsurancePolicy    `json:"policy,omitempty" gorm:"foreignKey:PolicyID"`
+	PatientID      uint                `json:"patient_id" gorm:"not null;index"`
+	DoctorID       uint                `json:"doctor_id" gorm:"not null;index"`
+	PayerReference string              `json:"payer_reference" gorm:"type:varchar(100)"` // The payer's own claim number
+	BilledAmount   float64             `json:"billed_amount" gorm:"not null"`
+	ApprovedAmount float64             `json:"approved_amount" gorm:"not null;default:0"`
+	PaidAmount     float64             `json:"paid_amount" gorm:"not null;default:0"`
+	Status         string              `json:"status" gorm:"type:varchar(20);not null;default:'submitted';index"`
+	DenialReason   string              `json:"denial_reason" gorm:"type:text"`
+	SubmittedAt    time.Time           `json:"submitted_at" gorm:"not null"`
+	DecidedAt      *time.Time          `json:"decided_at"`
+	PaidAt         *time.Time          `json:"paid_at"`
+	History        []ClaimStatusChange `json:"history,omitempty" gorm:"foreignKey:ClaimID"`
+}
+
+// ClaimStatusChange records who moved a claim between statuses and when
+type ClaimStatusChange struct {
+	gorm.Model
+	ClaimID     uint      `json:"claim_id" gorm:"not null;index"`
+	FromStatus  string    `json:"from_status" gorm:"type:varchar(20)"` // Empty for the first submission
+	ToStatus    string    `json:"to_status" gorm:"type:varchar(20);not null"`
+	ChangedByID uint      `json:"changed_by_id" gorm:"not null"`
+	Note        string    `json:"note" gorm:"type:text"`
+	ChangedAt   time.Time `json:"changed_at" gorm:"not null"`
+}
+
+// Transition moves the claim to a new status, applying updates such as the
+// approved amount along with it, and records the change. It returns an
+// *InvalidClaimTransitionError if the move is not allowed.
+func (c *Claim) Transition(tx *gorm.DB, to string, actorID uint, note string, updates map[string]interface{}) error {
+	if !CanTransitionClaim(c.Status, to) {
+		return &InvalidClaimTransitionError{From: c.Status, To: to}
+	}
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	from := c.Status
+	now := time.Now()
+	updates["status"] = to
+	switch to {
+	case ClaimSubmitted:
+		updates["submitted_at"] = now
+		updates["decided_at"] = nil
+	case ClaimApproved, ClaimDenied:
+		updates["decided_at"] = now
+	case ClaimPaid:
+		updates["paid_at"] = now
+	}
+	if err := tx.Model(c).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	change := ClaimStatusChange{
+		ClaimID:     c.ID,
+		FromStatus:  from,
+		ToStatus:    to,
+		ChangedByID: actorID,
+		Note:        note,
+		ChangedAt:   now,
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return err
+	}
+
+	c.Status = to
+	return nil
+}
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"errors"
 	"time"
+	// Embedded zone database so doctor time zones resolve even on hosts
+	// without /usr/share/zoneinfo
+	_ "time/tzdata"
 
 	"gorm.io/gorm"
 )
@@ -26,6 +30,8 @@ type Doctor struct {
 	Experience       int            `json:"experience" gorm:"not null;default:0"`
 	Bio              string         `json:"bio" gorm:"type:text"`
 	ConsultationFee  float64        `json:"consultation_fee" gorm:"not null;default:0"`
+	SlotDuration     int            `json:"slot_duration" gorm:"not null;default:30"` // Minutes per bookable slot
+	TimeZone         string         `json:"time_zone" gorm:"type:varchar(64);not null;default:'UTC'"` // IANA zone the schedule is expressed in
 	Available        bool           `json:"available" gorm:"default:true"`
 	AverageRating    float64        `json:"average_rating" gorm:"default:0"`
 	TotalRatings     int            `json:"total_ratings" gorm:"default:0"`
@@ -40,3 +46,36 @@ type Doctor struct {
 	UpdatedAt        time.Time     `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 }
+
+// DefaultTimeZone is used for doctors that have not configured a time zone
+const DefaultTimeZone = "UTC"
+
+// ErrInvalidTimeZone is returned by LoadTimeZone for names that are not IANA zones
+var ErrInvalidTimeZone = errors.New("time zone must be an IANA name such as Europe/London")
+
+// LoadTimeZone resolves an IANA time zone name. The server's "Local" zone is
+// rejected because it would make schedules depend on where the API runs.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimeZone
+	}
+	return loc, nil
+}
+
+// Location returns the doctor's time zone. Schedules, templates and
+// exceptions store wall-clock times that are interpreted in this zone.
+func (d *Doctor) Location() *time.Location {
+	name := d.TimeZone
+	if name == "" {
+		name = DefaultTimeZone
+	}
+	loc, err := LoadTimeZone(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTimeZone(t *testing.T) {
+	loc, err := LoadTimeZone("America/New_York")
+	assert.NoError(t, err)
+	assert.Equal(t, "America/New_York", loc.String())
+
+	for _, name := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		_, err := LoadTimeZone(name)
+		assert.ErrorIs(t, err, ErrInvalidTimeZone, name)
+	}
+}
+
+func TestDoctorLocation(t *testing.T) {
+	assert.Equal(t, "Asia/Kolkata", (&Doctor{TimeZone: "Asia/Kolkata"}).Location().String())
+	// Unset or unknown zones fall back to UTC
+	assert.Equal(t, time.UTC, (&Doctor{}).Location())
+	assert.Equal(t, time.UTC, (&Doctor{TimeZone: "Nowhere/Special"}).Location())
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Follow-up statuses
+const (
+	FollowUpPending   = "pending"
+	FollowUpBooked    = "booked"
+	FollowUpCancelled = "cancelled"
+)
+
+// FollowUp is a doctor's recommendation that a patient comes back after a
+// completed appointment. The patient books it within the recommended window,
+// if any, at the follow-up's discounted fee.
+type FollowUp struct {
+	gorm.Model
+	ParentAppointmentID uint             `json:"parent_appointment_id" gorm:"not null;index"`
+	PatientID           uint             `json:"patient_id" gorm:"not null;index"`
+	DoctorID            uint             `json:"doctor_id" gorm:"not null;index"`
+	Doctor              Doctor           `json:"doctor,omitempty" gorm:"foreignKey:DoctorID"`
+	AppointmentTypeID   *uint            `json:"appointment_type_id"`
+	AppointmentType     *AppointmentType `json:"appointment_type,omitempty" gorm:"foreignKey:AppointmentTypeID"`
+	FromDate            *time.Time       `json:"from_date"`                                  // Calendar date in the doctor's zone
+	ToDate              *time.Time       `json:"to_date"`                                    // Calendar date in the doctor's zone
+	DiscountPercent     int              `json:"discount_percent" gorm:"not null;default:0"` // 100 makes the follow-up free
+	Notes               string           `json:"notes" gorm:"type:text"`
+	Status              string           `json:"status" gorm:"type:varchar(20);not null;default:'pending';index"`
+	AppointmentID       *uint            `json:"appointment_id"` // Set once the follow-up is booked
+}
+
+// Covers reports whether date falls within the recommended window. A
+// follow-up without a window may be booked on any date.
+func (f *FollowUp) Covers(date time.Time) bool {
+	day := civilDate(date)
+	if f.FromDate != nil && day.Before(civilDate(*f.FromDate)) {
+		return false
+	}
+	if f.ToDate != nil && day.After(civilDate(*f.ToDate)) {
+		return false
+	}
+	return true
+}
+
+// DiscountedFee applies the follow-up's discount to fee, rounded to cents
+func (f *FollowUp) DiscountedFee(fee float64) float64 {
+	return math.Round(fee*float64(100-f.DiscountPercent)) / 100
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowUpCovers(t *testing.T) {
+	from := time.Date(2030, time.March, 10, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2030, time.March, 17, 0, 0, 0, 0, time.UTC)
+	followUp := FollowUp{FromDate: &from, ToDate: &to}
+
+	assert.False(t, followUp.Covers(time.Date(2030, time.March, 9, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, followUp.Covers(time.Date(2030, time.March, 10, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, followUp.Covers(time.Date(2030, time.March, 17, 23, 59, 0, 0, time.UTC)))
+	assert.False(t, followUp.Covers(time.Date(2030, time.March, 18, 0, 0, 0, 0, time.UTC)))
+
+	open := FollowUp{}
+	assert.True(t, open.Covers(time.Date(2031, time.January, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestFollowUpDiscountedFee(t *testing.T) {
+	assert.Equal(t, 80.0, (&FollowUp{}).DiscountedFee(80))
+	assert.Equal(t, 60.0, (&FollowUp{DiscountPercent: 25}).DiscountedFee(80))
+	assert.Equal(t, 33.33, (&FollowUp{DiscountPercent: 33}).DiscountedFee(49.75))
+	assert.Equal(t, 0.0, (&FollowUp{DiscountPercent: 100}).DiscountedFee(80))
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a local user to an account at an external identity provider
+type UserIdentity struct {
+	gorm.Model
+	UserID  uint   `json:"user_id" gorm:"not null;index"`
+	User    User   `json:"-" gorm:"foreignKey:UserID"`
+	Issuer  string `json:"issuer" gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_issuer_subject"`
+	Subject string `json:"subject" gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_issuer_subject"`
+	Email   string `json:"email" gorm:"type:varchar(100)"`
+}
+
+// OIDCLoginState holds the per-login values of an in-flight OpenID Connect
+// authorization request until the provider redirects back
+type OIDCLoginState struct {
+	gorm.Model
+	State        string    `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Nonce        string    `json:"-" gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `json:"-" gorm:"type:varchar(128);not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Eligibility statuses of an appointment's insurance coverage
+const (
+	EligibilityNotChecked = "not_checked"
+	EligibilityEligible   = "eligible"
+	EligibilityIneligible = "ineligible" // The patient's policies do not cover the appointment date
+	EligibilitySelfPay    = "self_pay"   // The patient has no policy on file
+)
+
+// InsurancePolicy is a patient's cover with an insurer. The patient pays the
+// co-pay of each visit and the payer is claimed for the rest of the fee.
+type InsurancePolicy struct {
+	gorm.Model
+	PatientID          uint       `json:"patient_id" gorm:"not null;index"`
+	Payer              string     `json:"payer" gorm:"type:varchar(100);not null"`
+	PlanName           string     `json:"plan_name" gorm:"type:varchar(100)"`
+	MemberID           string     `json:"member_id" gorm:"type:varchar(64);not null"`
+	GroupNumber        string     `json:"group_number" gorm:"type:varchar(64)"`
+	CoverageStart      time.Time  `json:"coverage_start" gorm:"not null"`                // Calendar date
+	CoverageEnd        *time.Time `json:"coverage_end"`                                  // Calendar date, inclusive; nil while the cover runs
+	CopayAmount        float64    `json:"copay_amount" gorm:"not null;default:0"`        // Fixed amount the patient pays per visit
+	CoinsurancePercent int        `json:"coinsurance_percent" gorm:"not null;default:0"` // Share of the rest of the fee the patient pays
+}
+
+// Covers reports whether the policy is in force on date
+func (p *InsurancePolicy) Covers(date time.Time) bool {
+	day := civilDate(date)
+	if day.Before(civilDate(p.CoverageStart)) {
+		return false
+	}
+	return p.CoverageEnd == nil || !day.After(civilDate(*p.CoverageEnd))
+}
+
+// Copay returns the part of fee the patient pays: the fixed co-pay, capped at
+// the fee, plus the coinsurance share of the remainder, rounded to cents
+func (p *InsurancePolicy) Copay(fee float64) float64 {
+	if fee <= 0 {
+		return 0
+	}
+	copay := math.Min(p.CopayAmount, fee)
+	copay += (fee - copay) * float64(p.CoinsurancePercent) / 100
+	return math.Round(copay*100) / 100
+}
+
+// PatientShare returns what the patient pays for the appointment: the co-pay
+// when insurance covers it and the whole fee otherwise
+func (a *Appointment) PatientShare() float64 {
+	if a.EligibilityStatus == EligibilityEligible {
+		return a.CopayAmount
+	}
+	return a.Fee
+}
+
+// InsuredAmount returns the part of the fee that can be claimed from the payer
+func (a *Appointment) InsuredAmount() float64 {
+	if a.EligibilityStatus != EligibilityEligible {
+		return 0
+	}
+	return math.Round((a.Fee-a.CopayAmount)*100) / 100
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsurancePolicyCovers(t *testing.T) {
+	start := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2030, time.December, 31, 0, 0, 0, 0, time.UTC)
+	policy := InsurancePolicy{CoverageStart: start, CoverageEnd: &end}
+
+	assert.False(t, policy.Covers(time.Date(2029, time.December, 31, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, policy.Covers(start))
+	assert.True(t, policy.Covers(time.Date(2030, time.December, 31, 18, 0, 0, 0, time.UTC)))
+	assert.False(t, policy.Covers(time.Date(2031, time.January, 1, 0, 0, 0, 0, time.UTC)))
+
+	policy.CoverageEnd = nil
+	assert.True(t, policy.Covers(time.Date(2040, time.June, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestInsurancePolicyCopay(t *testing.T) {
+	testCases := []struct {
+		name        string
+		copay       float64
+		coinsurance int
+		fee         float64
+		expected    float64
+	}{
+		{"Fixed co-pay", 20, 0, 100, 20},
+		{"Co-pay capped at the fee", 20, 0, 15, 15},
+		{"Coinsurance only", 0, 10, 100, 10},
+		{"Co-pay and coinsurance", 20, 10, 100, 28},
+		{"Rounded to cents", 0, 15, 33.33, 5},
+		{"Full cover", 0, 0, 100, 0},
+		{"No fee", 20, 10, 0, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := InsurancePolicy{CopayAmount: tc.copay, CoinsurancePercent: tc.coinsurance}
+			assert.Equal(t, tc.expected, policy.Copay(tc.fee))
+		})
+	}
+}
+
+func TestAppointmentPatientShare(t *testing.T) {
+	appointment := Appointment{Fee: 80, CopayAmount: 20, EligibilityStatus: EligibilityEligible}
+	assert.Equal(t, 20.0, appointment.PatientShare())
+	assert.Equal(t, 60.0, appointment.InsuredAmount())
+
+	appointment.EligibilityStatus = EligibilityIneligible
+	assert.Equal(t, 80.0, appointment.PatientShare())
+	assert.Equal(t, 0.0, appointment.InsuredAmount())
+}
+
+func TestCanTransitionClaim(t *testing.T) {
+	assert.True(t, CanTransitionClaim(ClaimSubmitted, ClaimApproved))
+	assert.True(t, CanTransitionClaim(ClaimSubmitted, ClaimDenied))
+	assert.True(t, CanTransitionClaim(ClaimApproved, ClaimPaid))
+	assert.True(t, CanTransitionClaim(ClaimDenied, ClaimSubmitted))
+
+	assert.False(t, CanTransitionClaim(ClaimSubmitted, ClaimPaid))
+	assert.False(t, CanTransitionClaim(ClaimDenied, ClaimPaid))
+	assert.False(t, CanTransitionClaim(ClaimPaid, ClaimSubmitted))
+	assert.False(t, CanTransitionClaim(ClaimApproved, ClaimDenied))
+}
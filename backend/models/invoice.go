@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Billing document kinds
+const (
+	InvoiceKindInvoice = "invoice"
+	InvoiceKindReceipt = "receipt"
+)
+
+// invoicePrefixes are the number prefixes of each document kind
+var invoicePrefixes = map[string]string{
+	InvoiceKindInvoice: "INV",
+	InvoiceKindReceipt: "RCT",
+}
+
+// IsValidInvoiceKind reports whether kind is a known billing document kind
+func IsValidInvoiceKind(kind string) bool {
+	_, ok := invoicePrefixes[kind]
+	return ok
+}
+
+// Invoice is a numbered billing document for an appointment. The parties and
+// amounts are copied when it is issued, so later changes to the doctor, the
+// patient or the appointment do not alter a document that has been handed out.
+type Invoice struct {
+	gorm.Model
+	Number           string     `json:"number" gorm:"type:varchar(32);not null;uniqueIndex"` // e.g. INV-2026-000042
+	Kind             string     `json:"kind" gorm:"type:varchar(10);not null;uniqueIndex:idx_invoice_appointment_kind"`
+	AppointmentID    uint       `json:"appointment_id" gorm:"not null;uniqueIndex:idx_invoice_appointment_kind"`
+	Issuer           string     `json:"issuer" gorm:"type:varchar(100);not null"`
+	PatientID        uint       `json:"patient_id" gorm:"not null;index"`
+	DoctorID         uint       `json:"doctor_id" gorm:"not null;index"`
+	PatientName      string     `json:"patient_name" gorm:"type:varchar(100);not null"`
+	PatientEmail     string     `json:"patient_email" gorm:"type:varchar(100)"`
+	DoctorName       string     `json:"doctor_name" gorm:"type:varchar(100);not null"`
+	Specialization   string     `json:"specialization" gorm:"type:varchar(100)"`
+	Description      string     `json:"description" gorm:"type:varchar(255);not null"`
+	Subtotal         float64    `json:"subtotal" gorm:"not null"`
+	TaxLabel         string     `json:"tax_label" gorm:"type:varchar(32)"`
+	TaxRate          float64    `json:"tax_rate" gorm:"not null;default:0"` // Percent
+	TaxAmount        float64    `json:"tax_amount" gorm:"not null;default:0"`
+	Total            float64    `json:"total" gorm:"not null"`
+	Currency         string     `json:"currency" gorm:"type:varchar(3);not null"`
+	PaymentReference string     `json:"payment_reference" gorm:"type:varchar(255)"`
+	PaidAt           *time.Time `json:"paid_at"`
+	IssuedAt         time.Time  `json:"issued_at" gorm:"not null"`
+}
+
+// InvoiceCounter holds the last number issued in a kind's sequence for a year
+type InvoiceCounter struct {
+	Kind       string `gorm:"type:varchar(10);primaryKey"`
+	Year       int    `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber int    `gorm:"not null;default:0"`
+}
+
+// FormatInvoiceNumber returns the document number for the sequence value seq
+// of kind in year, such as INV-2026-000042
+func FormatInvoiceNumber(kind string, year, seq int) string {
+	return fmt.Sprintf("%s-%d-%06d", invoicePrefixes[kind], year, seq)
+}
+
+// NextInvoiceNumber reserves the next number in kind's sequence for year.
+// The counter row stays locked until tx ends, so concurrent transactions take
+// numbers one after another, and a rolled back transaction leaves no gap.
+func NextInvoiceNumber(tx *gorm.DB, kind string, year int) (string, error) {
+	counter := InvoiceCounter{Kind: kind, Year: year}
+	// Another transaction creating the same counter makes this wait for it
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counter).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("kind = ? AND year = ?", kind, year).
+		First(&counter).Error; err != nil {
+		return "", err
+	}
+	counter.LastNumber++
+	if err := tx.Model(&InvoiceCounter{}).
+		Where("kind = ? AND year = ?", kind, year).
+		Update("last_number", counter.LastNumber).Error; err != nil {
+		return "", err
+	}
+	return FormatInvoiceNumber(kind, year, counter.LastNumber), nil
+}
+
+// SplitTax splits a tax inclusive total into its net amount and the tax at
+// ratePercent, rounded to cents so that the two add up to total
+func SplitTax(total, ratePercent float64) (net, tax float64) {
+	if ratePercent <= 0 {
+		return total, 0
+	}
+	net = math.Round(total*10000/(100+ratePercent)) / 100
+	return net, math.Round((total-net)*100) / 100
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatInvoiceNumber(t *testing.T) {
+	assert.Equal(t, "INV-2026-000042", FormatInvoiceNumber(InvoiceKindInvoice, 2026, 42))
+	assert.Equal(t, "RCT-2027-000001", FormatInvoiceNumber(InvoiceKindReceipt, 2027, 1))
+	assert.Equal(t, "INV-2026-1234567", FormatInvoiceNumber(InvoiceKindInvoice, 2026, 1234567))
+
+	assert.True(t, IsValidInvoiceKind(InvoiceKindReceipt))
+	assert.False(t, IsValidInvoiceKind("quote"))
+}
+
+func TestSplitTax(t *testing.T) {
+	testCases := []struct {
+		total, rate, net, tax float64
+	}{
+		{50, 0, 50, 0},
+		{50, 20, 41.67, 8.33},
+		{120, 20, 100, 20},
+		{99.99, 7.5, 93.01, 6.98},
+		{0.01, 20, 0.01, 0},
+	}
+	for _, tc := range testCases {
+		net, tax := SplitTax(tc.total, tc.rate)
+		assert.Equal(t, tc.net, net, "net of %v at %v%%", tc.total, tc.rate)
+		assert.Equal(t, tc.tax, tax, "tax of %v at %v%%", tc.total, tc.rate)
+		assert.InDelta(t, tc.total, net+tax, 0.001)
+	}
+}
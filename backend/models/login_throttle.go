@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginThrottle tracks failed login attempts for an account or client IP.
+// It is stored in the database so that all replicas share lockout state.
+type LoginThrottle struct {
+	gorm.Model
+	Key           string     `json:"key" gorm:"type:varchar(255);uniqueIndex;not null"` // "account:<email>" or "ip:<address>"
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+// LockedFor returns how long the key remains locked, or zero if it is not locked
+func (t *LoginThrottle) LockedFor(now time.Time) time.Duration {
+	if t.LockedUntil == nil || !now.Before(*t.LockedUntil) {
+		return 0
+	}
+	return t.LockedUntil.Sub(now)
+}
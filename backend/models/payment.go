@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payment statuses
+const (
+	PaymentPending           = "pending"
+	PaymentSucceeded         = "succeeded"
+	PaymentFailed            = "failed"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+	PaymentCanceled          = "canceled" // Nothing is due any more
+)
+
+// Payment collects an appointment's fee through a payment gateway and
+// records any refund of it. Each appointment has at most one payment, which
+// the patient may retry after a declined card. Its intent is replaced while
+// unpaid if the amount due changes.
+type Payment struct {
+	gorm.Model
+	AppointmentID  uint       `json:"appointment_id" gorm:"not null;uniqueIndex"`
+	PatientID      uint       `json:"patient_id" gorm:"not null;index"`
+	Gateway        string     `json:"gateway" gorm:"type:varchar(20);not null"`
+	IntentID       string     `json:"intent_id" gorm:"type:varchar(255);not null;uniqueIndex"`
+	ClientSecret   string     `json:"-" gorm:"type:varchar(255)"` // Only returned to the paying patient
+	Amount         float64    `json:"amount" gorm:"not null"`
+	Currency       string     `json:"currency" gorm:"type:varchar(3);not null"`
+	Status         string     `json:"status" gorm:"type:varchar(20);not null;default:'pending';index"`
+	RefundedAmount float64    `json:"refunded_amount" gorm:"not null;default:0"`
+	RefundID       string     `json:"refund_id" gorm:"type:varchar(255)"`
+	PaidAt         *time.Time `json:"paid_at"`
+	RefundedAt     *time.Time `json:"refunded_at"`
+}
+
+// Refundable returns how much of a succeeded payment can still be refunded
+func (p *Payment) Refundable() float64 {
+	if p.Status != PaymentSucceeded && p.Status != PaymentPartiallyRefunded {
+		return 0
+	}
+	return p.Amount - p.RefundedAmount
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Permissions checked by RequirePermission
+const (
+	PermissionViewAvailability         = "availability:view"
+	PermissionBookAppointment          = "appointments:book"
+	PermissionManageOwnAppointments    = "appointments:manage_own"
+	PermissionBookOnBehalf             = "appointments:book_on_behalf"
+	PermissionReadAllAppointments      = "appointments:read_all"
+	PermissionViewDoctorDashboard      = "doctor_dashboard:view"
+	PermissionManageSchedules          = "schedules:manage"
+	PermissionManageDoctorAppointments = "doctor_appointments:manage"
+	PermissionReadUsers                = "users:read"
+	PermissionManageUsers              = "users:manage"
+	PermissionManageSecurity           = "security:manage"
+	PermissionManageRoles              = "roles:manage"
+	PermissionManageClaims             = "claims:manage"
+)
+
+// Permissions lists every permission that can be assigned to a role
+var Permissions = []string{
+	PermissionViewAvailability,
+	PermissionBookAppointment,
+	PermissionManageOwnAppointments,
+	PermissionBookOnBehalf,
+	PermissionReadAllAppointments,
+	PermissionViewDoctorDashboard,
+	PermissionManageSchedules,
+	PermissionManageDoctorAppointments,
+	PermissionReadUsers,
+	PermissionManageUsers,
+	PermissionManageSecurity,
+	PermissionManageRoles,
+	PermissionManageClaims,
+}
+
+// IsValidPermission reports whether permission is a known permission
+func IsValidPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRolePermissions are assigned to the built-in roles when they are first
+// created. They reproduce the access the role checks in routes.go used to grant.
+var DefaultRolePermissions = map[UserRole][]string{
+	PatientRole: {
+		PermissionViewAvailability,
+		PermissionBookAppointment,
+		PermissionManageOwnAppointments,
+	},
+	DoctorRole: {
+		PermissionViewDoctorDashboard,
+		PermissionManageSchedules,
+		PermissionManageDoctorAppointments,
+	},
+	AdminRole: {
+		PermissionViewDoctorDashboard,
+		PermissionManageSchedules,
+		PermissionManageDoctorAppointments,
+		PermissionBookOnBehalf,
+		PermissionReadAllAppointments,
+		PermissionReadUsers,
+		PermissionManageUsers,
+		PermissionManageSecurity,
+		PermissionManageRoles,
+		PermissionManageClaims,
+	},
+	ReceptionistRole: {
+		PermissionViewAvailability,
+		PermissionBookOnBehalf,
+		PermissionReadAllAppointments,
+		PermissionReadUsers,
+	},
+	NurseRole: {
+		PermissionViewAvailability,
+		PermissionReadAllAppointments,
+	},
+}
+
+// Role is a named set of permissions that users can be assigned
+type Role struct {
+	gorm.Model
+	Name        UserRole `json:"name" gorm:"type:varchar(32);uniqueIndex;not null"`
+	Description string   `json:"description"`
+	BuiltIn     bool     `json:"built_in" gorm:"not null;default:false"`
+}
+
+// RolePermission grants a permission to a role
+type RolePermission struct {
+	gorm.Model
+	Role       UserRole `json:"role" gorm:"type:varchar(32);not null;uniqueIndex:idx_role_permission"`
+	Permission string   `json:"permission" gorm:"type:varchar(64);not null;uniqueIndex:idx_role_permission"`
+}
+
+// SeededPermission records that a default permission has been granted to a
+// built-in role once, so that it is not granted again after an administrator
+// removes it
+type SeededPermission struct {
+	gorm.Model
+	Role       UserRole `json:"role" gorm:"type:varchar(32);not null;uniqueIndex:idx_seeded_permission"`
+	Permission string   `json:"permission" gorm:"type:varchar(64);not null;uniqueIndex:idx_seeded_permission"`
+}
+
+// SeedDefaultRoles creates any missing built-in role and grants each default
+// permission that has not been seeded before. Defaults added in later releases
+// reach existing roles, while permissions removed by administrators stay
+// removed across restarts.
+func SeedDefaultRoles(db *gorm.DB) error {
+	for _, name := range []UserRole{PatientRole, DoctorRole, AdminRole, ReceptionistRole, NurseRole} {
+		role := Role{Name: name, BuiltIn: true}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&role).Error; err != nil {
+			return err
+		}
+		for _, permission := range DefaultRolePermissions[name] {
+			if err := seedPermission(db, name, permission); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// seedPermission grants permission to role unless it has been seeded before
+func seedPermission(db *gorm.DB, role UserRole, permission string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&SeededPermission{Role: role, Permission: permission})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&RolePermission{Role: role, Permission: permission}).Error
+	})
+}
+
+// SetRolePermissions replaces the permissions granted to a role
+func SetRolePermissions(db *gorm.DB, role UserRole, permissions []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("role = ?", role).Delete(&RolePermission{}).Error; err != nil {
+			return err
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+
+		rows := make([]RolePermission, 0, len(permissions))
+		for _, p := range permissions {
+			rows = append(rows, RolePermission{Role: role, Permission: p})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+	})
+}
+
+// RoleExists reports whether a role has been defined
+func RoleExists(db *gorm.DB, role UserRole) (bool, error) {
+	var count int64
+	err := db.Model(&Role{}).Where("name = ?", role).Count(&count).Error
+	return count > 0, err
+}
+
+// RoleHasPermission reports whether the role has been granted any of the permissions
+func RoleHasPermission(db *gorm.DB, role UserRole, permissions ...string) (bool, error) {
+	var count int64
+	err := db.Model(&RolePermission{}).
+		Where("role = ? AND permission IN ?", role, permissions).
+		Count(&count).Error
+	return count > 0, err
+}
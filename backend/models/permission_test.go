@@ -0,0 +1,20 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRolePermissions(t *testing.T) {
+	for role, permissions := range DefaultRolePermissions {
+		for _, p := range permissions {
+			assert.True(t, IsValidPermission(p), "role %s has unknown permission %s", role, p)
+		}
+	}
+
+	// The admin role must be able to manage roles or it could never be changed back
+	assert.Contains(t, DefaultRolePermissions[AdminRole], PermissionManageRoles)
+	assert.NotContains(t, DefaultRolePermissions[PatientRole], PermissionReadAllAppointments)
+	assert.False(t, IsValidPermission("everything"))
+}
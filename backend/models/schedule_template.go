@@ -0,0 +1,210 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// rruleWeekdays maps RRULE BYDAY codes to weekdays
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ScheduleTemplate is a weekly recurring working window, e.g. Mon/Wed 09:00-13:00.
+// It follows the RRULE model FREQ=WEEKLY;INTERVAL=n;BYDAY=...;UNTIL=... and is
+// expanded on the fly by the availability engine.
+type ScheduleTemplate struct {
+	gorm.Model
+	DoctorID       uint       `json:"doctor_id" gorm:"not null;index"`
+	Label          string     `json:"label"`
+	Weekdays       string     `json:"weekdays" gorm:"type:varchar(32);not null"`  // Comma separated BYDAY codes, e.g. "MO,WE"
+	Interval       int        `json:"interval" gorm:"not null;default:1"`         // Repeat every n weeks
+	StartTime      string     `json:"start_time" gorm:"type:varchar(5);not null"` // Format: "15:04"
+	EndTime        string     `json:"end_time" gorm:"type:varchar(5);not null"`   // Format: "15:04"
+	EffectiveFrom  time.Time  `json:"effective_from" gorm:"not null"`
+	EffectiveUntil *time.Time `json:"effective_until"`
+}
+
+// ScheduleException blocks out a date, or part of it, for holidays and leave.
+// Empty start and end times block the whole day.
+type ScheduleException struct {
+	gorm.Model
+	DoctorID  uint      `json:"doctor_id" gorm:"not null;index"`
+	Date      time.Time `json:"date" gorm:"not null;index"`
+	StartTime string    `json:"start_time" gorm:"type:varchar(5)"` // Format: "15:04"
+	EndTime   string    `json:"end_time" gorm:"type:varchar(5)"`   // Format: "15:04"
+	Reason    string    `json:"reason"`
+}
+
+// FullDay reports whether the exception blocks the whole day
+func (e *ScheduleException) FullDay() bool {
+	return e.StartTime == "" && e.EndTime == ""
+}
+
+// Recurrence is the parsed form of a weekly RRULE
+type Recurrence struct {
+	Weekdays []time.Weekday
+	Interval int
+	Until    *time.Time
+}
+
+// ParseWeekdays parses comma separated BYDAY codes such as "MO,WE"
+func ParseWeekdays(value string) ([]time.Weekday, error) {
+	var weekdays []time.Weekday
+	seen := make(map[time.Weekday]bool)
+	for _, code := range strings.Split(value, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		day, ok := rruleWeekdays[code]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", code)
+		}
+		if !seen[day] {
+			seen[day] = true
+			weekdays = append(weekdays, day)
+		}
+	}
+	if len(weekdays) == 0 {
+		return nil, errors.New("at least one weekday is required")
+	}
+	return weekdays, nil
+}
+
+// FormatWeekdays returns the BYDAY codes for the weekdays
+func FormatWeekdays(weekdays []time.Weekday) string {
+	codes := make([]string, 0, len(weekdays))
+	for _, day := range weekdays {
+		codes = append(codes, strings.ToUpper(day.String()[:2]))
+	}
+	return strings.Join(codes, ",")
+}
+
+// ParseRRule parses the weekly subset of RFC 5545 recurrence rules:
+// FREQ=WEEKLY with BYDAY, and optionally INTERVAL and UNTIL
+func ParseRRule(rule string) (Recurrence, error) {
+	rec := Recurrence{Interval: 1}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+
+	var freq, byDay string
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return rec, fmt.Errorf("invalid rule part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			freq = strings.ToUpper(value)
+		case "BYDAY":
+			byDay = value
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return rec, fmt.Errorf("invalid interval %q", value)
+			}
+			rec.Interval = interval
+		case "UNTIL":
+			until, err := parseRRuleDate(value)
+			if err != nil {
+				return rec, err
+			}
+			rec.Until = &until
+		default:
+			return rec, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if freq != "WEEKLY" {
+		return rec, errors.New("only FREQ=WEEKLY is supported")
+	}
+	weekdays, err := ParseWeekdays(byDay)
+	if err != nil {
+		return rec, err
+	}
+	rec.Weekdays = weekdays
+	return rec, nil
+}
+
+func parseRRuleDate(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102", "20060102T150405Z"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL date %q", value)
+}
+
+// RRule returns the template's recurrence as an RRULE string
+func (t *ScheduleTemplate) RRule() string {
+	rule := "FREQ=WEEKLY"
+	if t.Interval > 1 {
+		rule += ";INTERVAL=" + strconv.Itoa(t.Interval)
+	}
+	rule += ";BYDAY=" + t.Weekdays
+	if t.EffectiveUntil != nil {
+		rule += ";UNTIL=" + t.EffectiveUntil.Format("20060102")
+	}
+	return rule
+}
+
+// OccursOn reports whether the template produces a window on the given date
+func (t *ScheduleTemplate) OccursOn(date time.Time) bool {
+	day := civilDate(date)
+	from := civilDate(t.EffectiveFrom)
+	if day.Before(from) {
+		return false
+	}
+	if t.EffectiveUntil != nil && day.After(civilDate(*t.EffectiveUntil)) {
+		return false
+	}
+
+	weekdays, err := ParseWeekdays(t.Weekdays)
+	if err != nil {
+		return false
+	}
+	matches := false
+	for _, wd := range weekdays {
+		if wd == day.Weekday() {
+			matches = true
+			break
+		}
+	}
+	if !matches {
+		return false
+	}
+
+	interval := t.Interval
+	if interval <= 1 {
+		return true
+	}
+	weeks := int(weekStart(day).Sub(weekStart(from)).Hours() / (24 * 7))
+	return weeks%interval == 0
+}
+
+// civilDate truncates t to midnight UTC of its calendar date. Dates are
+// stored as midnight UTC, so t is read in UTC whatever zone it was loaded in.
+func civilDate(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// weekStart returns the Monday of the week containing date
+func weekStart(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7
+	return date.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRRule(t *testing.T) {
+	rec, err := ParseRRule("RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20301231")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Weekday{time.Monday, time.Wednesday}, rec.Weekdays)
+	assert.Equal(t, 2, rec.Interval)
+	if assert.NotNil(t, rec.Until) {
+		assert.Equal(t, "2030-12-31", rec.Until.Format("2006-01-02"))
+	}
+
+	for _, rule := range []string{
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=MO;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=MO;COUNT=3",
+	} {
+		_, err := ParseRRule(rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestScheduleTemplateOccursOn(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	until := date("2030-01-31")
+
+	// Every other week on Monday and Wednesday, starting Wednesday 2030-01-02
+	template := ScheduleTemplate{
+		Weekdays:       "MO,WE",
+		Interval:       2,
+		EffectiveFrom:  date("2030-01-02"),
+		EffectiveUntil: &until,
+	}
+
+	testCases := []struct {
+		date     string
+		expected bool
+	}{
+		{"2029-12-30", false}, // Before the effective range
+		{"2030-01-02", true},  // Wednesday of the first week
+		{"2030-01-03", false}, // Thursday
+		{"2030-01-07", false}, // Monday of an off week
+		{"2030-01-14", true},  // Monday two weeks after the first week
+		{"2030-01-16", true},
+		{"2030-01-28", true},
+		{"2030-02-11", false}, // After the effective range
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.date, func(t *testing.T) {
+			assert.Equal(t, tc.expected, template.OccursOn(date(tc.date)))
+		})
+	}
+
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20300131", template.RRule())
+}
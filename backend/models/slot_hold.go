@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Slot hold statuses
+const (
+	HoldActive    = "active"
+	HoldConverted = "converted"
+	HoldReleased  = "released"
+	HoldExpired   = "expired"
+)
+
+// SlotHold reserves a slot for a patient while they complete checkout. Until
+// ExpiresAt nobody else can book the slot; the holder converts it into an
+// appointment by booking with the hold's ID.
+type SlotHold struct {
+	gorm.Model
+	PatientID         uint      `json:"patient_id" gorm:"not null;index"`
+	DoctorID          uint      `json:"doctor_id" gorm:"not null;index"`
+	AppointmentTypeID *uint     `json:"appointment_type_id"`
+	StartTime         time.Time `json:"start_time" gorm:"not null"`
+	EndTime           time.Time `json:"end_time" gorm:"not null"` // Includes the appointment type's buffer
+	ExpiresAt         time.Time `json:"expires_at" gorm:"not null;index"`
+	Status            string    `json:"status" gorm:"type:varchar(20);not null;default:'active';index"`
+	AppointmentID     *uint     `json:"appointment_id"`
+}
+
+// Active reports whether the hold still reserves its slot at now
+func (h *SlotHold) Active(now time.Time) bool {
+	return h.Status == HoldActive && now.Before(h.ExpiresAt)
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlotHoldActive(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	hold := SlotHold{Status: HoldActive, ExpiresAt: now.Add(10 * time.Minute)}
+	assert.True(t, hold.Active(now))
+	assert.False(t, hold.Active(now.Add(10*time.Minute)))
+
+	for _, status := range []string{HoldConverted, HoldReleased, HoldExpired} {
+		hold.Status = status
+		assert.False(t, hold.Active(now), status)
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a long-lived, single-use credential exchanged for new access
+// tokens. Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	gorm.Model
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash    string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+}
+
+// Active reports whether the refresh token can still be used
+func (t *RefreshToken) Active() bool {
+	return t.RevokedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// RevokedToken lists access token IDs (jti) that must be rejected before they expire
+type RevokedToken struct {
+	gorm.Model
+	JTI       string    `json:"jti" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// Purposes for single-use user tokens
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+)
+
+// UserToken is a single-use, expiring token emailed to a user, e.g. for email
+// verification or password reset. Only the SHA-256 hash of the token is stored.
+type UserToken struct {
+	gorm.Model
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Purpose   string     `json:"purpose" gorm:"type:varchar(32);not null;index"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a single-use code that can replace a TOTP code at login.
+// Only the bcrypt hash of the code is stored.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash string     `json:"-" gorm:"type:varchar(60);not null"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+// SecurityPolicy holds the admin-managed authentication policy. Only one row is used.
+type SecurityPolicy struct {
+	gorm.Model
+	RequireTwoFactorRoles string `json:"-" gorm:"type:varchar(255)"` // Comma-separated list of roles
+}
+
+// TwoFactorRoles returns the roles that must use two-factor authentication
+func (p *SecurityPolicy) TwoFactorRoles() []UserRole {
+	roles := make([]UserRole, 0)
+	for _, role := range strings.Split(p.RequireTwoFactorRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, UserRole(role))
+		}
+	}
+	return roles
+}
+
+// SetTwoFactorRoles replaces the roles that must use two-factor authentication
+func (p *SecurityPolicy) SetTwoFactorRoles(roles []UserRole) {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+	p.RequireTwoFactorRoles = strings.Join(names, ",")
+}
+
+// RequiresTwoFactor reports whether users with the role must use two-factor authentication
+func (p *SecurityPolicy) RequiresTwoFactor(role UserRole) bool {
+	for _, r := range p.TwoFactorRoles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -12,8 +12,10 @@ type User struct {
 	Name           string    `json:"name" gorm:"type:varchar(100);not null"`
 	Email          string    `json:"email" gorm:"type:varchar(100);uniqueIndex;not null"`
 	Password       string    `json:"-" gorm:"type:varchar(255);not null"`
-	Role           UserRole  `json:"role" gorm:"type:varchar(20);not null;default:'patient'"`
+	Role           UserRole  `json:"role" gorm:"type:varchar(32);not null;default:'patient'"`
 	Active         bool      `json:"active" gorm:"default:true"`
+	EmailVerified  bool      `json:"email_verified" gorm:"not null;default:false"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 	Phone          string    `json:"phone" gorm:"type:varchar(20)"`
 	DateOfBirth    time.Time `json:"date_of_birth"`
 	Gender         string    `json:"gender" gorm:"type:varchar(10)"`
@@ -24,6 +26,10 @@ type User struct {
 	PostalCode     string    `json:"postal_code" gorm:"type:varchar(20)"`
 	ProfilePicture string    `json:"profile_picture" gorm:"type:varchar(255)"`
 	LastLogin      time.Time `json:"last_login"`
+	TokenVersion   int       `json:"-" gorm:"not null;default:0"` // Bumped to invalidate all issued tokens
+	TOTPSecret     string    `json:"-" gorm:"type:varchar(64)"`
+	TOTPEnabled    bool      `json:"totp_enabled" gorm:"not null;default:false"`
+	TOTPLastStep   int64     `json:"-" gorm:"not null;default:0"` // Last accepted TOTP step, to reject replays
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
@@ -52,6 +58,15 @@ func (u *User) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
+// MarkExistingUsersVerified treats every existing account as verified as of
+// its creation. It runs once, when the email_verified column is first added.
+func MarkExistingUsersVerified(db *gorm.DB) error {
+	return db.Unscoped().Model(&User{}).Where("email_verified = ?", false).UpdateColumns(map[string]interface{}{
+		"email_verified":    true,
+		"email_verified_at": gorm.Expr("created_at"),
+	}).Error
+}
+
 // CheckPassword verifies the provided password
 func (u *User) CheckPassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
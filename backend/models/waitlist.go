@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Waitlist entry statuses
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistOffered   = "offered"
+	WaitlistBooked    = "booked"
+	WaitlistCancelled = "cancelled"
+)
+
+// Waitlist offer statuses
+const (
+	OfferPending  = "pending"
+	OfferAccepted = "accepted"
+	OfferDeclined = "declined"
+	OfferExpired  = "expired"
+)
+
+// WaitlistEntry records a patient's interest in any slot with a doctor
+// between two calendar dates. Entries are served in the order they were made.
+type WaitlistEntry struct {
+	gorm.Model
+	PatientID     uint            `json:"patient_id" gorm:"not null;index"`
+	DoctorID      uint            `json:"doctor_id" gorm:"not null;index"`
+	Doctor        Doctor          `json:"doctor,omitempty" gorm:"foreignKey:DoctorID"`
+	FromDate      time.Time       `json:"from_date" gorm:"not null"` // Calendar date in the doctor's zone
+	ToDate        time.Time       `json:"to_date" gorm:"not null"`   // Calendar date in the doctor's zone
+	Notes         string          `json:"notes" gorm:"type:text"`
+	Status        string          `json:"status" gorm:"type:varchar(20);not null;default:'waiting';index"`
+	AppointmentID *uint           `json:"appointment_id"` // Set once an offer is accepted
+	Offers        []WaitlistOffer `json:"offers,omitempty" gorm:"foreignKey:EntryID"`
+}
+
+// Covers reports whether date falls within the entry's date range
+func (e *WaitlistEntry) Covers(date time.Time) bool {
+	day := civilDate(date)
+	return !day.Before(civilDate(e.FromDate)) && !day.After(civilDate(e.ToDate))
+}
+
+// Open reports whether the entry is still waiting for a slot
+func (e *WaitlistEntry) Open() bool {
+	return e.Status == WaitlistWaiting || e.Status == WaitlistOffered
+}
+
+// WaitlistOffer holds a freed slot for one waitlisted patient until ExpiresAt.
+// While pending, the slot is not bookable by anyone else.
+type WaitlistOffer struct {
+	gorm.Model
+	EntryID           uint       `json:"entry_id" gorm:"not null;index"`
+	PatientID         uint       `json:"patient_id" gorm:"not null;index"`
+	DoctorID          uint       `json:"doctor_id" gorm:"not null;index"`
+	StartTime         time.Time  `json:"start_time" gorm:"not null"`
+	EndTime           time.Time  `json:"end_time" gorm:"not null"`
+	AppointmentTypeID *uint      `json:"appointment_type_id"` // Type of the visit that freed the slot, booked on accept
+	ExpiresAt         time.Time  `json:"expires_at" gorm:"not null;index"`
+	Status            string     `json:"status" gorm:"type:varchar(20);not null;default:'pending';index"`
+	RespondedAt       *time.Time `json:"responded_at"`
+	AppointmentID     *uint      `json:"appointment_id"`
+}
+
+// Active reports whether the offer is still holding its slot at now
+func (o *WaitlistOffer) Active(now time.Time) bool {
+	return o.Status == OfferPending && now.Before(o.ExpiresAt)
+}
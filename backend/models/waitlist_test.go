@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitlistEntryCovers(t *testing.T) {
+	entry := WaitlistEntry{
+		FromDate: time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC),
+		ToDate:   time.Date(2030, 1, 9, 0, 0, 0, 0, time.UTC),
+	}
+
+	assert.False(t, entry.Covers(time.Date(2030, 1, 6, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, entry.Covers(time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, entry.Covers(time.Date(2030, 1, 9, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, entry.Covers(time.Date(2030, 1, 10, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestWaitlistOfferActive(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	offer := WaitlistOffer{Status: OfferPending, ExpiresAt: now.Add(time.Minute)}
+	assert.True(t, offer.Active(now))
+	assert.False(t, offer.Active(now.Add(time.Minute)))
+
+	offer.Status = OfferDeclined
+	assert.False(t, offer.Active(now))
+}
@@ -0,0 +1,69 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sandipdas/go-doctor-booking/backend/oidc"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+// authorize follows the authorization URL to the mock provider and returns the code
+func authorize(t *testing.T, authURL string) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("Failed to call provider: %v", err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid callback URL: %v", err)
+	}
+	return callback.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	mock := testhelper.NewMockOIDCProvider("client")
+	defer mock.Close()
+	mock.Claims = map[string]interface{}{"sub": "user-1", "email": "user@example.com", "groups": []string{"a", "b"}}
+
+	provider := oidc.NewProvider(mock.Config("http://localhost/callback"))
+	ctx := context.Background()
+	verifier, _ := oidc.RandomString()
+
+	t.Run("Valid code", func(t *testing.T) {
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", verifier)
+		assert.NoError(t, err)
+
+		claims, err := provider.Exchange(ctx, authorize(t, authURL), verifier, "nonce-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", claims.String("sub"))
+		assert.Equal(t, []string{"a", "b"}, claims.Strings("groups"))
+	})
+
+	t.Run("Wrong PKCE verifier", func(t *testing.T) {
+		authURL, _ := provider.AuthCodeURL(ctx, "state", "nonce-2", verifier)
+		_, err := provider.Exchange(ctx, authorize(t, authURL), "wrong-verifier", "nonce-2")
+		assert.Error(t, err)
+	})
+
+	t.Run("Nonce mismatch", func(t *testing.T) {
+		authURL, _ := provider.AuthCodeURL(ctx, "state", "nonce-3", verifier)
+		_, err := provider.Exchange(ctx, authorize(t, authURL), verifier, "other-nonce")
+		assert.Error(t, err)
+	})
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
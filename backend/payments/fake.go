@@ -0,0 +1,181 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FakeGateway keeps intents and refunds in memory instead of calling a real
+// gateway. Its webhooks use the same signature scheme as StripeGateway. It is
+// intended for tests and local development.
+type FakeGateway struct {
+	WebhookSecret string
+
+	mu      sync.Mutex
+	next    int
+	intents map[string]*Intent
+	refunds []Refund
+	keys    map[string]interface{}
+}
+
+// NewFakeGateway creates an empty FakeGateway
+func NewFakeGateway(webhookSecret string) *FakeGateway {
+	return &FakeGateway{
+		WebhookSecret: webhookSecret,
+		intents:       make(map[string]*Intent),
+		keys:          make(map[string]interface{}),
+	}
+}
+
+// Name implements PaymentGateway
+func (g *FakeGateway) Name() string {
+	return "fake"
+}
+
+// CreateIntent implements PaymentGateway
+func (g *FakeGateway) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if previous, ok := g.keys[req.IdempotencyKey].(*Intent); ok && req.IdempotencyKey != "" {
+		copied := *previous
+		return &copied, nil
+	}
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("fake: amount must be positive")
+	}
+
+	g.next++
+	id := fmt.Sprintf("pi_fake_%d", g.next)
+	intent := &Intent{
+		ID:           id,
+		ClientSecret: id + "_secret",
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		Status:       IntentRequiresPayment,
+	}
+	g.intents[id] = intent
+	if req.IdempotencyKey != "" {
+		g.keys[req.IdempotencyKey] = intent
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+// CancelIntent implements PaymentGateway
+func (g *FakeGateway) CancelIntent(ctx context.Context, intentID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return fmt.Errorf("fake: no such payment intent %s", intentID)
+	}
+	if intent.Status == IntentSucceeded {
+		return fmt.Errorf("fake: payment intent %s has already succeeded", intentID)
+	}
+	intent.Status = IntentCanceled
+	return nil
+}
+
+// Refund implements PaymentGateway
+func (g *FakeGateway) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if previous, ok := g.keys[idempotencyKey].(*Refund); ok && idempotencyKey != "" {
+		copied := *previous
+		return &copied, nil
+	}
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("fake: no such payment intent %s", intentID)
+	}
+	if intent.Status != IntentSucceeded {
+		return nil, fmt.Errorf("fake: payment intent %s has not succeeded", intentID)
+	}
+	refunded := int64(0)
+	for _, refund := range g.refunds {
+		if refund.IntentID == intentID {
+			refunded += refund.Amount
+		}
+	}
+	if amount <= 0 || refunded+amount > intent.Amount {
+		return nil, fmt.Errorf("fake: refund of %d exceeds the remaining amount", amount)
+	}
+
+	g.next++
+	refund := Refund{ID: fmt.Sprintf("re_fake_%d", g.next), IntentID: intentID, Amount: amount, Status: "succeeded"}
+	g.refunds = append(g.refunds, refund)
+	if idempotencyKey != "" {
+		stored := refund
+		g.keys[idempotencyKey] = &stored
+	}
+	return &refund, nil
+}
+
+// ParseWebhook implements PaymentGateway
+func (g *FakeGateway) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := VerifySignature(g.WebhookSecret, payload, header.Get(SignatureHeader), time.Now()); err != nil {
+		return nil, err
+	}
+	return decodeEvent(payload)
+}
+
+// Succeed marks an intent as paid, as if the client had confirmed it, and
+// returns the signed webhook the gateway would send
+func (g *FakeGateway) Succeed(intentID string) ([]byte, http.Header, error) {
+	return g.complete(intentID, IntentSucceeded, EventPaymentSucceeded)
+}
+
+// Fail returns the signed webhook for a declined payment of an intent
+func (g *FakeGateway) Fail(intentID string) ([]byte, http.Header, error) {
+	return g.complete(intentID, IntentRequiresPayment, EventPaymentFailed)
+}
+
+func (g *FakeGateway) complete(intentID, status, eventType string) ([]byte, http.Header, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, nil, fmt.Errorf("fake: no such payment intent %s", intentID)
+	}
+	intent.Status = status
+
+	g.next++
+	payload := EncodeEvent(fmt.Sprintf("evt_fake_%d", g.next), eventType, intent.ID, intent.Amount, intent.Currency)
+	header := http.Header{}
+	header.Set(SignatureHeader, SignPayload(g.WebhookSecret, payload, time.Now()))
+	return payload, header, nil
+}
+
+// Intent returns a copy of an intent
+func (g *FakeGateway) Intent(intentID string) (Intent, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return Intent{}, false
+	}
+	return *intent, true
+}
+
+// Refunds returns a copy of all refunds made
+func (g *FakeGateway) Refunds() []Refund {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Refund(nil), g.refunds...)
+}
+
+// Reset discards all intents and refunds
+func (g *FakeGateway) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.intents = make(map[string]*Intent)
+	g.refunds = nil
+	g.keys = make(map[string]interface{})
+}
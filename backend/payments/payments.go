@@ -0,0 +1,111 @@
+// Package payments takes card payments for appointments through a payment
+// gateway. The server creates a payment intent for the amount due, the client
+// confirms it with the gateway directly using the intent's client secret, and
+// the gateway reports the outcome through signed webhooks.
+package payments
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+)
+
+// Webhook event types handled by the API
+const (
+	EventPaymentSucceeded = "payment_intent.succeeded"
+	EventPaymentFailed    = "payment_intent.payment_failed"
+)
+
+// Intent statuses reported by gateways
+const (
+	IntentRequiresPayment = "requires_payment_method"
+	IntentSucceeded       = "succeeded"
+	IntentCanceled        = "canceled"
+)
+
+// ErrInvalidSignature is returned by ParseWebhook for payloads whose
+// signature is missing, stale or does not match
+var ErrInvalidSignature = errors.New("payments: invalid webhook signature")
+
+// IntentRequest describes a payment to collect. Amount is in the currency's
+// minor unit, e.g. cents.
+type IntentRequest struct {
+	Amount         int64
+	Currency       string
+	Description    string
+	Metadata       map[string]string
+	IdempotencyKey string
+}
+
+// Intent is a payment the client completes with the gateway
+type Intent struct {
+	ID           string
+	ClientSecret string
+	Amount       int64
+	Currency     string
+	Status       string
+}
+
+// Refund returns part or all of a captured payment
+type Refund struct {
+	ID       string
+	IntentID string
+	Amount   int64
+	Status   string
+}
+
+// Event is a verified webhook notification about a payment intent
+type Event struct {
+	ID       string
+	Type     string
+	IntentID string
+	Amount   int64
+	Currency string
+}
+
+// PaymentGateway creates payment intents, refunds them and verifies the
+// webhooks the gateway sends about them
+type PaymentGateway interface {
+	// Name identifies the gateway in stored payments
+	Name() string
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// CancelIntent cancels an intent that has not been paid, so that it can
+	// no longer be confirmed
+	CancelIntent(ctx context.Context, intentID string) error
+	// Refund returns amount, in minor units, of a succeeded intent
+	Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error)
+	// ParseWebhook verifies a webhook request's signature and decodes it
+	ParseWebhook(payload []byte, header http.Header) (*Event, error)
+}
+
+// NewGatewayFromEnv creates the gateway selected by the PAYMENT_GATEWAY
+// environment variable. It returns false when online payments are disabled.
+func NewGatewayFromEnv() (PaymentGateway, bool) {
+	switch strings.ToLower(config.GetEnv("PAYMENT_GATEWAY", "")) {
+	case "stripe":
+		return NewStripeGatewayFromEnv(), true
+	case "fake":
+		return NewFakeGateway(config.GetEnv("PAYMENT_WEBHOOK_SECRET", "")), true
+	default:
+		return nil, false
+	}
+}
+
+// Currency returns the ISO currency code payments are taken in
+func Currency() string {
+	return strings.ToLower(config.GetEnv("PAYMENT_CURRENCY", "usd"))
+}
+
+// ToMinorUnits converts an amount such as 12.50 to minor units such as 1250
+func ToMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinorUnits converts an amount in minor units back to a decimal amount
+func FromMinorUnits(amount int64) float64 {
+	return float64(amount) / 100
+}
@@ -0,0 +1,154 @@
+package payments_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1900000000, 0)
+	header := payments.SignPayload("whsec_test", payload, now)
+
+	assert.NoError(t, payments.VerifySignature("whsec_test", payload, header, now.Add(time.Minute)))
+
+	testCases := map[string]struct {
+		secret  string
+		payload []byte
+		header  string
+		now     time.Time
+	}{
+		"Wrong secret":     {"whsec_other", payload, header, now},
+		"Tampered payload": {"whsec_test", []byte(`{"id":"evt_2"}`), header, now},
+		"Stale timestamp":  {"whsec_test", payload, header, now.Add(payments.SignatureTolerance + time.Second)},
+		"Missing header":   {"whsec_test", payload, "", now},
+		"Malformed header": {"whsec_test", payload, "v1=abc", now},
+		"No secret":        {"", payload, header, now},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := payments.VerifySignature(tc.secret, tc.payload, tc.header, tc.now)
+			assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+		})
+	}
+
+	// During secret rotation either signature is accepted
+	rotated := payments.SignPayload("whsec_old", payload, now) + ",v1=" + header[len(header)-64:]
+	assert.NoError(t, payments.VerifySignature("whsec_test", payload, rotated, now))
+}
+
+func TestMinorUnits(t *testing.T) {
+	assert.Equal(t, int64(1250), payments.ToMinorUnits(12.5))
+	assert.Equal(t, int64(3333), payments.ToMinorUnits(33.33))
+	assert.Equal(t, 19.99, payments.FromMinorUnits(1999))
+}
+
+func TestFakeGateway(t *testing.T) {
+	gateway := payments.NewFakeGateway("whsec_test")
+	ctx := context.Background()
+
+	intent, err := gateway.CreateIntent(ctx, payments.IntentRequest{Amount: 5000, Currency: "usd", IdempotencyKey: "appointment-1"})
+	require.NoError(t, err)
+	again, err := gateway.CreateIntent(ctx, payments.IntentRequest{Amount: 5000, Currency: "usd", IdempotencyKey: "appointment-1"})
+	require.NoError(t, err)
+	assert.Equal(t, intent.ID, again.ID)
+
+	replaced, err := gateway.CreateIntent(ctx, payments.IntentRequest{Amount: 2000, Currency: "usd", IdempotencyKey: "appointment-1-replaces-" + intent.ID})
+	require.NoError(t, err)
+	require.NoError(t, gateway.CancelIntent(ctx, replaced.ID))
+	canceled, ok := gateway.Intent(replaced.ID)
+	require.True(t, ok)
+	assert.Equal(t, payments.IntentCanceled, canceled.Status)
+
+	_, err = gateway.Refund(ctx, intent.ID, 5000, "")
+	assert.Error(t, err, "unpaid intents cannot be refunded")
+
+	payload, header, err := gateway.Succeed(intent.ID)
+	require.NoError(t, err)
+	event, err := gateway.ParseWebhook(payload, header)
+	require.NoError(t, err)
+	assert.Equal(t, payments.EventPaymentSucceeded, event.Type)
+	assert.Equal(t, intent.ID, event.IntentID)
+	assert.Equal(t, int64(5000), event.Amount)
+
+	header.Set(payments.SignatureHeader, "t=1,v1=00")
+	_, err = gateway.ParseWebhook(payload, header)
+	assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+
+	_, err = gateway.Refund(ctx, intent.ID, 3000, "refund-1")
+	require.NoError(t, err)
+	_, err = gateway.Refund(ctx, intent.ID, 3000, "refund-2")
+	assert.Error(t, err, "refunds cannot exceed the amount paid")
+	assert.Len(t, gateway.Refunds(), 1)
+	assert.Error(t, gateway.CancelIntent(ctx, intent.ID), "paid intents cannot be canceled")
+}
+
+func TestStripeGateway(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/v1/payment_intents":
+			amount, _ := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":            "pi_123",
+				"client_secret": "pi_123_secret_abc",
+				"amount":        amount,
+				"currency":      r.PostForm.Get("currency"),
+				"status":        payments.IntentRequiresPayment,
+			})
+		case "/v1/payment_intents/pi_123/cancel":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "pi_123", "status": payments.IntentCanceled})
+		case "/v1/refunds":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"type":"invalid_request_error","message":"Charge has already been refunded."}}`))
+		}
+	}))
+	defer server.Close()
+
+	gateway := &payments.StripeGateway{SecretKey: "sk_test", WebhookSecret: "whsec_test", BaseURL: server.URL, HTTPClient: server.Client()}
+	ctx := context.Background()
+
+	intent, err := gateway.CreateIntent(ctx, payments.IntentRequest{
+		Amount:         4500,
+		Currency:       "eur",
+		Metadata:       map[string]string{"appointment_id": "7"},
+		IdempotencyKey: "appointment-7",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "pi_123_secret_abc", intent.ClientSecret)
+	assert.Equal(t, int64(4500), intent.Amount)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "Bearer sk_test", requests[0].Header.Get("Authorization"))
+	assert.Equal(t, "appointment-7", requests[0].Header.Get("Idempotency-Key"))
+	assert.Equal(t, "4500", requests[0].PostForm.Get("amount"))
+	assert.Equal(t, "7", requests[0].PostForm.Get("metadata[appointment_id]"))
+
+	require.NoError(t, gateway.CancelIntent(ctx, "pi_123"))
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/v1/payment_intents/pi_123/cancel", requests[1].URL.Path)
+
+	_, err = gateway.Refund(ctx, "pi_123", 100, "")
+	assert.ErrorContains(t, err, "already been refunded")
+
+	payload := payments.EncodeEvent("evt_1", payments.EventPaymentFailed, "pi_123", 4500, "eur")
+	header := http.Header{}
+	header.Set(payments.SignatureHeader, payments.SignPayload("whsec_test", payload, time.Now()))
+	event, err := gateway.ParseWebhook(payload, header)
+	require.NoError(t, err)
+	assert.Equal(t, payments.EventPaymentFailed, event.Type)
+	assert.Equal(t, "pi_123", event.IntentID)
+}
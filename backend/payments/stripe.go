@@ -0,0 +1,139 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandipdas/go-doctor-booking/backend/config"
+)
+
+// StripeGateway talks to the Stripe API, or any API compatible with its
+// payment intent and refund endpoints
+type StripeGateway struct {
+	SecretKey     string
+	WebhookSecret string
+	BaseURL       string
+	HTTPClient    *http.Client
+}
+
+// NewStripeGatewayFromEnv creates a StripeGateway from the STRIPE_* environment variables
+func NewStripeGatewayFromEnv() *StripeGateway {
+	return &StripeGateway{
+		SecretKey:     config.GetEnv("STRIPE_SECRET_KEY", ""),
+		WebhookSecret: config.GetEnv("STRIPE_WEBHOOK_SECRET", ""),
+		BaseURL:       config.GetEnv("STRIPE_API_URL", "https://api.stripe.com"),
+		HTTPClient:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// stripeError is the error body returned by the API
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// post sends a form-encoded request and decodes the JSON response into dest
+func (g *StripeGateway) post(ctx context.Context, path string, form url.Values, idempotencyKey string, dest interface{}) error {
+	endpoint := strings.TrimSuffix(g.BaseURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.SecretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := g.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr stripeError
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("stripe: POST %s: status %d: %s", path, resp.StatusCode, apiErr.Error.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+// Name implements PaymentGateway
+func (g *StripeGateway) Name() string {
+	return "stripe"
+}
+
+// CreateIntent implements PaymentGateway
+func (g *StripeGateway) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(req.Amount, 10))
+	form.Set("currency", req.Currency)
+	form.Set("automatic_payment_methods[enabled]", "true")
+	if req.Description != "" {
+		form.Set("description", req.Description)
+	}
+	for key, value := range req.Metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+
+	var body struct {
+		ID           string `json:"id"`
+		ClientSecret string `json:"client_secret"`
+		Amount       int64  `json:"amount"`
+		Currency     string `json:"currency"`
+		Status       string `json:"status"`
+	}
+	if err := g.post(ctx, "/v1/payment_intents", form, req.IdempotencyKey, &body); err != nil {
+		return nil, err
+	}
+	return &Intent{
+		ID:           body.ID,
+		ClientSecret: body.ClientSecret,
+		Amount:       body.Amount,
+		Currency:     body.Currency,
+		Status:       body.Status,
+	}, nil
+}
+
+// CancelIntent implements PaymentGateway
+func (g *StripeGateway) CancelIntent(ctx context.Context, intentID string) error {
+	var body struct {
+		Status string `json:"status"`
+	}
+	return g.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/cancel", url.Values{}, "", &body)
+}
+
+// Refund implements PaymentGateway
+func (g *StripeGateway) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", intentID)
+	form.Set("amount", strconv.FormatInt(amount, 10))
+
+	var body struct {
+		ID            string `json:"id"`
+		PaymentIntent string `json:"payment_intent"`
+		Amount        int64  `json:"amount"`
+		Status        string `json:"status"`
+	}
+	if err := g.post(ctx, "/v1/refunds", form, idempotencyKey, &body); err != nil {
+		return nil, err
+	}
+	return &Refund{ID: body.ID, IntentID: body.PaymentIntent, Amount: body.Amount, Status: body.Status}, nil
+}
+
+// ParseWebhook implements PaymentGateway
+func (g *StripeGateway) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := VerifySignature(g.WebhookSecret, payload, header.Get(SignatureHeader), time.Now()); err != nil {
+		return nil, err
+	}
+	return decodeEvent(payload)
+}
@@ -0,0 +1,119 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+// requestWithAPIKey sends a request authenticated with an API key
+func requestWithAPIKey(r *gin.Engine, method, url, key string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, nil)
+	req.Header.Set("X-API-Key", key)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	_, err := testhelper.CreateTestUser(db, "Admin User", "apikeys@example.com", "password123", models.AdminRole)
+	require.NoError(t, err)
+	adminToken := testhelper.LoginTestUser(t, r, "apikeys@example.com", "password123")
+
+	createKey := func(payload map[string]interface{}) (string, uint) {
+		w := postJSON(r, "/api/v1/admin/api-keys", adminToken, payload)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response struct {
+			Key    string `json:"key"`
+			APIKey struct {
+				ID     uint     `json:"id"`
+				Prefix string   `json:"prefix"`
+				Scopes []string `json:"scopes"`
+			} `json:"api_key"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Contains(t, response.Key, response.APIKey.Prefix)
+		return response.Key, response.APIKey.ID
+	}
+
+	t.Run("Unknown scope is rejected", func(t *testing.T) {
+		w := postJSON(r, "/api/v1/admin/api-keys", adminToken, map[string]interface{}{
+			"name":   "Bad",
+			"scopes": []string{"everything"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Scopes are enforced", func(t *testing.T) {
+		key, id := createKey(map[string]interface{}{
+			"name":   "Lab system",
+			"scopes": []string{models.ScopeAppointmentsRead},
+		})
+
+		w := requestWithAPIKey(r, "GET", "/api/v1/admin/appointments", key)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Missing scope
+		w = requestWithAPIKey(r, "GET", "/api/v1/admin/users", key)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// Routes without scopes stay closed to API keys
+		w = requestWithAPIKey(r, "GET", "/api/v1/admin/security-policy", key)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = requestWithAPIKey(r, "GET", "/api/v1/users/profile", key)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var stored models.APIKey
+		require.NoError(t, db.First(&stored, id).Error)
+		assert.NotNil(t, stored.LastUsedAt)
+		assert.NotEqual(t, key, stored.KeyHash)
+	})
+
+	t.Run("Revoked and expired keys are rejected", func(t *testing.T) {
+		key, id := createKey(map[string]interface{}{
+			"name":   "Kiosk",
+			"scopes": []string{models.ScopeAppointmentsRead},
+		})
+
+		req, _ := http.NewRequest("DELETE", "/api/v1/admin/api-keys/"+strconv.FormatUint(uint64(id), 10), nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = requestWithAPIKey(r, "GET", "/api/v1/admin/appointments", key)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		key, id = createKey(map[string]interface{}{
+			"name":       "Temporary",
+			"scopes":     []string{models.ScopeAppointmentsRead},
+			"expires_at": time.Now().Add(time.Hour),
+		})
+		require.NoError(t, db.Model(&models.APIKey{}).Where("id = ?", id).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		w = requestWithAPIKey(r, "GET", "/api/v1/admin/appointments", key)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = requestWithAPIKey(r, "GET", "/api/v1/admin/appointments", "dbk_unknown_key")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
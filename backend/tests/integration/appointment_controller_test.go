@@ -3,6 +3,7 @@ package integration_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -35,15 +36,16 @@ func setupAppointmentRouter(t *testing.T, db *gorm.DB, doctorUserID uint) *gin.E
 		// Add a route that gets the current doctor's appointments
 		doctorGroup.GET("/me/appointments", v1.GetDoctorAppointments(db))
 		doctorGroup.GET("/:id/appointments", v1.GetDoctorAppointments(db))
-		doctorGroup.PUT("/appointments/:id/status", v1.UpdateAppointmentStatus(db))
+		doctorGroup.PUT("/appointments/:id/status", v1.UpdateAppointmentStatus(db, testhelper.Mailer, testhelper.Payments))
+		doctorGroup.PUT("/appointments/:id/reschedule", v1.RescheduleDoctorAppointment(db))
 	}
 
 	// Patient endpoints
 	patientGroup := authGroup.Group("/patients")
 	{
 		patientGroup.GET("/appointments", v1.GetPatientAppointments(db))
-		patientGroup.POST("/appointments", v1.BookAppointment(db))
-		patientGroup.DELETE("/appointments/:id", v1.CancelAppointment(db))
+		patientGroup.POST("/appointments", v1.BookAppointment(db, testhelper.Payments))
+		patientGroup.DELETE("/appointments/:id", v1.CancelAppointment(db, testhelper.Mailer, testhelper.Payments))
 	}
 
 	// Public endpoints
@@ -185,6 +187,14 @@ func TestBookAppointment(t *testing.T) {
 	r := setupAppointmentRouter(t, db, doctor.UserID)
 	_ = patient // Use the patient variable to avoid unused variable error
 
+	// Publish a schedule for tomorrow
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	day := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, time.UTC)
+	schedule := models.Schedule{DoctorID: doctor.ID, Date: day, StartTime: "09:00", EndTime: "12:00", IsAvailable: true}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+
 	tests := []struct {
 		name       string
 		payload    map[string]interface{}
@@ -194,13 +204,38 @@ func TestBookAppointment(t *testing.T) {
 		{
 			name: "Valid appointment",
 			payload: map[string]interface{}{
-				"doctor_id":    1,
-				"scheduled_at": time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+				"doctor_id":    doctor.ID,
+				"scheduled_at": day.Add(9 * time.Hour).Format(time.RFC3339),
 				"notes":        "Test appointment",
 			},
 			statusCode: http.StatusCreated,
 			contains:   "Test appointment",
 		},
+		{
+			name: "Overlapping appointment",
+			payload: map[string]interface{}{
+				"doctor_id":    doctor.ID,
+				"scheduled_at": day.Add(9*time.Hour + 15*time.Minute).Format(time.RFC3339),
+			},
+			statusCode: http.StatusConflict,
+			contains:   "conflicting_slot",
+		},
+		{
+			name: "Outside published schedule",
+			payload: map[string]interface{}{
+				"doctor_id":    doctor.ID,
+				"scheduled_at": day.Add(14 * time.Hour).Format(time.RFC3339),
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "In the past",
+			payload: map[string]interface{}{
+				"doctor_id":    doctor.ID,
+				"scheduled_at": time.Now().Add(-time.Hour).Format(time.RFC3339),
+			},
+			statusCode: http.StatusBadRequest,
+		},
 		{
 			name: "Missing required fields",
 			payload: map[string]interface{}{
@@ -235,3 +270,189 @@ func TestBookAppointment(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDoctorAvailability(t *testing.T) {
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+
+	patient := createTestPatient(t, db, "patient@example.com")
+	doctor := createTestDoctor(t, db, "doctor@example.com")
+	r := setupAppointmentRouter(t, db, doctor.UserID)
+
+	date := time.Now().AddDate(0, 0, 7).UTC()
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	schedules := []models.Schedule{
+		{DoctorID: doctor.ID, Date: day, StartTime: "09:00", EndTime: "12:00", IsAvailable: true},
+		{DoctorID: doctor.ID, Date: day, StartTime: "10:00", EndTime: "11:00", IsAvailable: false},
+	}
+	for i := range schedules {
+		if err := db.Create(&schedules[i]).Error; err != nil {
+			t.Fatalf("Failed to create schedule: %v", err)
+		}
+	}
+	// IsAvailable=false must survive the column default
+	db.Model(&schedules[1]).Update("is_available", false)
+
+	appointment := models.Appointment{
+		PatientID:       patient.ID,
+		DoctorID:        doctor.ID,
+		AppointmentDate: day.Add(9 * time.Hour),
+		StartTime:       day.Add(9*time.Hour + 15*time.Minute),
+		EndTime:         day.Add(9*time.Hour + 45*time.Minute),
+		Status:          models.StatusPending,
+	}
+	if err := db.Create(&appointment).Error; err != nil {
+		t.Fatalf("Failed to create appointment: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		date     time.Time
+		expected []string
+	}{
+		{
+			name:     "Scheduled day",
+			date:     day,
+			expected: []string{"11:00", "11:30"},
+		},
+		{
+			name:     "Day without schedule",
+			date:     day.AddDate(0, 0, 1),
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := fmt.Sprintf("/api/v1/doctors/%d/availability?date=%s", doctor.ID, tt.date.Format("2006-01-02"))
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response struct {
+				AvailableSlots []string `json:"available_slots"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expected, response.AvailableSlots)
+		})
+	}
+}
+
+func TestUpdateAppointmentStatus(t *testing.T) {
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+
+	patient := createTestPatient(t, db, "patient@example.com")
+	doctor := createTestDoctor(t, db, "doctor@example.com")
+	r := setupAppointmentRouter(t, db, doctor.UserID)
+
+	appointment := models.Appointment{
+		PatientID:       patient.ID,
+		DoctorID:        doctor.ID,
+		AppointmentDate: time.Now().Add(24 * time.Hour),
+		StartTime:       time.Now().Add(24 * time.Hour),
+		EndTime:         time.Now().Add(25 * time.Hour),
+		Status:          models.StatusPending,
+	}
+	if err := db.Create(&appointment).Error; err != nil {
+		t.Fatalf("Failed to create appointment: %v", err)
+	}
+
+	// Each step runs against the state left by the previous one
+	steps := []struct {
+		name       string
+		status     string
+		statusCode int
+	}{
+		{name: "Pending to completed is rejected", status: models.StatusCompleted, statusCode: http.StatusConflict},
+		{name: "Pending to confirmed", status: models.StatusConfirmed, statusCode: http.StatusOK},
+		{name: "Confirmed to completed", status: models.StatusCompleted, statusCode: http.StatusOK},
+		{name: "Completed to confirmed is rejected", status: models.StatusConfirmed, statusCode: http.StatusConflict},
+		{name: "Unknown status", status: "archived", statusCode: http.StatusBadRequest},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			jsonData, _ := json.Marshal(map[string]string{"status": step.status})
+			url := fmt.Sprintf("/api/v1/doctors/appointments/%d/status", appointment.ID)
+			req, _ := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, step.statusCode, w.Code)
+		})
+	}
+
+	var changes []models.AppointmentStatusChange
+	db.Where("appointment_id = ?", appointment.ID).Order("id").Find(&changes)
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, models.StatusConfirmed, changes[0].ToStatus)
+		assert.Equal(t, models.StatusCompleted, changes[1].ToStatus)
+		assert.Equal(t, doctor.UserID, changes[1].ChangedByID)
+	}
+}
+
+func TestRescheduleAppointment(t *testing.T) {
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	t.Setenv("RESCHEDULE_MIN_NOTICE", "1h")
+
+	patient := createTestPatient(t, db, "patient@example.com")
+	doctor := createTestDoctor(t, db, "doctor@example.com")
+	r := setupAppointmentRouter(t, db, doctor.UserID)
+
+	next := time.Now().UTC().AddDate(0, 0, 2)
+	day := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, time.UTC)
+	schedule := models.Schedule{DoctorID: doctor.ID, Date: day, StartTime: "09:00", EndTime: "12:00", IsAvailable: true}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+
+	appointments := []models.Appointment{
+		{PatientID: patient.ID, DoctorID: doctor.ID, AppointmentDate: day.Add(9 * time.Hour), StartTime: day.Add(9 * time.Hour), EndTime: day.Add(9*time.Hour + 30*time.Minute), Status: models.StatusPending},
+		{PatientID: patient.ID, DoctorID: doctor.ID, AppointmentDate: day.Add(11 * time.Hour), StartTime: day.Add(11 * time.Hour), EndTime: day.Add(11*time.Hour + 30*time.Minute), Status: models.StatusConfirmed},
+	}
+	for i := range appointments {
+		if err := db.Create(&appointments[i]).Error; err != nil {
+			t.Fatalf("Failed to create appointment: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		scheduledAt time.Time
+		statusCode  int
+	}{
+		{name: "Overlaps another appointment", scheduledAt: day.Add(11*time.Hour + 15*time.Minute), statusCode: http.StatusConflict},
+		{name: "Outside published schedule", scheduledAt: day.Add(15 * time.Hour), statusCode: http.StatusBadRequest},
+		{name: "Within notice period", scheduledAt: time.Now().Add(30 * time.Minute), statusCode: http.StatusBadRequest},
+		{name: "Free slot", scheduledAt: day.Add(10 * time.Hour), statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonData, _ := json.Marshal(map[string]interface{}{"scheduled_at": tt.scheduledAt.Format(time.RFC3339)})
+			url := fmt.Sprintf("/api/v1/doctors/appointments/%d/reschedule", appointments[0].ID)
+			req, _ := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+
+	var moved models.Appointment
+	db.Preload("Reschedules").First(&moved, appointments[0].ID)
+	assert.Equal(t, models.StatusRescheduled, moved.Status)
+	assert.True(t, moved.StartTime.Equal(day.Add(10*time.Hour)))
+	if assert.Len(t, moved.Reschedules, 1) {
+		assert.True(t, moved.Reschedules[0].PreviousStartTime.Equal(day.Add(9*time.Hour)))
+	}
+}
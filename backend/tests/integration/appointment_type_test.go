@@ -0,0 +1,116 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestAppointmentTypes(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	doctor := createTestDoctor(t, db, "types-doctor@example.com")
+	require.NoError(t, db.Model(doctor).Update("consultation_fee", 50).Error)
+	createTestPatient(t, db, "types-patient@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "types-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "types-patient@example.com", "password123")
+
+	date := time.Now().UTC().AddDate(0, 0, 3).Format("2006-01-02")
+	w := sendJSON(r, "POST", "/api/v1/doctors/schedules", doctorToken, map[string]interface{}{
+		"date":       date,
+		"start_time": "09:00",
+		"end_time":   "11:00",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	createType := func(payload map[string]interface{}) models.AppointmentType {
+		w := sendJSON(r, "POST", "/api/v1/doctors/appointment-types", doctorToken, payload)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			AppointmentType models.AppointmentType `json:"appointment_type"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.AppointmentType
+	}
+	procedure := createType(map[string]interface{}{"name": "Procedure", "duration": 60, "buffer_minutes": 15, "fee": 200})
+	createType(map[string]interface{}{"name": "Follow-up", "duration": 15})
+
+	w = sendJSON(r, "POST", "/api/v1/doctors/appointment-types", doctorToken, map[string]interface{}{"name": "Too short", "duration": 1})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	t.Run("Patients see the fee charged for each type", func(t *testing.T) {
+		w := sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/doctors/%d/appointment-types", doctor.ID), patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data []models.AppointmentType `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 2)
+		assert.Equal(t, "Follow-up", response.Data[0].Name)
+		assert.Equal(t, 50.0, *response.Data[0].Fee)
+		assert.Equal(t, 200.0, *response.Data[1].Fee)
+	})
+
+	availability := func(typeID uint) []string {
+		url := fmt.Sprintf("/api/v1/patients/doctors/%d/availability?date=%s", doctor.ID, date)
+		if typeID != 0 {
+			url += fmt.Sprintf("&appointment_type_id=%d", typeID)
+		}
+		w := sendJSON(r, "GET", url, patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			AvailableSlots []string `json:"available_slots"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.AvailableSlots
+	}
+
+	t.Run("Availability per type", func(t *testing.T) {
+		assert.Equal(t, []string{"09:00", "09:30", "10:00"}, availability(procedure.ID))
+		assert.Len(t, availability(0), 4)
+
+		w := sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/doctors/%d/availability?date=%s&appointment_type_id=999999", doctor.ID, date), patientToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Booking uses the type's length, buffer and fee", func(t *testing.T) {
+		start, err := time.Parse("2006-01-02 15:04", date+" 09:00")
+		require.NoError(t, err)
+
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+			"doctor_id":           doctor.ID,
+			"scheduled_at":        start.Format(time.RFC3339),
+			"appointment_type_id": procedure.ID,
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var appointment models.Appointment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &appointment))
+		assert.True(t, appointment.EndTime.Equal(start.Add(time.Hour)))
+		assert.Equal(t, 15, appointment.BufferMinutes)
+		assert.Equal(t, 200.0, appointment.Fee)
+
+		// The buffer keeps 10:00-10:15 free, so only 10:30 is left
+		assert.Equal(t, []string{"10:30"}, availability(0))
+		assert.Empty(t, availability(procedure.ID))
+
+		w = sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": start.Add(time.Hour).Format(time.RFC3339),
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
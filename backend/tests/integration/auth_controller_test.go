@@ -6,13 +6,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sandipdas/go-doctor-booking/backend/api/v1"
 	"github.com/sandipdas/go-doctor-booking/backend/middleware"
 	"github.com/sandipdas/go-doctor-booking/backend/models"
 	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+	"github.com/sandipdas/go-doctor-booking/backend/totp"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -26,12 +29,17 @@ func setupAuthRouter(db *gorm.DB) *gin.Engine {
 	{
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", v1.RegisterUser(db))
+			auth.POST("/register", v1.RegisterUser(db, testhelper.Mailer))
 			auth.POST("/login", v1.LoginUser(db))
+			auth.POST("/refresh", v1.RefreshToken(db))
+			auth.POST("/forgot-password", v1.ForgotPassword(db, testhelper.Mailer))
+			auth.POST("/reset-password", v1.ResetPassword(db))
+			auth.GET("/verify-email", v1.VerifyEmail(db))
+			auth.POST("/logout", middleware.AuthMiddleware(db), v1.LogoutUser(db))
 		}
 
 		// Protected route for testing
-		api.GET("/protected", middleware.AuthMiddleware(), func(c *gin.Context) {
+		api.GET("/protected", middleware.AuthMiddleware(db), func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"message": "protected"})
 		})
 	}
@@ -312,3 +320,265 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func postJSON(r *gin.Engine, url, token string, payload interface{}) *httptest.ResponseRecorder {
+	jsonData, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func getProtected(r *gin.Engine, token string) int {
+	req, _ := http.NewRequest("GET", "/api/v1/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := setupAuthRouter(db)
+
+	if _, err := testhelper.CreateTestUser(db, "Refresh User", "refresh@example.com", "password123", models.PatientRole); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	var login v1.AuthResponse
+	w := postJSON(r, "/api/v1/auth/login", "", map[string]string{"email": "refresh@example.com", "password": "password123"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	assert.NotEmpty(t, login.RefreshToken)
+
+	// Rotating returns a new pair
+	var refreshed v1.AuthResponse
+	w = postJSON(r, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": login.RefreshToken})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshed))
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+	assert.Equal(t, http.StatusOK, getProtected(r, refreshed.Token))
+
+	// Reusing a rotated token revokes the whole family
+	w = postJSON(r, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": login.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = postJSON(r, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": refreshed.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, getProtected(r, refreshed.Token))
+
+	// Logout revokes the presented access token
+	w = postJSON(r, "/api/v1/auth/login", "", map[string]string{"email": "refresh@example.com", "password": "password123"})
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	assert.Equal(t, http.StatusOK, getProtected(r, login.Token))
+
+	w = postJSON(r, "/api/v1/auth/logout", login.Token, map[string]string{"refresh_token": login.RefreshToken})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, getProtected(r, login.Token))
+
+	w = postJSON(r, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": login.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// tokenFromMail extracts the token query parameter from the last email sent to the address
+func tokenFromMail(t *testing.T, to string) string {
+	msg, ok := testhelper.Mailer.Last(to)
+	if !ok {
+		t.Fatalf("No email sent to %s", to)
+	}
+	idx := strings.Index(msg.Body, "token=")
+	if idx < 0 {
+		t.Fatalf("No token in email body: %s", msg.Body)
+	}
+	token := msg.Body[idx+len("token="):]
+	if end := strings.IndexAny(token, " \n"); end >= 0 {
+		token = token[:end]
+	}
+	return token
+}
+
+func TestPasswordReset(t *testing.T) {
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := setupAuthRouter(db)
+	testhelper.Mailer.Reset()
+
+	if _, err := testhelper.CreateTestUser(db, "Reset User", "reset@example.com", "oldpassword", models.PatientRole); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	// Unknown emails get the same response and no email
+	w := postJSON(r, "/api/v1/auth/forgot-password", "", map[string]string{"email": "unknown@example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, testhelper.Mailer.Messages())
+
+	w = postJSON(r, "/api/v1/auth/forgot-password", "", map[string]string{"email": "reset@example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	token := tokenFromMail(t, "reset@example.com")
+
+	w = postJSON(r, "/api/v1/auth/reset-password", "", map[string]string{"token": token, "password": "newpassword"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Tokens are single-use
+	w = postJSON(r, "/api/v1/auth/reset-password", "", map[string]string{"token": token, "password": "otherpassword"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(r, "/api/v1/auth/login", "", map[string]string{"email": "reset@example.com", "password": "oldpassword"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = postJSON(r, "/api/v1/auth/login", "", map[string]string{"email": "reset@example.com", "password": "newpassword"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := setupAuthRouter(db)
+	testhelper.Mailer.Reset()
+
+	w := postJSON(r, "/api/v1/auth/register", "", map[string]string{
+		"name":     "Verify User",
+		"email":    "verify@example.com",
+		"password": "password123",
+		"role":     "patient",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	token := tokenFromMail(t, "verify@example.com")
+
+	req, _ := http.NewRequest("GET", "/api/v1/auth/verify-email?token="+token, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var user models.User
+	db.Where("email = ?", "verify@example.com").First(&user)
+	assert.True(t, user.EmailVerified)
+	assert.NotNil(t, user.EmailVerifiedAt)
+
+	req, _ = http.NewRequest("GET", "/api/v1/auth/verify-email?token="+token, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ACCOUNT_FAILURES", "3")
+	t.Setenv("LOGIN_MAX_IP_FAILURES", "100")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := setupAuthRouter(db)
+
+	if _, err := testhelper.CreateTestUser(db, "Locked User", "locked@example.com", "password123", models.PatientRole); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		w := postJSON(r, "/api/v1/auth/login", "", map[string]string{"email": "locked@example.com", "password": "wrongpassword"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// Even the correct password is rejected while locked
+	w := postJSON(r, "/api/v1/auth/login", "", map[string]string{"email": "locked@example.com", "password": "password123"})
+	assert.Equal(t, http.StatusLocked, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	var throttle models.LoginThrottle
+	assert.NoError(t, db.Where("key = ?", "account:locked@example.com").First(&throttle).Error)
+	assert.Equal(t, 3, throttle.Failures)
+	assert.NotNil(t, throttle.LockedUntil)
+
+	// Clearing the lock, as the admin unlock endpoint does, allows login again
+	db.Model(&throttle).Updates(map[string]interface{}{"failures": 0, "locked_until": nil})
+	w = postJSON(r, "/api/v1/auth/login", "", map[string]string{"email": "locked@example.com", "password": "password123"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	if _, err := testhelper.CreateTestUser(db, "Admin User", "admin2fa@example.com", "password123", models.AdminRole); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	credentials := map[string]string{"email": "admin2fa@example.com", "password": "password123"}
+
+	// Require 2FA for admins; the next login only yields an enrollment token
+	adminToken := testhelper.LoginTestUser(t, r, "admin2fa@example.com", "password123")
+	req, _ := http.NewRequest("PUT", "/api/v1/admin/security-policy", strings.NewReader(`{"require_2fa_roles":["admin"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var enrollment struct {
+		EnrollmentToken string `json:"enrollment_token"`
+	}
+	w = postJSON(r, "/api/v1/auth/login", "", credentials)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrollment))
+
+	// Enrollment tokens cannot be used as access tokens
+	assert.Equal(t, http.StatusUnauthorized, getProfile(r, enrollment.EnrollmentToken))
+
+	var setup struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+	w = postJSON(r, "/api/v1/auth/2fa/setup", enrollment.EnrollmentToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &setup))
+	assert.Contains(t, setup.OtpauthURI, "otpauth://totp/")
+
+	code, _ := totp.CodeAt(setup.Secret, totp.Step(time.Now()))
+	var enabled struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	w = postJSON(r, "/api/v1/auth/2fa/enable", enrollment.EnrollmentToken, map[string]string{"code": code})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &enabled))
+	assert.Len(t, enabled.RecoveryCodes, 10)
+
+	var challenge struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+	w = postJSON(r, "/api/v1/auth/login", "", credentials)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &challenge))
+	assert.True(t, challenge.TwoFactorRequired)
+
+	// The code already used for enrollment cannot be replayed
+	w = postJSON(r, "/api/v1/auth/2fa/verify", "", map[string]string{"challenge_token": challenge.ChallengeToken, "code": code})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var resp v1.AuthResponse
+	w = postJSON(r, "/api/v1/auth/2fa/verify", "", map[string]string{"challenge_token": challenge.ChallengeToken, "recovery_code": enabled.RecoveryCodes[0]})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusOK, getProfile(r, resp.Token))
+
+	// Recovery codes are single-use
+	w = postJSON(r, "/api/v1/auth/2fa/verify", "", map[string]string{"challenge_token": challenge.ChallengeToken, "recovery_code": enabled.RecoveryCodes[0]})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func getProfile(r *gin.Engine, token string) int {
+	req, _ := http.NewRequest("GET", "/api/v1/users/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
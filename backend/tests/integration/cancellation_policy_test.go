@@ -0,0 +1,171 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestCancellationPolicy(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	doctor := createTestDoctor(t, db, "policy-doctor@example.com")
+	patient := createTestPatient(t, db, "policy-patient@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "policy-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "policy-patient@example.com", "password123")
+
+	w := sendJSON(r, "PUT", "/api/v1/doctors/cancellation-policy", doctorToken, map[string]interface{}{
+		"min_notice_hours":  24,
+		"late_cancel_fee":   20,
+		"max_cancellations": 2,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/doctors/%d/cancellation-policy", doctor.ID), patientToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var policy models.CancellationPolicy
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &policy))
+	assert.Equal(t, 24, policy.MinNoticeHours)
+	assert.Equal(t, 30, policy.PeriodDays)
+
+	book := func(in time.Duration, status string) models.Appointment {
+		start := time.Now().Add(in).Truncate(time.Minute)
+		appointment := models.Appointment{
+			PatientID:       patient.ID,
+			DoctorID:        doctor.ID,
+			AppointmentDate: start,
+			StartTime:       start,
+			EndTime:         start.Add(30 * time.Minute),
+			Status:          status,
+		}
+		require.NoError(t, db.Create(&appointment).Error)
+		return appointment
+	}
+	cancel := func(appointment models.Appointment, reason string) (int, map[string]interface{}) {
+		w := sendJSON(r, "PUT", fmt.Sprintf("/api/v1/patients/appointments/%d/cancel", appointment.ID), patientToken,
+			map[string]interface{}{"reason": reason})
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("On time and late cancellations", func(t *testing.T) {
+		onTime := book(72*time.Hour, models.StatusConfirmed)
+		code, response := cancel(onTime, "Feeling better")
+		require.Equal(t, http.StatusOK, code, response)
+		assert.Equal(t, 0.0, response["late_cancellation_fee"])
+
+		var stored models.Appointment
+		require.NoError(t, db.First(&stored, onTime.ID).Error)
+		assert.Equal(t, "Feeling better", stored.CancellationReason)
+		require.NotNil(t, stored.CancelledByID)
+		assert.Equal(t, patient.ID, *stored.CancelledByID)
+		assert.NotNil(t, stored.CancelledAt)
+
+		late := book(2*time.Hour, models.StatusConfirmed)
+		code, response = cancel(late, "Stuck at work")
+		require.Equal(t, http.StatusOK, code, response)
+		assert.Equal(t, 20.0, response["late_cancellation_fee"])
+	})
+
+	t.Run("Cancellation limit", func(t *testing.T) {
+		code, _ := cancel(book(96*time.Hour, models.StatusConfirmed), "")
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("Completed appointments cannot be cancelled", func(t *testing.T) {
+		code, _ := cancel(book(-48*time.Hour, models.StatusCompleted), "")
+		assert.Equal(t, http.StatusConflict, code)
+	})
+
+	t.Run("Late cancellations can be refused", func(t *testing.T) {
+		w := sendJSON(r, "PUT", "/api/v1/doctors/cancellation-policy", doctorToken, map[string]interface{}{
+			"min_notice_hours":         24,
+			"block_late_cancellations": true,
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		late := book(3*time.Hour, models.StatusConfirmed)
+		code, _ := cancel(late, "")
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		var stored models.Appointment
+		require.NoError(t, db.First(&stored, late.ID).Error)
+		assert.Equal(t, models.StatusConfirmed, stored.Status)
+	})
+}
+
+func TestNoShows(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+	t.Setenv("NO_SHOW_LIMIT", "2")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	doctor := createTestDoctor(t, db, "noshow-doctor@example.com")
+	patient := createTestPatient(t, db, "noshow-patient@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "noshow-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "noshow-patient@example.com", "password123")
+
+	book := func(in time.Duration) models.Appointment {
+		start := time.Now().Add(in).Truncate(time.Minute)
+		appointment := models.Appointment{
+			PatientID:       patient.ID,
+			DoctorID:        doctor.ID,
+			AppointmentDate: start,
+			StartTime:       start,
+			EndTime:         start.Add(30 * time.Minute),
+			Status:          models.StatusConfirmed,
+		}
+		require.NoError(t, db.Create(&appointment).Error)
+		return appointment
+	}
+	markNoShow := func(appointment models.Appointment) (int, map[string]interface{}) {
+		w := sendJSON(r, "PUT", fmt.Sprintf("/api/v1/doctors/appointments/%d/no-show", appointment.ID), doctorToken, nil)
+		var response map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	code, _ := markNoShow(book(2 * time.Hour))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, response := markNoShow(book(-48 * time.Hour))
+	require.Equal(t, http.StatusOK, code, response)
+	assert.Equal(t, false, response["booking_restricted"])
+
+	code, response = markNoShow(book(-24 * time.Hour))
+	require.Equal(t, http.StatusOK, code, response)
+	assert.Equal(t, true, response["booking_restricted"])
+
+	// The generic status endpoint applies the same rules
+	w := sendJSON(r, "PUT", fmt.Sprintf("/api/v1/doctors/appointments/%d/status", book(2*time.Hour).ID), doctorToken, map[string]interface{}{
+		"status": models.StatusNoShow,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	w = sendJSON(r, "PUT", fmt.Sprintf("/api/v1/doctors/appointments/%d/status", book(-12*time.Hour).ID), doctorToken, map[string]interface{}{
+		"status": models.StatusNoShow,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"booking_restricted":true`)
+
+	w = sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+		"doctor_id":    doctor.ID,
+		"scheduled_at": time.Now().Add(72 * time.Hour).Format(time.RFC3339),
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "restricted_until")
+}
@@ -310,6 +310,16 @@ func TestCreateSchedule(t *testing.T) {
 			expectedStatus: http.StatusCreated,
 			shouldCreate:   true,
 		},
+		{
+			name: "Overlapping schedule",
+			payload: map[string]interface{}{
+				"date":       time.Now().Add(24 * time.Hour).Format("2006-01-02"),
+				"start_time": "16:00",
+				"end_time":   "18:00",
+			},
+			expectedStatus: http.StatusConflict,
+			shouldCreate:   false,
+		},
 		{
 			name: "Invalid date format",
 			payload: map[string]interface{}{
@@ -0,0 +1,129 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestFollowUps(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+	testhelper.Mailer.Reset()
+
+	doctor := createTestDoctor(t, db, "followup-doctor@example.com")
+	require.NoError(t, db.Model(doctor).Update("consultation_fee", 50).Error)
+	patient := createTestPatient(t, db, "followup-patient@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "followup-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "followup-patient@example.com", "password123")
+
+	today := time.Now().UTC()
+	day := func(offset int) string { return today.AddDate(0, 0, offset).Format("2006-01-02") }
+	for _, offset := range []int{3, 6} {
+		w := sendJSON(r, "POST", "/api/v1/doctors/schedules", doctorToken, map[string]interface{}{
+			"date":       day(offset),
+			"start_time": "09:00",
+			"end_time":   "10:00",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	visit := func(status string) models.Appointment {
+		start := today.AddDate(0, 0, -1).Truncate(time.Hour)
+		appointment := models.Appointment{
+			PatientID:       patient.ID,
+			DoctorID:        doctor.ID,
+			AppointmentDate: start,
+			StartTime:       start,
+			EndTime:         start.Add(30 * time.Minute),
+			Status:          status,
+		}
+		require.NoError(t, db.Create(&appointment).Error)
+		return appointment
+	}
+	completed := visit(models.StatusCompleted)
+	confirmed := visit(models.StatusConfirmed)
+
+	recommend := func(appointmentID uint) *httptest.ResponseRecorder {
+		return sendJSON(r, "POST", fmt.Sprintf("/api/v1/doctors/appointments/%d/follow-up", appointmentID), doctorToken, map[string]interface{}{
+			"from_date":        day(2),
+			"to_date":          day(4),
+			"discount_percent": 50,
+			"notes":            "Review blood test results",
+		})
+	}
+
+	t.Run("Only completed appointments", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, recommend(confirmed.ID).Code)
+	})
+
+	var followUp models.FollowUp
+	t.Run("Recommend a follow-up", func(t *testing.T) {
+		w := recommend(completed.ID)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response struct {
+			FollowUp models.FollowUp `json:"follow_up"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		followUp = response.FollowUp
+		assert.Equal(t, models.FollowUpPending, followUp.Status)
+
+		msg, ok := testhelper.Mailer.Last("followup-patient@example.com")
+		require.True(t, ok)
+		assert.Contains(t, msg.Body, "Review blood test results")
+
+		assert.Equal(t, http.StatusConflict, recommend(completed.ID).Code)
+	})
+
+	listPending := func() []models.FollowUp {
+		w := sendJSON(r, "GET", "/api/v1/patients/follow-ups", patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var followUps []models.FollowUp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &followUps))
+		return followUps
+	}
+
+	t.Run("Patient books within the window", func(t *testing.T) {
+		require.Len(t, listPending(), 1)
+
+		book := func(date string) (int, []byte) {
+			w := sendJSON(r, "POST", fmt.Sprintf("/api/v1/patients/follow-ups/%d/book", followUp.ID), patientToken, map[string]interface{}{
+				"scheduled_at": date + "T09:00:00Z",
+			})
+			return w.Code, w.Body.Bytes()
+		}
+
+		code, _ := book(day(6))
+		assert.Equal(t, http.StatusBadRequest, code)
+		var count int64
+		db.Model(&models.Appointment{}).Where("parent_appointment_id = ?", completed.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+
+		code, body := book(day(3))
+		require.Equal(t, http.StatusCreated, code, string(body))
+		var appointment models.Appointment
+		require.NoError(t, json.Unmarshal(body, &appointment))
+		assert.True(t, appointment.IsFollowUp)
+		require.NotNil(t, appointment.ParentAppointmentID)
+		assert.Equal(t, completed.ID, *appointment.ParentAppointmentID)
+		assert.Equal(t, 25.0, appointment.Fee)
+		assert.Equal(t, "Review blood test results", appointment.FollowUpNotes)
+
+		assert.Empty(t, listPending())
+		code, _ = book(day(3))
+		assert.Equal(t, http.StatusConflict, code)
+	})
+}
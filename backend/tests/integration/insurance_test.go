@@ -0,0 +1,245 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestInsuranceAndClaims(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+	testhelper.Payments.Reset()
+
+	doctor := createTestDoctor(t, db, "insurance-doctor@example.com")
+	require.NoError(t, db.Model(doctor).Update("consultation_fee", 100).Error)
+	createTestPatient(t, db, "insurance-patient@example.com")
+	_, err := testhelper.CreateTestUser(db, "Admin User", "insurance-admin@example.com", "password123", models.AdminRole)
+	require.NoError(t, err)
+	doctorToken := testhelper.LoginTestUser(t, r, "insurance-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "insurance-patient@example.com", "password123")
+	adminToken := testhelper.LoginTestUser(t, r, "insurance-admin@example.com", "password123")
+
+	today := time.Now().UTC()
+	day := func(offset int) string { return today.AddDate(0, 0, offset).Format("2006-01-02") }
+	w := sendJSON(r, "POST", "/api/v1/doctors/schedules", doctorToken, map[string]interface{}{
+		"date":       day(3),
+		"start_time": "09:00",
+		"end_time":   "10:00",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	policyRequest := map[string]interface{}{
+		"payer":               "Acme Health",
+		"member_id":           "ACM-123",
+		"coverage_start":      day(-1),
+		"coverage_end":        day(30),
+		"copay_amount":        15,
+		"coinsurance_percent": 10,
+	}
+	addPolicy := func() models.InsurancePolicy {
+		w := sendJSON(r, "POST", "/api/v1/patients/insurance", patientToken, policyRequest)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Policy models.InsurancePolicy `json:"policy"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Policy
+	}
+
+	t.Run("Coverage dates are validated", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/patients/insurance", patientToken, map[string]interface{}{
+			"payer":          "Acme Health",
+			"member_id":      "ACM-123",
+			"coverage_start": day(10),
+			"coverage_end":   day(5),
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	policy := addPolicy()
+
+	var appointment models.Appointment
+	var bookedIntent string
+	t.Run("Booking checks eligibility and charges the co-pay", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": day(3) + "T09:00:00Z",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var booked struct {
+			models.Appointment
+			Payment *struct {
+				IntentID string  `json:"intent_id"`
+				Amount   float64 `json:"amount"`
+			} `json:"payment"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &booked))
+		appointment = booked.Appointment
+
+		assert.Equal(t, models.EligibilityEligible, appointment.EligibilityStatus)
+		require.NotNil(t, appointment.InsurancePolicyID)
+		assert.Equal(t, policy.ID, *appointment.InsurancePolicyID)
+		// 15 co-pay plus 10% of the remaining 85
+		assert.Equal(t, 23.5, appointment.CopayAmount)
+		require.NotNil(t, booked.Payment)
+		assert.Equal(t, 23.5, booked.Payment.Amount)
+		bookedIntent = booked.Payment.IntentID
+	})
+
+	checkEligibility := func() map[string]interface{} {
+		w := sendJSON(r, "POST", fmt.Sprintf("/api/v1/patients/appointments/%d/eligibility", appointment.ID), patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("Eligibility follows the patient's policies", func(t *testing.T) {
+		w := sendJSON(r, "DELETE", fmt.Sprintf("/api/v1/patients/insurance/%d", policy.ID), patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		response := checkEligibility()
+		assert.Equal(t, models.EligibilitySelfPay, response["eligibility_status"])
+		assert.Equal(t, 100.0, response["patient_share"])
+
+		// The unpaid intent is replaced with one for the new share
+		var payment models.Payment
+		require.NoError(t, db.Where("appointment_id = ?", appointment.ID).First(&payment).Error)
+		assert.Equal(t, 100.0, payment.Amount)
+		assert.Equal(t, models.PaymentPending, payment.Status)
+		assert.NotEqual(t, bookedIntent, payment.IntentID)
+		old, ok := testhelper.Payments.Intent(bookedIntent)
+		require.True(t, ok)
+		assert.Equal(t, payments.IntentCanceled, old.Status)
+		w = sendJSON(r, "POST", fmt.Sprintf("/api/v1/patients/appointments/%d/pay", appointment.ID), patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var pay map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pay))
+		assert.Equal(t, payment.IntentID, pay["intent_id"])
+		assert.Equal(t, 100.0, pay["amount"])
+
+		policyRequest["coverage_end"] = day(1)
+		expired := addPolicy()
+		response = checkEligibility()
+		assert.Equal(t, models.EligibilityIneligible, response["eligibility_status"])
+
+		policyRequest["coverage_end"] = day(30)
+		w = sendJSON(r, "PUT", fmt.Sprintf("/api/v1/patients/insurance/%d", expired.ID), patientToken, policyRequest)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		response = checkEligibility()
+		assert.Equal(t, models.EligibilityEligible, response["eligibility_status"])
+		assert.Equal(t, 76.5, response["insured_amount"])
+
+		var covered models.Payment
+		require.NoError(t, db.Where("appointment_id = ?", appointment.ID).First(&covered).Error)
+		assert.Equal(t, 23.5, covered.Amount)
+	})
+
+	claimRequest := func() *httptest.ResponseRecorder {
+		return sendJSON(r, "POST", "/api/v1/admin/claims", adminToken, map[string]interface{}{
+			"appointment_id":  appointment.ID,
+			"payer_reference": "ACME-CLM-1",
+		})
+	}
+
+	t.Run("Claims need a completed appointment", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, claimRequest().Code)
+	})
+
+	var claim models.Claim
+	t.Run("Admins submit claims", func(t *testing.T) {
+		require.NoError(t, db.Model(&models.Appointment{}).Where("id = ?", appointment.ID).
+			Update("status", models.StatusCompleted).Error)
+
+		w := sendJSON(r, "POST", "/api/v1/admin/claims", patientToken, map[string]interface{}{"appointment_id": appointment.ID})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = claimRequest()
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Claim models.Claim `json:"claim"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		claim = response.Claim
+		assert.Equal(t, models.ClaimSubmitted, claim.Status)
+		assert.Equal(t, 76.5, claim.BilledAmount)
+		require.NotNil(t, claim.Policy)
+		assert.Equal(t, "Acme Health", claim.Policy.Payer)
+
+		assert.Equal(t, http.StatusConflict, claimRequest().Code)
+	})
+
+	updateStatus := func(payload map[string]interface{}) (int, models.Claim) {
+		w := sendJSON(r, "PUT", fmt.Sprintf("/api/v1/admin/claims/%d/status", claim.ID), adminToken, payload)
+		var response struct {
+			Claim models.Claim `json:"claim"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Claim
+	}
+
+	t.Run("Claim status transitions", func(t *testing.T) {
+		code, _ := updateStatus(map[string]interface{}{"status": models.ClaimPaid})
+		assert.Equal(t, http.StatusConflict, code)
+		code, _ = updateStatus(map[string]interface{}{"status": models.ClaimDenied})
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, updated := updateStatus(map[string]interface{}{"status": models.ClaimDenied, "reason": "Missing referral"})
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Missing referral", updated.DenialReason)
+		assert.NotNil(t, updated.DecidedAt)
+
+		code, updated = updateStatus(map[string]interface{}{"status": models.ClaimSubmitted, "reason": "Referral attached"})
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, updated.DenialReason)
+
+		code, _ = updateStatus(map[string]interface{}{"status": models.ClaimApproved, "approved_amount": 90})
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, updated = updateStatus(map[string]interface{}{"status": models.ClaimApproved, "approved_amount": 70})
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 70.0, updated.ApprovedAmount)
+
+		code, updated = updateStatus(map[string]interface{}{"status": models.ClaimPaid})
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, models.ClaimPaid, updated.Status)
+		assert.Equal(t, 70.0, updated.PaidAmount)
+		assert.NotNil(t, updated.PaidAt)
+
+		require.Len(t, updated.History, 5)
+		assert.Equal(t, "", updated.History[0].FromStatus)
+		assert.Equal(t, models.ClaimDenied, updated.History[1].ToStatus)
+		assert.Equal(t, models.ClaimPaid, updated.History[4].ToStatus)
+
+		code, _ = updateStatus(map[string]interface{}{"status": models.ClaimSubmitted})
+		assert.Equal(t, http.StatusConflict, code)
+	})
+
+	t.Run("Claimed appointments keep their eligibility", func(t *testing.T) {
+		w := sendJSON(r, "POST", fmt.Sprintf("/api/v1/admin/appointments/%d/eligibility", appointment.ID), adminToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Patients see their claims", func(t *testing.T) {
+		w := sendJSON(r, "GET", "/api/v1/patients/claims", patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data []models.Claim `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 1)
+		assert.Equal(t, models.ClaimPaid, response.Data[0].Status)
+	})
+}
@@ -0,0 +1,176 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestInvoices(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+	t.Setenv("TAX_RATE", "20")
+	t.Setenv("TAX_LABEL", "VAT")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	doctor := createTestDoctor(t, db, "invoice-doctor@example.com")
+	patient := createTestPatient(t, db, "invoice-patient@example.com")
+	createTestPatient(t, db, "invoice-other@example.com")
+	_, err := testhelper.CreateTestUser(db, "Admin User", "invoice-admin@example.com", "password123", models.AdminRole)
+	require.NoError(t, err)
+	patientToken := testhelper.LoginTestUser(t, r, "invoice-patient@example.com", "password123")
+	otherToken := testhelper.LoginTestUser(t, r, "invoice-other@example.com", "password123")
+	adminToken := testhelper.LoginTestUser(t, r, "invoice-admin@example.com", "password123")
+
+	book := func(days int, paid bool) models.Appointment {
+		start := time.Now().AddDate(0, 0, days).Truncate(time.Hour)
+		appointment := models.Appointment{
+			PatientID:       patient.ID,
+			DoctorID:        doctor.ID,
+			AppointmentDate: start,
+			StartTime:       start,
+			EndTime:         start.Add(30 * time.Minute),
+			Status:          models.StatusConfirmed,
+			Fee:             50,
+		}
+		if paid {
+			appointment.IsPaid = true
+			appointment.PaymentAmount = 50
+			appointment.PaymentReference = fmt.Sprintf("pi_invoice_%d", days)
+		}
+		require.NoError(t, db.Create(&appointment).Error)
+		return appointment
+	}
+	number := func(kind string, seq int) string {
+		return models.FormatInvoiceNumber(kind, time.Now().UTC().Year(), seq)
+	}
+
+	paid := book(1, true)
+
+	t.Run("Only paid appointments", func(t *testing.T) {
+		unpaid := book(2, false)
+		w := sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/appointments/%d/invoice", unpaid.ID), patientToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Not for cancelled or refunded appointments", func(t *testing.T) {
+		cancelled := book(3, true)
+		require.NoError(t, db.Model(&cancelled).Update("status", models.StatusCancelled).Error)
+		w := sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/appointments/%d/receipt", cancelled.ID), patientToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		refunded := book(4, true)
+		now := time.Now()
+		require.NoError(t, db.Create(&models.Payment{
+			AppointmentID:  refunded.ID,
+			PatientID:      patient.ID,
+			Gateway:        "fake",
+			IntentID:       "pi_invoice_refunded",
+			Amount:         50,
+			Currency:       "usd",
+			Status:         models.PaymentRefunded,
+			RefundedAmount: 50,
+			PaidAt:         &now,
+			RefundedAt:     &now,
+		}).Error)
+		w = sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/appointments/%d/invoice", refunded.ID), patientToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Patient downloads an invoice", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			w := sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/appointments/%d/invoice", paid.ID), patientToken, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Header().Get("Content-Disposition"), number(models.InvoiceKindInvoice, 1)+".pdf")
+			assert.Contains(t, w.Body.String(), "%PDF-1.4")
+			assert.Contains(t, w.Body.String(), "(pi_invoice_1)")
+		}
+
+		var issued models.Invoice
+		require.NoError(t, db.Where("appointment_id = ? AND kind = ?", paid.ID, models.InvoiceKindInvoice).First(&issued).Error)
+		assert.Equal(t, 50.0, issued.Total)
+		assert.Equal(t, 41.67, issued.Subtotal)
+		assert.Equal(t, 8.33, issued.TaxAmount)
+		assert.Equal(t, "Test Patient", issued.PatientName)
+		assert.Equal(t, "Test Doctor", issued.DoctorName)
+	})
+
+	t.Run("Receipts are numbered separately", func(t *testing.T) {
+		w := sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/appointments/%d/receipt", paid.ID), patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Header().Get("Content-Disposition"), number(models.InvoiceKindReceipt, 1)+".pdf")
+	})
+
+	t.Run("Other patients cannot download", func(t *testing.T) {
+		w := sendJSON(r, "GET", fmt.Sprintf("/api/v1/patients/appointments/%d/invoice", paid.ID), otherToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Concurrent issuing gives sequential numbers", func(t *testing.T) {
+		var appointments []models.Appointment
+		for i := 0; i < 8; i++ {
+			appointments = append(appointments, book(10+i, true))
+		}
+
+		var wg sync.WaitGroup
+		codes := make([]int, len(appointments))
+		for i, appointment := range appointments {
+			wg.Add(1)
+			go func(i int, appointmentID uint) {
+				defer wg.Done()
+				w := sendJSON(r, "GET", fmt.Sprintf("/api/v1/admin/appointments/%d/invoice", appointmentID), adminToken, nil)
+				codes[i] = w.Code
+			}(i, appointment.ID)
+		}
+		wg.Wait()
+		for _, code := range codes {
+			assert.Equal(t, http.StatusOK, code)
+		}
+
+		var numbers []string
+		require.NoError(t, db.Model(&models.Invoice{}).Where("kind = ?", models.InvoiceKindInvoice).Pluck("number", &numbers).Error)
+		sort.Strings(numbers)
+		require.Len(t, numbers, len(appointments)+1)
+		for i, n := range numbers {
+			assert.Equal(t, number(models.InvoiceKindInvoice, i+1), n)
+		}
+	})
+
+	t.Run("Listing invoices", func(t *testing.T) {
+		w := sendJSON(r, "GET", "/api/v1/patients/invoices", patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var invoices []models.Invoice
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invoices))
+		assert.Len(t, invoices, 10)
+
+		w = sendJSON(r, "GET", "/api/v1/patients/invoices", otherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invoices))
+		assert.Empty(t, invoices)
+
+		w = sendJSON(r, "GET", "/api/v1/admin/invoices?kind=receipt", adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data []models.Invoice `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 1)
+		assert.Equal(t, number(models.InvoiceKindReceipt, 1), response.Data[0].Number)
+
+		w = sendJSON(r, "GET", "/api/v1/admin/invoices?kind=quote", adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,181 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/sandipdas/go-doctor-booking/backend/api/v1"
+	"github.com/sandipdas/go-doctor-booking/backend/middleware"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/oidc"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func setupOIDCRouter(db *gorm.DB, provider *oidc.Provider) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/api/v1/auth/oidc/login", v1.OIDCLogin(db, provider))
+	r.GET("/api/v1/auth/oidc/callback", v1.OIDCCallback(db, provider))
+	r.GET("/api/v1/users/profile", middleware.AuthMiddleware(db), v1.GetUserProfile(db))
+	return r
+}
+
+// oidcLogin runs the browser side of the login: start at the backend, follow
+// the redirect to the mock provider and return the callback response
+func oidcLogin(t *testing.T, r *gin.Engine) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/api/v1/auth/oidc/login", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected redirect to provider, got %d: %s", w.Code, w.Body.String())
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to call provider: %v", err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid callback URL: %v", err)
+	}
+
+	req, _ = http.NewRequest("GET", callback.RequestURI(), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestOIDCLogin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+	t.Setenv("OIDC_ROLE_CLAIM", "groups")
+	t.Setenv("OIDC_ROLE_MAPPING", "physicians=doctor,it-admins=admin")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+
+	mock := testhelper.NewMockOIDCProvider("doctor-booking")
+	defer mock.Close()
+	provider := oidc.NewProvider(mock.Config("http://localhost/api/v1/auth/oidc/callback"))
+	r := setupOIDCRouter(db, provider)
+
+	existing, err := testhelper.CreateTestUser(db, "Existing Doctor", "existing@example.com", "password123", models.PatientRole)
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	var staffToken string
+	t.Run("Links existing account by verified email", func(t *testing.T) {
+		mock.Claims = map[string]interface{}{
+			"sub":            "staff-1",
+			"email":          "existing@example.com",
+			"email_verified": true,
+			"groups":         []string{"physicians"},
+		}
+		w := oidcLogin(t, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp v1.AuthResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.NotEmpty(t, resp.Token)
+		staffToken = resp.Token
+		assert.Equal(t, existing.ID, resp.User.ID)
+		assert.Equal(t, string(models.DoctorRole), resp.User.Role)
+
+		var identity models.UserIdentity
+		assert.NoError(t, db.Where("issuer = ? AND subject = ?", mock.Issuer(), "staff-1").First(&identity).Error)
+		assert.Equal(t, existing.ID, identity.UserID)
+
+		// Mapped doctors can use the doctor routes straight away
+		var profile models.Doctor
+		assert.NoError(t, db.Where("user_id = ?", existing.ID).First(&profile).Error)
+	})
+
+	t.Run("Role changes revoke existing tokens", func(t *testing.T) {
+		var before models.User
+		assert.NoError(t, db.First(&before, existing.ID).Error)
+
+		mock.Claims = map[string]interface{}{
+			"sub":    "staff-1",
+			"groups": []string{"it-admins"},
+		}
+		w := oidcLogin(t, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var after models.User
+		assert.NoError(t, db.First(&after, existing.ID).Error)
+		assert.Equal(t, models.AdminRole, after.Role)
+		assert.Equal(t, before.TokenVersion+1, after.TokenVersion)
+
+		// Only the tokens issued with the new role are accepted
+		var resp v1.AuthResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusUnauthorized, getProfile(r, staffToken))
+		assert.Equal(t, http.StatusOK, getProfile(r, resp.Token))
+	})
+
+	t.Run("Creates new user with default role", func(t *testing.T) {
+		mock.Claims = map[string]interface{}{
+			"sub":            "staff-2",
+			"name":           "New Staff",
+			"email":          "new.staff@example.com",
+			"email_verified": true,
+		}
+		w := oidcLogin(t, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var user models.User
+		assert.NoError(t, db.Where("email = ?", "new.staff@example.com").First(&user).Error)
+		assert.Equal(t, models.PatientRole, user.Role)
+		assert.True(t, user.EmailVerified)
+	})
+
+	t.Run("Rejects unverified email", func(t *testing.T) {
+		mock.Claims = map[string]interface{}{
+			"sub":            "staff-3",
+			"email":          "unverified@example.com",
+			"email_verified": false,
+		}
+		w := oidcLogin(t, r)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Rejects reused state", func(t *testing.T) {
+		mock.Claims = map[string]interface{}{"sub": "staff-1"}
+		w := oidcLogin(t, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req, _ := http.NewRequest("GET", "/api/v1/auth/oidc/callback?code=abc&state=unknown", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Requires second factor for two-factor accounts", func(t *testing.T) {
+		assert.NoError(t, db.Model(&models.User{}).Where("id = ?", existing.ID).Updates(map[string]interface{}{
+			"totp_secret":  "JBSWY3DPEHPK3PXP",
+			"totp_enabled": true,
+		}).Error)
+
+		mock.Claims = map[string]interface{}{"sub": "staff-1"}
+		w := oidcLogin(t, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, true, resp["two_factor_required"])
+		assert.NotEmpty(t, resp["challenge_token"])
+		assert.Nil(t, resp["token"])
+	})
+}
@@ -0,0 +1,334 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/api/v1"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestPayments(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+	testhelper.Payments.Reset()
+
+	doctor := createTestDoctor(t, db, "payment-doctor@example.com")
+	require.NoError(t, db.Model(doctor).Update("consultation_fee", 40).Error)
+	patient := createTestPatient(t, db, "payment-patient@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "payment-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "payment-patient@example.com", "password123")
+
+	w := sendJSON(r, "PUT", "/api/v1/doctors/cancellation-policy", doctorToken, map[string]interface{}{
+		"min_notice_hours": 24,
+		"late_cancel_fee":  10,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	date := time.Now().UTC().AddDate(0, 0, 3).Format("2006-01-02")
+	w = sendJSON(r, "POST", "/api/v1/doctors/schedules", doctorToken, map[string]interface{}{
+		"date":       date,
+		"start_time": "09:00",
+		"end_time":   "10:00",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	webhook := func(payload []byte, header http.Header) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader(payload))
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	pay := func(appointmentID uint) (*httptest.ResponseRecorder, v1.PaymentResponse) {
+		w := sendJSON(r, "POST", fmt.Sprintf("/api/v1/patients/appointments/%d/pay", appointmentID), patientToken, nil)
+		var payment v1.PaymentResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &payment)
+		return w, payment
+	}
+	cancel := func(appointmentID uint) map[string]interface{} {
+		w := sendJSON(r, "PUT", fmt.Sprintf("/api/v1/patients/appointments/%d/cancel", appointmentID), patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	var booked struct {
+		models.Appointment
+		Payment *v1.PaymentResponse `json:"payment"`
+	}
+	t.Run("Booking starts a payment", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": date + "T09:00:00Z",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &booked))
+		require.NotNil(t, booked.Payment)
+		assert.NotEmpty(t, booked.Payment.ClientSecret)
+		assert.Equal(t, 40.0, booked.Payment.Amount)
+		assert.Equal(t, models.PaymentPending, booked.Payment.Status)
+
+		// Paying again returns the same intent
+		w, payment := pay(booked.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, booked.Payment.IntentID, payment.IntentID)
+	})
+
+	t.Run("Webhooks must be signed", func(t *testing.T) {
+		payload, header, err := testhelper.Payments.Succeed(booked.Payment.IntentID)
+		require.NoError(t, err)
+		header.Set(payments.SignatureHeader, payments.SignPayload("wrong-secret", payload, time.Now()))
+		assert.Equal(t, http.StatusBadRequest, webhook(payload, header).Code)
+
+		var stored models.Appointment
+		require.NoError(t, db.First(&stored, booked.ID).Error)
+		assert.False(t, stored.IsPaid)
+	})
+
+	t.Run("Successful payment marks the appointment paid", func(t *testing.T) {
+		payload, header, err := testhelper.Payments.Succeed(booked.Payment.IntentID)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, webhook(payload, header).Code)
+		// Redelivered events are acknowledged without effect
+		require.Equal(t, http.StatusOK, webhook(payload, header).Code)
+
+		var stored models.Appointment
+		require.NoError(t, db.First(&stored, booked.ID).Error)
+		assert.True(t, stored.IsPaid)
+		assert.Equal(t, 40.0, stored.PaymentAmount)
+		assert.Equal(t, booked.Payment.IntentID, stored.PaymentReference)
+
+		w, _ := pay(booked.ID)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Cancellation refunds in full with enough notice", func(t *testing.T) {
+		response := cancel(booked.ID)
+		assert.Equal(t, 40.0, response["refunded_amount"])
+
+		refunds := testhelper.Payments.Refunds()
+		require.Len(t, refunds, 1)
+		assert.Equal(t, int64(4000), refunds[0].Amount)
+
+		var payment models.Payment
+		require.NoError(t, db.Where("appointment_id = ?", booked.ID).First(&payment).Error)
+		assert.Equal(t, models.PaymentRefunded, payment.Status)
+		assert.Equal(t, 40.0, payment.RefundedAmount)
+	})
+
+	t.Run("Late cancellation keeps the fee", func(t *testing.T) {
+		start := time.Now().Add(2 * time.Hour).Truncate(time.Minute)
+		appointment := models.Appointment{
+			PatientID:       patient.ID,
+			DoctorID:        doctor.ID,
+			AppointmentDate: start,
+			StartTime:       start,
+			EndTime:         start.Add(30 * time.Minute),
+			Status:          models.StatusConfirmed,
+			Fee:             40,
+		}
+		require.NoError(t, db.Create(&appointment).Error)
+
+		w, payment := pay(appointment.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		payload, header, err := testhelper.Payments.Succeed(payment.IntentID)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, webhook(payload, header).Code)
+
+		response := cancel(appointment.ID)
+		assert.Equal(t, 10.0, response["late_cancellation_fee"])
+		assert.Equal(t, 30.0, response["refunded_amount"])
+
+		var stored models.Payment
+		require.NoError(t, db.Where("appointment_id = ?", appointment.ID).First(&stored).Error)
+		assert.Equal(t, models.PaymentPartiallyRefunded, stored.Status)
+		assert.Equal(t, 30.0, stored.RefundedAmount)
+	})
+
+	t.Run("Failed payments can be retried", func(t *testing.T) {
+		start := time.Now().Add(96 * time.Hour).Truncate(time.Minute)
+		appointment := models.Appointment{
+			PatientID:       patient.ID,
+			DoctorID:        doctor.ID,
+			AppointmentDate: start,
+			StartTime:       start,
+			EndTime:         start.Add(30 * time.Minute),
+			Status:          models.StatusPending,
+			Fee:             40,
+		}
+		require.NoError(t, db.Create(&appointment).Error)
+
+		_, payment := pay(appointment.ID)
+		payload, header, err := testhelper.Payments.Fail(payment.IntentID)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, webhook(payload, header).Code)
+
+		var stored models.Payment
+		require.NoError(t, db.Where("appointment_id = ?", appointment.ID).First(&stored).Error)
+		assert.Equal(t, models.PaymentFailed, stored.Status)
+
+		w, retry := pay(appointment.ID)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, payment.IntentID, retry.IntentID)
+	})
+	t.Run("Schedule cascades refund in full", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": date + "T09:30:00Z",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var appointment struct {
+			models.Appointment
+			Payment *v1.PaymentResponse `json:"payment"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &appointment))
+		require.NotNil(t, appointment.Payment)
+		payload, header, err := testhelper.Payments.Succeed(appointment.Payment.IntentID)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, webhook(payload, header).Code)
+
+		var schedule models.Schedule
+		require.NoError(t, db.Where("doctor_id = ?", doctor.ID).First(&schedule).Error)
+		w = sendJSON(r, "DELETE", fmt.Sprintf("/api/v1/doctors/schedules/%d?mode=cascade", schedule.ID), doctorToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 40.0, response["refunded_amount"])
+
+		var stored models.Payment
+		require.NoError(t, db.Where("appointment_id = ?", appointment.ID).First(&stored).Error)
+		assert.Equal(t, models.PaymentRefunded, stored.Status)
+	})
+}
+
+func TestBookingPathsStartPayments(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+	testhelper.Payments.Reset()
+
+	doctor := createTestDoctor(t, db, "paths-doctor@example.com")
+	require.NoError(t, db.Model(doctor).Update("consultation_fee", 40).Error)
+	patient := createTestPatient(t, db, "paths-patient@example.com")
+	_, err := testhelper.CreateTestUser(db, "Front Desk", "paths-desk@example.com", "password123", models.ReceptionistRole)
+	require.NoError(t, err)
+	doctorToken := testhelper.LoginTestUser(t, r, "paths-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "paths-patient@example.com", "password123")
+	deskToken := testhelper.LoginTestUser(t, r, "paths-desk@example.com", "password123")
+
+	next := time.Now().UTC().AddDate(0, 0, 3)
+	day := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, time.UTC)
+	for _, date := range []time.Time{day, day.AddDate(0, 0, 7)} {
+		schedule := models.Schedule{DoctorID: doctor.ID, Date: date, StartTime: "09:00", EndTime: "12:00", IsAvailable: true}
+		require.NoError(t, db.Create(&schedule).Error)
+	}
+
+	t.Run("Staff booking", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/staff/appointments", deskToken, map[string]interface{}{
+			"patient_id":   patient.ID,
+			"doctor_id":    doctor.ID,
+			"scheduled_at": day.Add(9 * time.Hour),
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var booked struct {
+			models.Appointment
+			Payment *v1.PaymentResponse `json:"payment"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &booked))
+		require.NotNil(t, booked.Payment)
+		assert.Equal(t, 40.0, booked.Payment.Amount)
+		assert.Equal(t, booked.ID, booked.Payment.AppointmentID)
+	})
+
+	t.Run("Series booking", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/patients/series", patientToken, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": day.Add(9*time.Hour + 30*time.Minute),
+			"count":        2,
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Payments []v1.PaymentResponse `json:"payments"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Payments, 2)
+		assert.NotEqual(t, response.Payments[0].IntentID, response.Payments[1].IntentID)
+	})
+
+	t.Run("Accepting a waitlist offer", func(t *testing.T) {
+		entry := models.WaitlistEntry{
+			PatientID: patient.ID,
+			DoctorID:  doctor.ID,
+			FromDate:  day,
+			ToDate:    day,
+			Status:    models.WaitlistOffered,
+		}
+		require.NoError(t, db.Create(&entry).Error)
+		offer := models.WaitlistOffer{
+			EntryID:   entry.ID,
+			PatientID: patient.ID,
+			DoctorID:  doctor.ID,
+			StartTime: day.Add(10 * time.Hour),
+			EndTime:   day.Add(10*time.Hour + 30*time.Minute),
+			ExpiresAt: time.Now().Add(time.Hour),
+			Status:    models.OfferPending,
+		}
+		require.NoError(t, db.Create(&offer).Error)
+
+		w := sendJSON(r, "POST", fmt.Sprintf("/api/v1/patients/waitlist/offers/%d/accept", offer.ID), patientToken, nil)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var booked struct {
+			Payment *v1.PaymentResponse `json:"payment"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &booked))
+		require.NotNil(t, booked.Payment)
+		assert.Equal(t, 40.0, booked.Payment.Amount)
+	})
+
+	t.Run("Follow-up booked by the doctor", func(t *testing.T) {
+		start := time.Now().Add(-48 * time.Hour).Truncate(time.Hour)
+		parent := models.Appointment{
+			PatientID:       patient.ID,
+			DoctorID:        doctor.ID,
+			AppointmentDate: start,
+			StartTime:       start,
+			EndTime:         start.Add(30 * time.Minute),
+			Status:          models.StatusCompleted,
+		}
+		require.NoError(t, db.Create(&parent).Error)
+
+		w := sendJSON(r, "POST", fmt.Sprintf("/api/v1/doctors/appointments/%d/follow-up", parent.ID), doctorToken, map[string]interface{}{
+			"from_date":    day.Format("2006-01-02"),
+			"to_date":      day.Format("2006-01-02"),
+			"scheduled_at": day.Add(11 * time.Hour),
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Appointment struct {
+				Payment *v1.PaymentResponse `json:"payment"`
+			} `json:"appointment"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.Appointment.Payment)
+		assert.Equal(t, 40.0, response.Appointment.Payment.Amount)
+	})
+}
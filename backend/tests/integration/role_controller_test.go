@@ -0,0 +1,154 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+// sendJSON sends an authenticated request with an optional JSON body
+func sendJSON(r *gin.Engine, method, url, token string, payload interface{}) *httptest.ResponseRecorder {
+	var body *bytes.Buffer
+	if payload != nil {
+		data, _ := json.Marshal(payload)
+		body = bytes.NewBuffer(data)
+	} else {
+		body = &bytes.Buffer{}
+	}
+
+	req, _ := http.NewRequest(method, url, body)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRolePermissions(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	_, err := testhelper.CreateTestUser(db, "Admin User", "rbac-admin@example.com", "password123", models.AdminRole)
+	require.NoError(t, err)
+	receptionist, err := testhelper.CreateTestUser(db, "Front Desk", "rbac-desk@example.com", "password123", models.ReceptionistRole)
+	require.NoError(t, err)
+	_, err = testhelper.CreateTestUser(db, "Patient User", "rbac-patient@example.com", "password123", models.PatientRole)
+	require.NoError(t, err)
+
+	adminToken := testhelper.LoginTestUser(t, r, "rbac-admin@example.com", "password123")
+	deskToken := testhelper.LoginTestUser(t, r, "rbac-desk@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "rbac-patient@example.com", "password123")
+
+	t.Run("Default permissions", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, sendJSON(r, "GET", "/api/v1/admin/appointments", adminToken, nil).Code)
+		assert.Equal(t, http.StatusOK, sendJSON(r, "GET", "/api/v1/admin/appointments", deskToken, nil).Code)
+		assert.Equal(t, http.StatusForbidden, sendJSON(r, "GET", "/api/v1/admin/appointments", patientToken, nil).Code)
+		assert.Equal(t, http.StatusForbidden, sendJSON(r, "GET", "/api/v1/admin/security-policy", deskToken, nil).Code)
+		assert.Equal(t, http.StatusOK, sendJSON(r, "GET", "/api/v1/patients/appointments", patientToken, nil).Code)
+		assert.Equal(t, http.StatusForbidden, sendJSON(r, "GET", "/api/v1/patients/appointments", deskToken, nil).Code)
+	})
+
+	t.Run("Defaults are seeded once", func(t *testing.T) {
+		hasClaims := func() bool {
+			ok, err := models.RoleHasPermission(db, models.AdminRole, models.PermissionManageClaims)
+			require.NoError(t, err)
+			return ok
+		}
+
+		// A default added after the roles were created reaches them on the next start
+		require.NoError(t, db.Unscoped().Where("permission = ?", models.PermissionManageClaims).Delete(&models.RolePermission{}).Error)
+		require.NoError(t, db.Unscoped().Where("permission = ?", models.PermissionManageClaims).Delete(&models.SeededPermission{}).Error)
+		require.False(t, hasClaims())
+		require.NoError(t, models.SeedDefaultRoles(db))
+		assert.True(t, hasClaims())
+
+		// Once removed by an administrator it stays removed
+		require.NoError(t, db.Unscoped().Where("permission = ?", models.PermissionManageClaims).Delete(&models.RolePermission{}).Error)
+		require.NoError(t, models.SeedDefaultRoles(db))
+		assert.False(t, hasClaims())
+
+		require.NoError(t, db.Create(&models.RolePermission{Role: models.AdminRole, Permission: models.PermissionManageClaims}).Error)
+	})
+
+	t.Run("Permissions can be changed", func(t *testing.T) {
+		w := sendJSON(r, "PUT", "/api/v1/admin/roles/receptionist/permissions", adminToken, map[string]interface{}{
+			"permissions": []string{models.PermissionViewAvailability},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusForbidden, sendJSON(r, "GET", "/api/v1/admin/appointments", deskToken, nil).Code)
+
+		w = sendJSON(r, "PUT", "/api/v1/admin/roles/receptionist/permissions", adminToken, map[string]interface{}{
+			"permissions": []string{"everything"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// The admin role cannot lose role management
+		w = sendJSON(r, "PUT", "/api/v1/admin/roles/admin/permissions", adminToken, map[string]interface{}{
+			"permissions": []string{models.PermissionReadUsers},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Custom roles", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/admin/roles", adminToken, map[string]interface{}{
+			"name":        "billing",
+			"description": "Billing office",
+			"permissions": []string{models.PermissionReadAllAppointments},
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		userURL := "/api/v1/admin/users/" + strconv.FormatUint(uint64(receptionist.ID), 10) + "/role"
+		w = sendJSON(r, "PUT", userURL, adminToken, map[string]string{"role": "billing"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// The old token carries the old role and is revoked
+		assert.Equal(t, http.StatusUnauthorized, sendJSON(r, "GET", "/api/v1/admin/appointments", deskToken, nil).Code)
+		deskToken = testhelper.LoginTestUser(t, r, "rbac-desk@example.com", "password123")
+		assert.Equal(t, http.StatusOK, sendJSON(r, "GET", "/api/v1/admin/appointments", deskToken, nil).Code)
+
+		// Assigned roles cannot be deleted
+		assert.Equal(t, http.StatusConflict, sendJSON(r, "DELETE", "/api/v1/admin/roles/billing", adminToken, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, sendJSON(r, "DELETE", "/api/v1/admin/roles/nurse", adminToken, nil).Code)
+
+		w = sendJSON(r, "PUT", userURL, adminToken, map[string]string{"role": "unknown"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// Role names may use the full column width
+		longName := "medical_records_coordinator_01"
+		w = sendJSON(r, "POST", "/api/v1/admin/roles", adminToken, map[string]interface{}{
+			"name":        longName,
+			"permissions": []string{models.PermissionReadAllAppointments},
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		w = sendJSON(r, "PUT", userURL, adminToken, map[string]string{"role": longName})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		// Users made doctors get a doctor profile
+		w = sendJSON(r, "PUT", userURL, adminToken, map[string]string{"role": string(models.DoctorRole)})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var profile models.Doctor
+		assert.NoError(t, db.Where("user_id = ?", receptionist.ID).First(&profile).Error)
+
+		// Custom roles can be required to use two-factor authentication
+		w = sendJSON(r, "PUT", "/api/v1/admin/security-policy", adminToken, map[string][]string{"require_2fa_roles": {"billing", "receptionist"}})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = sendJSON(r, "PUT", "/api/v1/admin/security-policy", adminToken, map[string][]string{"require_2fa_roles": {"unknown"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
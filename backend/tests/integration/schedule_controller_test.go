@@ -0,0 +1,130 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestScheduleManagement(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+	testhelper.Mailer.Reset()
+
+	doctor := createTestDoctor(t, db, "schedule-doctor@example.com")
+	patient := createTestPatient(t, db, "schedule-patient@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "schedule-doctor@example.com", "password123")
+
+	next := time.Now().UTC().AddDate(0, 0, 2)
+	day := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, time.UTC)
+	date := day.Format("2006-01-02")
+
+	t.Run("Bulk create", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/doctors/schedules/bulk", doctorToken, map[string]interface{}{
+			"start_date": date,
+			"end_date":   day.AddDate(0, 0, 6).Format("2006-01-02"),
+			"start_time": "09:00",
+			"end_time":   "12:00",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var count int64
+		db.Model(&models.Schedule{}).Where("doctor_id = ?", doctor.ID).Count(&count)
+		assert.Equal(t, int64(7), count)
+
+		// Overlapping ranges create nothing
+		w = sendJSON(r, "POST", "/api/v1/doctors/schedules/bulk", doctorToken, map[string]interface{}{
+			"start_date": day.AddDate(0, 0, 6).Format("2006-01-02"),
+			"end_date":   day.AddDate(0, 0, 8).Format("2006-01-02"),
+			"start_time": "11:00",
+			"end_time":   "13:00",
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		db.Model(&models.Schedule{}).Where("doctor_id = ?", doctor.ID).Count(&count)
+		assert.Equal(t, int64(7), count)
+	})
+
+	var schedule models.Schedule
+	require.NoError(t, db.Where("doctor_id = ? AND DATE(date) = ?", doctor.ID, date).First(&schedule).Error)
+
+	appointment := models.Appointment{
+		PatientID:       patient.ID,
+		DoctorID:        doctor.ID,
+		AppointmentDate: day.Add(10 * time.Hour),
+		StartTime:       day.Add(10 * time.Hour),
+		EndTime:         day.Add(10*time.Hour + 30*time.Minute),
+		Status:          models.StatusConfirmed,
+	}
+	require.NoError(t, db.Create(&appointment).Error)
+
+	url := fmt.Sprintf("/api/v1/doctors/schedules/%d", schedule.ID)
+
+	t.Run("List and get", func(t *testing.T) {
+		w := sendJSON(r, "GET", "/api/v1/doctors/schedules?from="+date+"&to="+date, doctorToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data []models.Schedule `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, http.StatusBadRequest, sendJSON(r, "GET", "/api/v1/doctors/schedules?from=tomorrow", doctorToken, nil).Code)
+
+		assert.Equal(t, http.StatusOK, sendJSON(r, "GET", url, doctorToken, nil).Code)
+	})
+
+	t.Run("Update keeping the appointment", func(t *testing.T) {
+		w := sendJSON(r, "PUT", url, doctorToken, map[string]interface{}{
+			"date":       date,
+			"start_time": "08:00",
+			"end_time":   "11:00",
+		})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		// Shrinking the window past the appointment is reported
+		w = sendJSON(r, "PUT", url, doctorToken, map[string]interface{}{
+			"date":       date,
+			"start_time": "08:00",
+			"end_time":   "10:00",
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Safe delete", func(t *testing.T) {
+		w := sendJSON(r, "DELETE", url, doctorToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response struct {
+			Affected []models.Appointment `json:"affected_appointments"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		if assert.Len(t, response.Affected, 1) {
+			assert.Equal(t, appointment.ID, response.Affected[0].ID)
+		}
+
+		w = sendJSON(r, "DELETE", url+"?mode=cascade", doctorToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var cancelled models.Appointment
+		require.NoError(t, db.First(&cancelled, appointment.ID).Error)
+		assert.Equal(t, models.StatusCancelled, cancelled.Status)
+		if assert.NotNil(t, cancelled.CancelledByID) {
+			assert.Equal(t, doctor.UserID, *cancelled.CancelledByID)
+		}
+		assert.NotNil(t, cancelled.CancelledAt)
+		assert.NotEmpty(t, cancelled.CancellationReason)
+
+		_, ok := testhelper.Mailer.Last("schedule-patient@example.com")
+		assert.True(t, ok)
+	})
+}
@@ -0,0 +1,114 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestScheduleTemplates(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	doctor := createTestDoctor(t, db, "template-doctor@example.com")
+	createTestPatient(t, db, "template-patient@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "template-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "template-patient@example.com", "password123")
+
+	// Find the next Monday and Tuesday at least a day away
+	day := time.Now().UTC().AddDate(0, 0, 1)
+	for day.Weekday() != time.Monday {
+		day = day.AddDate(0, 0, 1)
+	}
+	monday := day.Format("2006-01-02")
+	tuesday := day.AddDate(0, 0, 1).Format("2006-01-02")
+	wednesday := day.AddDate(0, 0, 2).Format("2006-01-02")
+
+	availability := func(date string) []string {
+		url := fmt.Sprintf("/api/v1/patients/doctors/%d/availability?date=%s", doctor.ID, date)
+		w := sendJSON(r, "GET", url, patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			AvailableSlots []string `json:"available_slots"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.AvailableSlots
+	}
+
+	w := sendJSON(r, "POST", "/api/v1/doctors/schedule-templates", doctorToken, map[string]interface{}{
+		"rrule":          "FREQ=WEEKLY;BYDAY=MO,WE",
+		"start_time":     "09:00",
+		"end_time":       "10:00",
+		"effective_from": time.Now().UTC().Format("2006-01-02"),
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	assert.Equal(t, []string{"09:00", "09:30"}, availability(monday))
+	assert.Empty(t, availability(tuesday))
+
+	// Recurring slots can be booked
+	w = sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+		"doctor_id":    doctor.ID,
+		"scheduled_at": monday + "T09:30:00Z",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, []string{"09:00"}, availability(monday))
+
+	var booked models.Appointment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &booked))
+
+	// A holiday over a booked appointment is reported until it is cascaded
+	holiday := map[string]interface{}{
+		"date":   monday,
+		"reason": "Public holiday",
+	}
+	w = sendJSON(r, "POST", "/api/v1/doctors/schedule-exceptions", doctorToken, holiday)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "affected_appointments")
+
+	// A holiday removes the day's slots
+	w = sendJSON(r, "POST", "/api/v1/doctors/schedule-exceptions?mode=cascade", doctorToken, holiday)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Empty(t, availability(monday))
+	var cancelled models.Appointment
+	require.NoError(t, db.First(&cancelled, booked.ID).Error)
+	assert.Equal(t, models.StatusCancelled, cancelled.Status)
+
+	// Deleting the template is reported while appointments depend on it
+	w = sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+		"doctor_id":    doctor.ID,
+		"scheduled_at": wednesday + "T09:00:00Z",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var template models.ScheduleTemplate
+	require.NoError(t, db.Where("doctor_id = ?", doctor.ID).First(&template).Error)
+	w = sendJSON(r, "DELETE", fmt.Sprintf("/api/v1/doctors/schedule-templates/%d", template.ID), doctorToken, nil)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	w = sendJSON(r, "DELETE", fmt.Sprintf("/api/v1/doctors/schedule-templates/%d?mode=cascade", template.ID), doctorToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		CancelledAppointments []uint `json:"cancelled_appointments"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.CancelledAppointments, 1)
+
+	w = sendJSON(r, "POST", "/api/v1/doctors/schedule-templates", doctorToken, map[string]interface{}{
+		"rrule":          "FREQ=DAILY",
+		"start_time":     "09:00",
+		"end_time":       "10:00",
+		"effective_from": monday,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
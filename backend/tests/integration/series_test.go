@@ -0,0 +1,134 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestAppointmentSeries(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	doctor := createTestDoctor(t, db, "series-doctor@example.com")
+	createTestPatient(t, db, "series-a@example.com")
+	createTestPatient(t, db, "series-b@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "series-doctor@example.com", "password123")
+	tokenA := testhelper.LoginTestUser(t, r, "series-a@example.com", "password123")
+	tokenB := testhelper.LoginTestUser(t, r, "series-b@example.com", "password123")
+
+	next := time.Now().UTC().AddDate(0, 0, 7)
+	first := time.Date(next.Year(), next.Month(), next.Day(), 9, 0, 0, 0, time.UTC)
+	byDay := strings.ToUpper(first.Weekday().String()[:2])
+
+	w := sendJSON(r, "POST", "/api/v1/doctors/schedule-templates", doctorToken, map[string]interface{}{
+		"rrule":          "FREQ=WEEKLY;BYDAY=" + byDay,
+		"start_time":     "09:00",
+		"end_time":       "10:00",
+		"effective_from": time.Now().UTC().Format("2006-01-02"),
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// Another patient already has the second week's 09:00 slot
+	w = sendJSON(r, "POST", "/api/v1/patients/appointments", tokenB, map[string]interface{}{
+		"doctor_id":    doctor.ID,
+		"scheduled_at": first.AddDate(0, 0, 7).Format(time.RFC3339),
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var blocking models.Appointment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &blocking))
+
+	type seriesResponse struct {
+		Series  models.AppointmentSeries `json:"series"`
+		Skipped []map[string]interface{} `json:"skipped"`
+	}
+	createSeries := func(start time.Time, mode string) (int, seriesResponse) {
+		w := sendJSON(r, "POST", "/api/v1/patients/series", tokenA, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": start.Format(time.RFC3339),
+			"count":        3,
+			"mode":         mode,
+		})
+		var response seriesResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+	countOwn := func() int64 {
+		var count int64
+		db.Model(&models.Appointment{}).
+			Where("patient_id <> ? AND status <> ?", blocking.PatientID, models.StatusCancelled).
+			Count(&count)
+		return count
+	}
+
+	t.Run("All or nothing", func(t *testing.T) {
+		code, _ := createSeries(first, models.SeriesAllOrNothing)
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, int64(0), countOwn())
+	})
+
+	var bestEffort seriesResponse
+	t.Run("Best effort", func(t *testing.T) {
+		var code int
+		code, bestEffort = createSeries(first, models.SeriesBestEffort)
+		require.Equal(t, http.StatusCreated, code)
+		assert.Len(t, bestEffort.Series.Appointments, 2)
+		assert.Len(t, bestEffort.Skipped, 1)
+	})
+
+	code, weekly := createSeries(first.Add(30*time.Minute), models.SeriesAllOrNothing)
+	require.Equal(t, http.StatusCreated, code)
+	require.Len(t, weekly.Series.Appointments, 3)
+
+	t.Run("Cancel this and following", func(t *testing.T) {
+		url := fmt.Sprintf("/api/v1/patients/appointments/%d/cancel?series=following", bestEffort.Series.Appointments[0].ID)
+		w := sendJSON(r, "PUT", url, tokenA, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Cancelled []uint `json:"cancelled_appointments"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Cancelled, 2)
+		assert.Equal(t, int64(3), countOwn())
+
+		url = fmt.Sprintf("/api/v1/patients/appointments/%d/cancel?series=all", blocking.ID)
+		w = sendJSON(r, "PUT", url, tokenB, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Reschedule the whole series", func(t *testing.T) {
+		url := fmt.Sprintf("/api/v1/patients/appointments/%d/reschedule?series=all", weekly.Series.Appointments[0].ID)
+		body := map[string]interface{}{"scheduled_at": first.Format(time.RFC3339)}
+
+		// The second week's 09:00 slot is taken, so nothing moves
+		w := sendJSON(r, "PUT", url, tokenA, body)
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+		w = sendJSON(r, "PUT", fmt.Sprintf("/api/v1/patients/appointments/%d/cancel", blocking.ID), tokenB, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = sendJSON(r, "PUT", url, tokenA, body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var moved []models.Appointment
+		require.NoError(t, db.Where("series_id = ?", weekly.Series.ID).Order("start_time ASC").Find(&moved).Error)
+		require.Len(t, moved, 3)
+		for i, appt := range moved {
+			assert.True(t, appt.StartTime.Equal(first.AddDate(0, 0, 7*i)), appt.StartTime)
+			assert.Equal(t, models.StatusRescheduled, appt.Status)
+		}
+	})
+}
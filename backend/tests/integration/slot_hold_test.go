@@ -0,0 +1,137 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/sandipdas/go-doctor-booking/backend/api/v1"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestSlotHolds(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	doctor := createTestDoctor(t, db, "hold-doctor@example.com")
+	createTestPatient(t, db, "hold-a@example.com")
+	createTestPatient(t, db, "hold-b@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "hold-doctor@example.com", "password123")
+	tokenA := testhelper.LoginTestUser(t, r, "hold-a@example.com", "password123")
+	tokenB := testhelper.LoginTestUser(t, r, "hold-b@example.com", "password123")
+
+	next := time.Now().UTC().AddDate(0, 0, 2)
+	day := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, time.UTC)
+	date := day.Format("2006-01-02")
+	nine := day.Add(9 * time.Hour)
+	nineThirty := day.Add(9*time.Hour + 30*time.Minute)
+
+	w := sendJSON(r, "POST", "/api/v1/doctors/schedules", doctorToken, map[string]interface{}{
+		"date":       date,
+		"start_time": "09:00",
+		"end_time":   "10:00",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	availability := func() []string {
+		url := fmt.Sprintf("/api/v1/patients/doctors/%d/availability?date=%s", doctor.ID, date)
+		w := sendJSON(r, "GET", url, tokenB, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			AvailableSlots []string `json:"available_slots"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.AvailableSlots
+	}
+	hold := func(token string, at time.Time) (int, uint) {
+		w := sendJSON(r, "POST", "/api/v1/patients/slots/hold", token, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": at.Format(time.RFC3339),
+		})
+		var response struct {
+			HoldID     uint `json:"hold_id"`
+			TTLSeconds int  `json:"ttl_seconds"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.HoldID
+	}
+
+	code, holdID := hold(tokenA, nine)
+	require.Equal(t, http.StatusCreated, code)
+
+	t.Run("Held slots are unavailable to others", func(t *testing.T) {
+		assert.Equal(t, []string{"09:30"}, availability())
+
+		code, _ := hold(tokenB, nine)
+		assert.Equal(t, http.StatusConflict, code)
+
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", tokenB, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": nine.Format(time.RFC3339),
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Holds are converted by booking", func(t *testing.T) {
+		// Other patients cannot use the hold
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", tokenB, map[string]interface{}{"hold_id": holdID})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = sendJSON(r, "POST", "/api/v1/patients/appointments", tokenA, map[string]interface{}{
+			"hold_id":      holdID,
+			"scheduled_at": nineThirty.Format(time.RFC3339),
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = sendJSON(r, "POST", "/api/v1/patients/appointments", tokenA, map[string]interface{}{"hold_id": holdID})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var stored models.SlotHold
+		require.NoError(t, db.First(&stored, holdID).Error)
+		assert.Equal(t, models.HoldConverted, stored.Status)
+		assert.NotNil(t, stored.AppointmentID)
+
+		// A converted hold cannot be used again
+		w = sendJSON(r, "POST", "/api/v1/patients/appointments", tokenA, map[string]interface{}{"hold_id": holdID})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Stale holds are reaped", func(t *testing.T) {
+		code, holdID := hold(tokenB, nineThirty)
+		require.Equal(t, http.StatusCreated, code)
+		assert.Empty(t, availability())
+
+		require.NoError(t, db.Model(&models.SlotHold{}).Where("id = ?", holdID).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+		assert.Equal(t, []string{"09:30"}, availability())
+
+		require.NoError(t, v1.ExpireSlotHolds(db))
+		var stored models.SlotHold
+		require.NoError(t, db.First(&stored, holdID).Error)
+		assert.Equal(t, models.HoldExpired, stored.Status)
+
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", tokenB, map[string]interface{}{"hold_id": holdID})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		code, holdID := hold(tokenB, nineThirty)
+		require.Equal(t, http.StatusCreated, code)
+
+		w := sendJSON(r, "DELETE", fmt.Sprintf("/api/v1/patients/slots/hold/%d", holdID), tokenB, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"09:30"}, availability())
+
+		w = sendJSON(r, "DELETE", fmt.Sprintf("/api/v1/patients/slots/hold/%d", holdID), tokenB, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
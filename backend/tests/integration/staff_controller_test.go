@@ -0,0 +1,120 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestStaffBooking(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+	// Longer than the time until the booked slots, which staff may still move
+	t.Setenv("RESCHEDULE_MIN_NOTICE", "96h")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+
+	patient := createTestPatient(t, db, "staff-patient@example.com")
+	doctor := createTestDoctor(t, db, "staff-doctor@example.com")
+	receptionist, err := testhelper.CreateTestUser(db, "Front Desk", "desk@example.com", "password123", models.ReceptionistRole)
+	require.NoError(t, err)
+
+	deskToken := testhelper.LoginTestUser(t, r, "desk@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "staff-patient@example.com", "password123")
+
+	next := time.Now().UTC().AddDate(0, 0, 2)
+	day := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, time.UTC)
+	schedule := models.Schedule{DoctorID: doctor.ID, Date: day, StartTime: "09:00", EndTime: "12:00", IsAvailable: true}
+	require.NoError(t, db.Create(&schedule).Error)
+
+	t.Run("Patients cannot use staff booking", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/staff/appointments", patientToken, map[string]interface{}{
+			"patient_id":   patient.ID,
+			"doctor_id":    doctor.ID,
+			"scheduled_at": day.Add(9 * time.Hour),
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	var booked models.Appointment
+	t.Run("Book for an existing patient", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/staff/appointments", deskToken, map[string]interface{}{
+			"patient_id":   patient.ID,
+			"doctor_id":    doctor.ID,
+			"scheduled_at": day.Add(9 * time.Hour),
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &booked))
+		assert.Equal(t, patient.ID, booked.PatientID)
+		if assert.NotNil(t, booked.BookedByID) {
+			assert.Equal(t, receptionist.ID, *booked.BookedByID)
+		}
+
+		// Only patients can be booked for
+		w = sendJSON(r, "POST", "/api/v1/staff/appointments", deskToken, map[string]interface{}{
+			"patient_id":   receptionist.ID,
+			"doctor_id":    doctor.ID,
+			"scheduled_at": day.Add(10 * time.Hour),
+		})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Book for a walk-in patient", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/staff/appointments", deskToken, map[string]interface{}{
+			"patient":      map[string]string{"name": "Walk In", "phone": "555-0100"},
+			"doctor_id":    doctor.ID,
+			"scheduled_at": day.Add(10 * time.Hour),
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var appointment models.Appointment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &appointment))
+		var walkIn models.User
+		require.NoError(t, db.First(&walkIn, appointment.PatientID).Error)
+		assert.Equal(t, models.PatientRole, walkIn.Role)
+		assert.Equal(t, "555-0100", walkIn.Phone)
+
+		// A failed booking does not leave a patient behind
+		var before int64
+		db.Model(&models.User{}).Count(&before)
+		w = sendJSON(r, "POST", "/api/v1/staff/appointments", deskToken, map[string]interface{}{
+			"patient":      map[string]string{"name": "Too Late", "phone": "555-0101"},
+			"doctor_id":    doctor.ID,
+			"scheduled_at": day.Add(10 * time.Hour),
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		var after int64
+		db.Model(&models.User{}).Count(&after)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("Reschedule and cancel", func(t *testing.T) {
+		url := fmt.Sprintf("/api/v1/staff/appointments/%d", booked.ID)
+		w := sendJSON(r, "PUT", url+"/reschedule", deskToken, map[string]interface{}{
+			"scheduled_at": day.Add(11 * time.Hour),
+		})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = sendJSON(r, "PUT", url+"/cancel", deskToken, map[string]string{"reason": "Patient called"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var cancelled models.Appointment
+		require.NoError(t, db.Preload("StatusHistory").First(&cancelled, booked.ID).Error)
+		assert.Equal(t, models.StatusCancelled, cancelled.Status)
+		assert.Equal(t, "Patient called", cancelled.CancellationReason)
+		if assert.NotEmpty(t, cancelled.StatusHistory) {
+			last := cancelled.StatusHistory[len(cancelled.StatusHistory)-1]
+			assert.Equal(t, receptionist.ID, last.ChangedByID)
+			assert.Equal(t, models.ReceptionistRole, last.ChangedByRole)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestDoctorTimeZone(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+	testhelper.Mailer.Reset()
+
+	doctor := createTestDoctor(t, db, "tz-doctor@example.com")
+	createTestPatient(t, db, "tz-patient@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "tz-doctor@example.com", "password123")
+	patientToken := testhelper.LoginTestUser(t, r, "tz-patient@example.com", "password123")
+
+	t.Run("Invalid zone", func(t *testing.T) {
+		for _, zone := range []string{"Mars/Olympus_Mons", "Local"} {
+			w := sendJSON(r, "PUT", "/api/v1/doctors/time-zone", doctorToken, map[string]interface{}{"time_zone": zone})
+			assert.Equal(t, http.StatusBadRequest, w.Code, zone)
+		}
+	})
+
+	w := sendJSON(r, "PUT", "/api/v1/doctors/time-zone", doctorToken, map[string]interface{}{"time_zone": "Asia/Kolkata"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	next := time.Now().In(kolkata).AddDate(0, 0, 2)
+	date := next.Format("2006-01-02")
+
+	w = sendJSON(r, "POST", "/api/v1/doctors/schedules", doctorToken, map[string]interface{}{
+		"date":       date,
+		"start_time": "09:00",
+		"end_time":   "10:00",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	t.Run("Availability is in the doctor's zone", func(t *testing.T) {
+		url := fmt.Sprintf("/api/v1/patients/doctors/%d/availability?date=%s", doctor.ID, date)
+		w := sendJSON(r, "GET", url, patientToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			TimeZone       string            `json:"time_zone"`
+			AvailableSlots []string          `json:"available_slots"`
+			Slots          []json.RawMessage `json:"slots"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Asia/Kolkata", response.TimeZone)
+		assert.Equal(t, []string{"09:00", "09:30"}, response.AvailableSlots)
+		require.Len(t, response.Slots, 2)
+		assert.Contains(t, string(response.Slots[0]), date+"T09:00:00+05:30")
+	})
+
+	t.Run("Booking from another zone", func(t *testing.T) {
+		// 09:30 in Kolkata sent as UTC
+		start := time.Date(next.Year(), next.Month(), next.Day(), 9, 30, 0, 0, kolkata)
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": start.UTC().Format(time.RFC3339),
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), date+"T09:30:00+05:30")
+
+		// 09:00 UTC is outside the doctor's hours even though it reads the same
+		w = sendJSON(r, "POST", "/api/v1/patients/appointments", patientToken, map[string]interface{}{
+			"doctor_id":    doctor.ID,
+			"scheduled_at": date + "T09:00:00Z",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Changing zone reports stranded appointments", func(t *testing.T) {
+		w := sendJSON(r, "PUT", "/api/v1/doctors/time-zone", doctorToken, map[string]interface{}{"time_zone": "UTC"})
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+		var stored models.Doctor
+		require.NoError(t, db.First(&stored, doctor.ID).Error)
+		assert.Equal(t, "Asia/Kolkata", stored.TimeZone)
+
+		w = sendJSON(r, "PUT", "/api/v1/doctors/time-zone?mode=cascade", doctorToken, map[string]interface{}{"time_zone": "UTC"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Cancelled []uint `json:"cancelled_appointments"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Cancelled, 1)
+		_, ok := testhelper.Mailer.Last("tz-patient@example.com")
+		assert.True(t, ok)
+	})
+}
@@ -0,0 +1,194 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/sandipdas/go-doctor-booking/backend/api/v1"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/tests/testhelper"
+)
+
+func TestWaitlist(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key")
+
+	db := testhelper.SetupTestDB(t)
+	defer testhelper.CleanupTestDB(db)
+	r := testhelper.SetupTestRouter(db)
+	testhelper.Mailer.Reset()
+
+	doctor := createTestDoctor(t, db, "waitlist-doctor@example.com")
+	createTestPatient(t, db, "waitlist-a@example.com")
+	createTestPatient(t, db, "waitlist-b@example.com")
+	createTestPatient(t, db, "waitlist-c@example.com")
+	doctorToken := testhelper.LoginTestUser(t, r, "waitlist-doctor@example.com", "password123")
+	tokenA := testhelper.LoginTestUser(t, r, "waitlist-a@example.com", "password123")
+	tokenB := testhelper.LoginTestUser(t, r, "waitlist-b@example.com", "password123")
+	tokenC := testhelper.LoginTestUser(t, r, "waitlist-c@example.com", "password123")
+
+	next := time.Now().UTC().AddDate(0, 0, 2)
+	day := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, time.UTC)
+	date := day.Format("2006-01-02")
+	nine := day.Add(9 * time.Hour)
+	nineThirty := day.Add(9*time.Hour + 30*time.Minute)
+
+	w := sendJSON(r, "POST", "/api/v1/doctors/schedules", doctorToken, map[string]interface{}{
+		"date":       date,
+		"start_time": "09:00",
+		"end_time":   "10:00",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	fee := 75.0
+	checkUp := models.AppointmentType{DoctorID: doctor.ID, Name: "Check-up", Duration: 30, Fee: &fee}
+	require.NoError(t, db.Create(&checkUp).Error)
+
+	bookType := func(token string, at time.Time, typeID *uint) *models.Appointment {
+		w := sendJSON(r, "POST", "/api/v1/patients/appointments", token, map[string]interface{}{
+			"doctor_id":           doctor.ID,
+			"scheduled_at":        at.Format(time.RFC3339),
+			"appointment_type_id": typeID,
+		})
+		if w.Code != http.StatusCreated {
+			return nil
+		}
+		var appointment models.Appointment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &appointment))
+		return &appointment
+	}
+	book := func(token string, at time.Time) *models.Appointment {
+		return bookType(token, at, nil)
+	}
+	availability := func() []string {
+		url := fmt.Sprintf("/api/v1/patients/doctors/%d/availability?date=%s", doctor.ID, date)
+		w := sendJSON(r, "GET", url, tokenC, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			AvailableSlots []string `json:"available_slots"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.AvailableSlots
+	}
+	pendingOffer := func(token string) *models.WaitlistOffer {
+		w := sendJSON(r, "GET", "/api/v1/patients/waitlist", token, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var entries []models.WaitlistEntry
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		for _, entry := range entries {
+			if len(entry.Offers) > 0 {
+				return &entry.Offers[0]
+			}
+		}
+		return nil
+	}
+
+	first := bookType(tokenA, nine, &checkUp.ID)
+	require.NotNil(t, first)
+	second := book(tokenA, nineThirty)
+	require.NotNil(t, second)
+	assert.Empty(t, availability())
+
+	join := func(token string) {
+		w := sendJSON(r, "POST", "/api/v1/patients/waitlist", token, map[string]interface{}{
+			"doctor_id": doctor.ID,
+			"from_date": date,
+			"to_date":   day.AddDate(0, 0, 3).Format("2006-01-02"),
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	join(tokenB)
+	join(tokenC)
+
+	t.Run("Validation", func(t *testing.T) {
+		w := sendJSON(r, "POST", "/api/v1/patients/waitlist", tokenB, map[string]interface{}{
+			"doctor_id": doctor.ID,
+			"from_date": date,
+			"to_date":   date,
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = sendJSON(r, "POST", "/api/v1/patients/waitlist", tokenA, map[string]interface{}{
+			"doctor_id": doctor.ID,
+			"from_date": date,
+			"to_date":   day.AddDate(0, 0, -1).Format("2006-01-02"),
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Cancellation is offered in order and held", func(t *testing.T) {
+		w := sendJSON(r, "PUT", fmt.Sprintf("/api/v1/patients/appointments/%d/cancel", first.ID), tokenA, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		offer := pendingOffer(tokenB)
+		require.NotNil(t, offer)
+		assert.True(t, offer.StartTime.Equal(nine))
+		assert.Nil(t, pendingOffer(tokenC))
+		_, ok := testhelper.Mailer.Last("waitlist-b@example.com")
+		assert.True(t, ok)
+
+		// The held slot is not offered to or bookable by anyone else
+		assert.Empty(t, availability())
+		assert.Nil(t, book(tokenC, nine))
+	})
+
+	t.Run("Declined offers move to the next patient", func(t *testing.T) {
+		offer := pendingOffer(tokenB)
+		require.NotNil(t, offer)
+		w := sendJSON(r, "POST", fmt.Sprintf("/api/v1/patients/waitlist/offers/%d/decline", offer.ID), tokenB, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		next := pendingOffer(tokenC)
+		require.NotNil(t, next)
+		w = sendJSON(r, "POST", fmt.Sprintf("/api/v1/patients/waitlist/offers/%d/accept", next.ID), tokenC, nil)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var entry models.WaitlistEntry
+		require.NoError(t, db.Where("offers.id = ?", next.ID).
+			Joins("JOIN waitlist_offers offers ON offers.entry_id = waitlist_entries.id").
+			First(&entry).Error)
+		assert.Equal(t, models.WaitlistBooked, entry.Status)
+		require.NotNil(t, entry.AppointmentID)
+
+		// The freed slot is booked as the same type of visit
+		var booked models.Appointment
+		require.NoError(t, db.First(&booked, *entry.AppointmentID).Error)
+		if assert.NotNil(t, booked.AppointmentTypeID) {
+			assert.Equal(t, checkUp.ID, *booked.AppointmentTypeID)
+		}
+		assert.Equal(t, fee, booked.Fee)
+
+		// Accepting twice fails
+		w = sendJSON(r, "POST", fmt.Sprintf("/api/v1/patients/waitlist/offers/%d/accept", next.ID), tokenC, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Expired offers are released", func(t *testing.T) {
+		w := sendJSON(r, "PUT", fmt.Sprintf("/api/v1/doctors/appointments/%d/status", second.ID), doctorToken, map[string]interface{}{
+			"status": models.StatusCancelled,
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		offer := pendingOffer(tokenB)
+		require.NotNil(t, offer)
+		assert.True(t, offer.StartTime.Equal(nineThirty))
+
+		require.NoError(t, db.Model(&models.WaitlistOffer{}).Where("id = ?", offer.ID).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+		// Lapsed offers are no longer listed, even before the reaper runs
+		assert.Nil(t, pendingOffer(tokenB))
+		require.NoError(t, v1.ExpireWaitlistOffers(db, testhelper.Mailer))
+
+		var expired models.WaitlistOffer
+		require.NoError(t, db.First(&expired, offer.ID).Error)
+		assert.Equal(t, models.OfferExpired, expired.Status)
+
+		// Nobody else is waiting, so the slot opens up again
+		assert.Equal(t, []string{"09:30"}, availability())
+	})
+}
@@ -0,0 +1,169 @@
+package testhelper
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sandipdas/go-doctor-booking/backend/oidc"
+)
+
+// MockOIDCProvider is a minimal OpenID Connect provider for tests. Its
+// authorization endpoint immediately redirects back with a code for the
+// current Claims, and its token endpoint enforces the PKCE verifier.
+type MockOIDCProvider struct {
+	Server   *httptest.Server
+	ClientID string
+	// Claims are added to every ID token issued after they are set
+	Claims map[string]interface{}
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+}
+
+type mockAuthorization struct {
+	nonce     string
+	challenge string
+	claims    map[string]interface{}
+}
+
+// NewMockOIDCProvider starts a mock provider. Call Close when done.
+func NewMockOIDCProvider(clientID string) *MockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	m := &MockOIDCProvider{
+		ClientID: clientID,
+		Claims:   map[string]interface{}{},
+		key:      key,
+		codes:    make(map[string]mockAuthorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.handleDiscovery)
+	mux.HandleFunc("/authorize", m.handleAuthorize)
+	mux.HandleFunc("/token", m.handleToken)
+	mux.HandleFunc("/jwks", m.handleJWKS)
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+// Issuer returns the provider's issuer URL
+func (m *MockOIDCProvider) Issuer() string {
+	return m.Server.URL
+}
+
+// Config returns an oidc.Config for a client of this provider
+func (m *MockOIDCProvider) Config(redirectURL string) oidc.Config {
+	return oidc.Config{
+		IssuerURL:   m.Issuer(),
+		ClientID:    m.ClientID,
+		RedirectURL: redirectURL,
+		Scopes:      []string{"openid", "email", "profile"},
+	}
+}
+
+// Close shuts down the provider
+func (m *MockOIDCProvider) Close() {
+	m.Server.Close()
+}
+
+func (m *MockOIDCProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 m.Issuer(),
+		"authorization_endpoint": m.Issuer() + "/authorize",
+		"token_endpoint":         m.Issuer() + "/token",
+		"jwks_uri":               m.Issuer() + "/jwks",
+	})
+}
+
+func (m *MockOIDCProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != m.ClientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code, _ := oidc.RandomString()
+	m.mu.Lock()
+	claims := make(map[string]interface{}, len(m.Claims))
+	for k, v := range m.Claims {
+		claims[k] = v
+	}
+	m.codes[code] = mockAuthorization{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), claims: claims}
+	m.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *MockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != auth.challenge {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   m.Issuer(),
+		"aud":   m.ClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": auth.nonce,
+	}
+	for k, v := range auth.claims {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock-key"
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (m *MockOIDCProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := m.key.PublicKey
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "mock-key",
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
@@ -3,24 +3,67 @@ package testhelper
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/sandipdas/go-doctor-booking/backend/api/v1"
+	"github.com/sandipdas/go-doctor-booking/backend/mailer"
 	"github.com/sandipdas/go-doctor-booking/backend/middleware"
+	"github.com/sandipdas/go-doctor-booking/backend/models"
+	"github.com/sandipdas/go-doctor-booking/backend/oidc"
+	"github.com/sandipdas/go-doctor-booking/backend/payments"
 	"gorm.io/gorm"
 )
 
+// Mailer captures the emails sent by the routes registered in SetupTestRoutes
+var Mailer = mailer.NewMemoryMailer()
+
+// Payments is the payment gateway used by the routes registered in SetupTestRoutes
+var Payments = payments.NewFakeGateway("whsec_test")
+
 func SetupTestRoutes(router *gin.RouterGroup, db *gorm.DB) {
 	// Public routes
 	auth := router.Group("/auth")
 	{
-		auth.POST("/register", v1.RegisterUser(db))
+		auth.POST("/register", v1.RegisterUser(db, Mailer))
 		auth.POST("/login", v1.LoginUser(db))
+		auth.POST("/refresh", v1.RefreshToken(db))
+		auth.POST("/forgot-password", v1.ForgotPassword(db, Mailer))
+		auth.POST("/reset-password", v1.ResetPassword(db))
+		auth.GET("/verify-email", v1.VerifyEmail(db))
+		auth.POST("/2fa/verify", v1.VerifyTwoFactorLogin(db))
+
+		// Two-factor enrollment also accepts enrollment tokens from LoginUser
+		enroll := auth.Group("/2fa")
+		enroll.Use(middleware.EnrollmentAuthMiddleware(db))
+		{
+			enroll.POST("/setup", v1.SetupTwoFactor(db))
+			enroll.POST("/enable", v1.EnableTwoFactor(db))
+		}
+
+		// Single sign-on, only when an identity provider is configured
+		if oidcConfig, ok := oidc.LoadConfigFromEnv(); ok {
+			provider := oidc.NewProvider(oidcConfig)
+			auth.GET("/oidc/login", v1.OIDCLogin(db, provider))
+			auth.GET("/oidc/callback", v1.OIDCCallback(db, provider))
+		}
 	}
 
+	// Payment gateway events
+	router.POST("/payments/webhook", v1.PaymentWebhook(db, Payments))
+
 	// Protected routes
 	authorized := router.Group("")
-	authorized.Use(middleware.AuthMiddleware())
+	authorized.Use(middleware.AuthMiddleware(db))
 	{
+		// Session routes
+		session := authorized.Group("/auth")
+		session.Use(middleware.UserOnlyMiddleware())
+		{
+			session.POST("/logout", v1.LogoutUser(db))
+			session.POST("/2fa/disable", v1.DisableTwoFactor(db))
+			session.POST("/verify-email/resend", v1.ResendVerificationEmail(db, Mailer))
+		}
+
 		// User routes
 		users := authorized.Group("/users")
+		users.Use(middleware.UserOnlyMiddleware())
 		{
 			users.GET("/profile", v1.GetUserProfile(db))
 			users.PUT("/profile", v1.UpdateUserProfile(db))
@@ -34,34 +77,132 @@ func SetupTestRoutes(router *gin.RouterGroup, db *gorm.DB) {
 			router.GET("/doctors/:id", v1.GetDoctorProfile(db))
 
 			// Protected doctor routes
-			doctorRoutes := doctors.Group("")
-			doctorRoutes.Use(middleware.RoleMiddleware("doctor", "admin"))
+			doctors.GET("/dashboard", middleware.RequirePermission(db, models.PermissionViewDoctorDashboard), v1.GetDoctorDashboard(db))
+			doctors.GET("/appointments", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), v1.GetDoctorAppointments(db))
+			doctors.PUT("/appointments/:id/status", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), v1.UpdateAppointmentStatus(db, Mailer, Payments))
+			doctors.PUT("/appointments/:id/reschedule", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), v1.RescheduleDoctorAppointment(db))
+			doctors.POST("/appointments/:id/follow-up", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), v1.CreateFollowUp(db, Mailer, Payments))
+			doctors.PUT("/appointments/:id/no-show", middleware.RequirePermission(db, models.PermissionManageDoctorAppointments), v1.MarkNoShow(db))
+
+			schedules := doctors.Group("")
+			schedules.Use(middleware.RequirePermission(db, models.PermissionManageSchedules))
 			{
-				doctorRoutes.GET("/dashboard", v1.GetDoctorDashboard(db))
-				doctorRoutes.POST("/schedules", v1.CreateSchedule(db))
-				doctorRoutes.GET("/appointments", v1.GetDoctorAppointments(db))
-				doctorRoutes.PUT("/appointments/:id/status", v1.UpdateAppointmentStatus(db))
+				schedules.POST("/schedules", v1.CreateSchedule(db))
+				schedules.POST("/schedules/bulk", v1.BulkCreateSchedules(db, Mailer, Payments))
+				schedules.GET("/schedules", v1.ListSchedules(db))
+				schedules.GET("/schedules/:id", v1.GetSchedule(db))
+				schedules.PUT("/schedules/:id", v1.UpdateSchedule(db, Mailer, Payments))
+				schedules.DELETE("/schedules/:id", v1.DeleteSchedule(db, Mailer, Payments))
+				schedules.PUT("/time-zone", v1.UpdateDoctorTimeZone(db, Mailer, Payments))
+				schedules.GET("/schedule-templates", v1.ListScheduleTemplates(db))
+				schedules.POST("/schedule-templates", v1.CreateScheduleTemplate(db, Mailer, Payments))
+				schedules.PUT("/schedule-templates/:id", v1.UpdateScheduleTemplate(db, Mailer, Payments))
+				schedules.DELETE("/schedule-templates/:id", v1.DeleteScheduleTemplate(db, Mailer, Payments))
+				schedules.GET("/schedule-exceptions", v1.ListScheduleExceptions(db))
+				schedules.POST("/schedule-exceptions", v1.CreateScheduleException(db, Mailer, Payments))
+				schedules.DELETE("/schedule-exceptions/:id", v1.DeleteScheduleException(db))
+				schedules.GET("/appointment-types", v1.ListAppointmentTypes(db))
+				schedules.POST("/appointment-types", v1.CreateAppointmentType(db))
+				schedules.PUT("/appointment-types/:id", v1.UpdateAppointmentType(db))
+				schedules.DELETE("/appointment-types/:id", v1.DeleteAppointmentType(db))
+				schedules.GET("/cancellation-policy", v1.GetCancellationPolicy(db))
+				schedules.PUT("/cancellation-policy", v1.UpdateCancellationPolicy(db))
 			}
 		}
 
 		// Patient routes
 		patients := authorized.Group("/patients")
-		patients.Use(middleware.RoleMiddleware("patient"))
 		{
-			patients.GET("/doctors", v1.ListDoctors(db))
-			patients.GET("/doctors/:id/availability", v1.GetDoctorAvailability(db))
-			patients.POST("/appointments", v1.BookAppointment(db))
-			patients.GET("/appointments", v1.GetPatientAppointments(db))
-			patients.PUT("/appointments/:id/cancel", v1.CancelAppointment(db))
+			patients.GET("/doctors", middleware.RequirePermission(db, models.PermissionViewAvailability), v1.ListDoctors(db))
+			// Availability is also open to API keys with the doctors:read scope
+			patients.GET("/doctors/:id/availability", middleware.ScopeMiddleware(models.ScopeDoctorsRead),
+				middleware.RequirePermission(db, models.PermissionViewAvailability), v1.GetDoctorAvailability(db))
+			patients.GET("/doctors/:id/appointment-types", middleware.RequirePermission(db, models.PermissionViewAvailability), v1.ListDoctorAppointmentTypes(db))
+			patients.GET("/doctors/:id/cancellation-policy", middleware.RequirePermission(db, models.PermissionViewAvailability), v1.GetDoctorCancellationPolicy(db))
+			patients.POST("/appointments", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.BookAppointment(db, Payments))
+			patients.POST("/slots/hold", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.HoldSlot(db))
+			patients.DELETE("/slots/hold/:id", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.ReleaseSlotHold(db))
+			patients.GET("/appointments", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.GetPatientAppointments(db))
+			patients.PUT("/appointments/:id/cancel", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.CancelAppointment(db, Mailer, Payments))
+			patients.POST("/appointments/:id/pay", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.PayAppointment(db, Payments))
+			patients.GET("/appointments/:id/invoice", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.DownloadInvoice(db, models.InvoiceKindInvoice))
+			patients.GET("/appointments/:id/receipt", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.DownloadInvoice(db, models.InvoiceKindReceipt))
+			patients.GET("/invoices", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.ListInvoices(db))
+			patients.POST("/appointments/:id/eligibility", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.CheckEligibility(db, Payments))
+			patients.GET("/insurance", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.ListInsurancePolicies(db))
+			patients.POST("/insurance", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.CreateInsurancePolicy(db))
+			patients.PUT("/insurance/:id", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.UpdateInsurancePolicy(db))
+			patients.DELETE("/insurance/:id", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.DeleteInsurancePolicy(db))
+			patients.GET("/claims", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.ListPatientClaims(db))
+			patients.PUT("/appointments/:id/reschedule", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.RescheduleAppointment(db))
+			patients.POST("/series", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.CreateAppointmentSeries(db, Payments))
+			patients.GET("/series", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.ListAppointmentSeries(db))
+			patients.GET("/series/:id", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.GetAppointmentSeries(db))
+			patients.GET("/follow-ups", middleware.RequirePermission(db, models.PermissionManageOwnAppointments), v1.ListFollowUps(db))
+			patients.POST("/follow-ups/:id/book", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.BookFollowUp(db, Payments))
+			patients.POST("/waitlist", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.JoinWaitlist(db))
+			patients.GET("/waitlist", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.ListWaitlist(db))
+			patients.DELETE("/waitlist/:id", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.LeaveWaitlist(db, Mailer))
+			patients.POST("/waitlist/offers/:id/accept", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.AcceptWaitlistOffer(db, Mailer, Payments))
+			patients.POST("/waitlist/offers/:id/decline", middleware.RequirePermission(db, models.PermissionBookAppointment), v1.DeclineWaitlistOffer(db, Mailer))
 		}
 
-		// Admin routes
+		// Staff routes for booking on behalf of patients
+		staff := authorized.Group("/staff")
+		staff.Use(middleware.RequirePermission(db, models.PermissionBookOnBehalf))
+		{
+			staff.GET("/patients", v1.SearchPatients(db))
+			staff.POST("/patients", v1.CreateStaffPatient(db))
+			staff.POST("/appointments", v1.StaffBookAppointment(db, Payments))
+			staff.PUT("/appointments/:id/reschedule", v1.StaffRescheduleAppointment(db))
+			staff.PUT("/appointments/:id/cancel", v1.StaffCancelAppointment(db, Mailer, Payments))
+		}
+
+		// Admin routes. Reporting and user status routes are also open to API keys
+		// with the matching scope.
 		admin := authorized.Group("/admin")
-		admin.Use(middleware.RoleMiddleware("admin"))
 		{
-			admin.GET("/users", v1.ListAllUsers(db))
-			admin.PUT("/users/:id/status", v1.UpdateUserStatus(db))
-			admin.GET("/appointments", v1.ListAllAppointments(db))
+			admin.GET("/users", middleware.ScopeMiddleware(models.ScopeUsersRead),
+				middleware.RequirePermission(db, models.PermissionReadUsers), v1.ListAllUsers(db))
+			admin.PUT("/users/:id/status", middleware.ScopeMiddleware(models.ScopeUsersWrite),
+				middleware.RequirePermission(db, models.PermissionManageUsers), v1.UpdateUserStatus(db))
+			admin.POST("/users/:id/unlock", middleware.RequirePermission(db, models.PermissionManageUsers), v1.UnlockUserLogin(db))
+			admin.PUT("/users/:id/role", middleware.RequirePermission(db, models.PermissionManageRoles), v1.UpdateUserRole(db))
+			admin.GET("/appointments", middleware.ScopeMiddleware(models.ScopeAppointmentsRead),
+				middleware.RequirePermission(db, models.PermissionReadAllAppointments), v1.ListAllAppointments(db))
+			admin.GET("/appointments/:id/invoice", middleware.RequirePermission(db, models.PermissionReadAllAppointments), v1.AdminDownloadInvoice(db, models.InvoiceKindInvoice))
+			admin.GET("/appointments/:id/receipt", middleware.RequirePermission(db, models.PermissionReadAllAppointments), v1.AdminDownloadInvoice(db, models.InvoiceKindReceipt))
+			admin.GET("/invoices", middleware.RequirePermission(db, models.PermissionReadAllAppointments), v1.ListAllInvoices(db))
+
+			claims := admin.Group("")
+			claims.Use(middleware.RequirePermission(db, models.PermissionManageClaims))
+			{
+				claims.POST("/appointments/:id/eligibility", v1.AdminCheckEligibility(db, Payments))
+				claims.GET("/claims", v1.ListClaims(db))
+				claims.POST("/claims", v1.CreateClaim(db))
+				claims.GET("/claims/:id", v1.GetClaim(db))
+				claims.PUT("/claims/:id/status", v1.UpdateClaimStatus(db))
+			}
+
+			security := admin.Group("")
+			security.Use(middleware.RequirePermission(db, models.PermissionManageSecurity))
+			{
+				security.GET("/security-policy", v1.GetSecurityPolicy(db))
+				security.PUT("/security-policy", v1.UpdateSecurityPolicy(db))
+				security.GET("/api-keys", v1.ListAPIKeys(db))
+				security.POST("/api-keys", v1.CreateAPIKey(db))
+				security.DELETE("/api-keys/:id", v1.RevokeAPIKey(db))
+			}
+
+			roles := admin.Group("")
+			roles.Use(middleware.RequirePermission(db, models.PermissionManageRoles))
+			{
+				roles.GET("/permissions", v1.ListPermissions())
+				roles.GET("/roles", v1.ListRoles(db))
+				roles.POST("/roles", v1.CreateRole(db))
+				roles.PUT("/roles/:role/permissions", v1.UpdateRolePermissions(db))
+				roles.DELETE("/roles/:role", v1.DeleteRole(db))
+			}
 		}
 	}
 }
@@ -91,10 +91,42 @@ func SetupTestDB(t *testing.T) *gorm.DB {
 		&models.Doctor{},
 		&models.Schedule{},
 		&models.Appointment{},
+		&models.AppointmentStatusChange{},
+		&models.AppointmentReschedule{},
+		&models.AppointmentSeries{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.UserToken{},
+		&models.LoginThrottle{},
+		&models.RecoveryCode{},
+		&models.SecurityPolicy{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+		&models.APIKey{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.SeededPermission{},
+		&models.ScheduleTemplate{},
+		&models.ScheduleException{},
+		&models.WaitlistEntry{},
+		&models.WaitlistOffer{},
+		&models.SlotHold{},
+		&models.AppointmentType{},
+		&models.FollowUp{},
+		&models.CancellationPolicy{},
+		&models.Payment{},
+		&models.Invoice{},
+		&models.InvoiceCounter{},
+		&models.InsurancePolicy{},
+		&models.Claim{},
+		&models.ClaimStatusChange{},
 	)
 	if err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}
+	if err := models.SeedDefaultRoles(db); err != nil {
+		t.Fatalf("Failed to seed roles: %v", err)
+	}
 
 	return db
 }
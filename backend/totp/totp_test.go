@@ -0,0 +1,59 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 appendix B test vectors for SHA1, truncated to 6 digits
+func TestCodeAt(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	testCases := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1111111111, expected: "050471"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, tc := range testCases {
+		code, err := CodeAt(secret, Step(time.Unix(tc.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, code, "unix=%d", tc.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	code, err := CodeAt(secret, Step(now.Add(-Period)))
+	assert.NoError(t, err)
+
+	step, ok := Validate(secret, code, now)
+	assert.True(t, ok, "previous step should be accepted")
+	assert.Equal(t, Step(now)-1, step)
+
+	stale, _ := CodeAt(secret, Step(now.Add(-5*Period)))
+	_, ok = Validate(secret, stale, now)
+	assert.False(t, ok, "old codes should be rejected")
+
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("JBSWY3DPEHPK3PXP", "Doctor Booking", "doc@example.com")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Doctor%20Booking:doc@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Doctor+Booking")
+}